@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// MigrateUsage is the usage of the migrate command. "to 0" takes back every
+// migration.
+const MigrateUsage = "usage: migrate up|down|status|to N"
+
+// RunMigrate runs the migrate command of args, such as "up" or "to 3", on
+// the db of driver, then writes the status of the migrations to w as a
+// table.
+func RunMigrate(ctx context.Context, db *sql.DB, driver string, args []string, w io.Writer) error {
+	var (
+		command = ""
+		version int64
+	)
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch {
+	case len(args) == 1 && (command == "up" || command == "down" || command == "status"):
+	case len(args) == 2 && command == "to":
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: N must be a version: %q", MigrateUsage, args[1])
+		}
+		version = n
+	default:
+		return errors.New(MigrateUsage)
+	}
+
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return err
+	}
+	m, err := NewMigrator(db, driver, migrations)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx)
+	case "to":
+		err = m.To(ctx, version)
+	}
+	if err != nil {
+		return err
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return printStatus(w, statuses)
+}
+
+// printStatus writes statuses to w as a table.
+func printStatus(w io.Writer, statuses []*MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		switch {
+		case s.Edited:
+			status = "edited"
+		case s.Unknown:
+			status = "unknown"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	return tw.Flush()
+}
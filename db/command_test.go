@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+func TestRunMigrate(t *testing.T) {
+	t.Parallel()
+
+	todoDB, err := db.Open("sqlite3", filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	for _, args := range [][]string{nil, {"sideways"}, {"to"}, {"to", "-1"}, {"up", "1"}} {
+		if err := db.RunMigrate(ctx, todoDB, "sqlite3", args, &bytes.Buffer{}); err == nil {
+			t.Fatal("expected an error for ", args)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := db.RunMigrate(ctx, todoDB, "sqlite3", []string{"to", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if !strings.HasPrefix(lines[0], "VERSION") {
+		t.Fatal("expected: header, actual: ", lines[0])
+	}
+	if !strings.Contains(lines[1], "applied") || !strings.Contains(lines[2], "pending") {
+		t.Fatal("expected: 1 applied and 2 pending, actual: ", out.String())
+	}
+}
@@ -1,25 +1,122 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
+	"fmt"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-//go:embed schema.sql
-var schema string
+// ftsSchema sets up the FTS5 index of todos. FTS5 is only compiled into
+// go-sqlite3 with the sqlite_fts5 build tag, so it is kept apart from the
+// migrations.
+//
+//go:embed fts.sql
+var ftsSchema string
 
-// NewDB returns go-sqlite3 driver based *sql.DB.
+// Open returns *sql.DB of driver, sqlite3 or postgres, as it is: no
+// migration is applied. source is the path of SQLite or the DSN of
+// PostgreSQL.
+func Open(driver, source string) (*sql.DB, error) {
+	switch driver {
+	case "sqlite3":
+		// foreign key constraints are enforced on every connection, and a
+		// transaction takes the write lock when it begins: one that reads
+		// first and writes later would otherwise fail with "database is
+		// locked" when another writes in between, as SQLite cannot upgrade
+		// its read lock then
+		return sql.Open(driver, source+"?_foreign_keys=on&_txlock=immediate")
+	case "postgres":
+		return sql.Open(driver, source)
+	default:
+		return nil, fmt.Errorf("db: unknown driver %q", driver)
+	}
+}
+
+// NewDB returns go-sqlite3 driver based *sql.DB with every migration
+// applied. Foreign key constraints are enforced on every connection, and
+// every transaction begins immediate.
 func NewDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
+	db, err := Open("sqlite3", path)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := db.Exec(schema); err != nil {
+	if err := migrateUp(db, "sqlite3"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := setupFTS(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// HasFTS reports whether FTS5 is compiled into the SQLite of db, which it
+// is only with the sqlite_fts5 build tag. Search results are not ranked
+// without it.
+func HasFTS(db *sql.DB) (bool, error) {
+	var enabled bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// setupFTS creates the full-text index when FTS5 is available and fills it
+// from the existing TODOs whenever its triggers were missing, which they are
+// the first time, after todos is rebuilt by a migration, and after a build
+// without FTS5 has opened the DB.
+//
+// Without FTS5 the triggers are dropped instead, as they would fail every
+// write to todos with "no such module: fts5"; the index is left as it is,
+// since it cannot be dropped without the module either, and search falls
+// back to LIKE.
+func setupFTS(db *sql.DB) error {
+	enabled, err := HasFTS(db)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		_, err := db.Exec(`DROP TRIGGER IF EXISTS trigger_todos_fts_insert;
+DROP TRIGGER IF EXISTS trigger_todos_fts_delete;
+DROP TRIGGER IF EXISTS trigger_todos_fts_update;`)
+		return err
+	}
+
+	var exists bool
+	err = db.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'trigger' AND name = 'trigger_todos_fts_insert'`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(ftsSchema); err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := db.Exec(`INSERT INTO todos_fts(todos_fts) VALUES('rebuild')`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUp applies the migrations of driver not applied to db yet.
+func migrateUp(db *sql.DB, driver string) error {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return err
+	}
+	m, err := NewMigrator(db, driver, migrations)
+	if err != nil {
+		return err
+	}
+	return m.Up(context.Background())
+}
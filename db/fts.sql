@@ -0,0 +1,22 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS todos_fts USING fts5(
+  subject,
+  description,
+  content='todos',
+  content_rowid='id'
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_fts_insert AFTER INSERT ON todos
+BEGIN
+  INSERT INTO todos_fts(rowid, subject, description) VALUES (NEW.id, NEW.subject, NEW.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_fts_delete AFTER DELETE ON todos
+BEGIN
+  INSERT INTO todos_fts(todos_fts, rowid, subject, description) VALUES ('delete', OLD.id, OLD.subject, OLD.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_fts_update AFTER UPDATE OF subject, description ON todos
+BEGIN
+  INSERT INTO todos_fts(todos_fts, rowid, subject, description) VALUES ('delete', OLD.id, OLD.subject, OLD.description);
+  INSERT INTO todos_fts(rowid, subject, description) VALUES (NEW.id, NEW.subject, NEW.description);
+END;
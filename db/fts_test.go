@@ -0,0 +1,90 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// TestNewDBOpensFTSDB opens a DB set up by a build with FTS5 from this
+// build, which has none. The index of such a DB is faked in sqlite_master,
+// as it cannot be created here.
+func TestNewDBOpensFTSDB(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const fts = `PRAGMA writable_schema = ON;
+INSERT INTO sqlite_master(type, name, tbl_name, rootpage, sql) VALUES('table', 'todos_fts', 'todos_fts', 0, 'CREATE VIRTUAL TABLE todos_fts USING fts5(subject, description, content=''todos'', content_rowid=''id'')');
+PRAGMA writable_schema = OFF;
+CREATE TRIGGER trigger_todos_fts_insert AFTER INSERT ON todos
+BEGIN
+  INSERT INTO todos_fts(rowid, subject, description) VALUES (NEW.id, NEW.subject, NEW.description);
+END;
+CREATE TRIGGER trigger_todos_fts_delete AFTER DELETE ON todos
+BEGIN
+  INSERT INTO todos_fts(todos_fts, rowid, subject, description) VALUES ('delete', OLD.id, OLD.subject, OLD.description);
+END;
+CREATE TRIGGER trigger_todos_fts_update AFTER UPDATE OF subject, description ON todos
+BEGIN
+  INSERT INTO todos_fts(todos_fts, rowid, subject, description) VALUES ('delete', OLD.id, OLD.subject, OLD.description);
+  INSERT INTO todos_fts(rowid, subject, description) VALUES (NEW.id, NEW.subject, NEW.description);
+END;`
+	if _, err := todoDB.Exec(fts); err != nil {
+		t.Fatal(err)
+	}
+	todoDB.Close()
+
+	const insert = `INSERT INTO todos(subject) VALUES('subject')`
+	todoDB, err = db.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := todoDB.Exec(insert); err == nil || !strings.Contains(err.Error(), "no such module: fts5") {
+		t.Fatal("expected: no such module: fts5, actual: ", err)
+	}
+	todoDB.Close()
+
+	todoDB, err = db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	if _, err := todoDB.Exec(insert); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := todoDB.Exec(`UPDATE todos SET subject = 'updated'`); err != nil {
+		t.Fatal(err)
+	}
+
+	// every migration, the table rebuilding ones included, is taken back
+	// and applied again
+	migrations, err := db.Migrations("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := db.NewMigrator(todoDB, "sqlite3", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := todoDB.Exec(insert); err != nil {
+		t.Fatal(err)
+	}
+}
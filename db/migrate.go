@@ -0,0 +1,408 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles are the migrations of every driver, in a directory named
+// after it.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationName matches the file names of migrations, such as
+// "0002_add_todos_color.up.sql".
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// A dialect is what the Migrator does differently for a driver.
+type dialect struct {
+	// begin starts the transaction of a run, keeping other runners out
+	// until it ends.
+	begin       []string
+	createTable string
+	insert      string
+	delete      string
+}
+
+// postgresMigrationLock is the advisory lock key of migration runs.
+const postgresMigrationLock = 0x746f646f
+
+var dialects = map[string]*dialect{
+	"sqlite3": {
+		// IMMEDIATE takes the write lock up front, so that a second runner
+		// waits for the first one rather than reading what it changes.
+		begin: []string{`BEGIN IMMEDIATE`},
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version    INTEGER  NOT NULL PRIMARY KEY,
+  name       TEXT     NOT NULL,
+  checksum   TEXT     NOT NULL,
+  applied_at DATETIME NOT NULL DEFAULT (DATETIME('now'))
+)`,
+		insert: `INSERT INTO schema_migrations(version, name, checksum) VALUES(?, ?, ?)`,
+		delete: `DELETE FROM schema_migrations WHERE version = ?`,
+	},
+	"postgres": {
+		begin: []string{`BEGIN`, fmt.Sprintf(`SELECT pg_advisory_xact_lock(%d)`, postgresMigrationLock)},
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version    BIGINT    NOT NULL PRIMARY KEY,
+  name       TEXT      NOT NULL,
+  checksum   TEXT      NOT NULL,
+  applied_at TIMESTAMP NOT NULL DEFAULT (NOW() AT TIME ZONE 'UTC')
+)`,
+		insert: `INSERT INTO schema_migrations(version, name, checksum) VALUES($1, $2, $3)`,
+		delete: `DELETE FROM schema_migrations WHERE version = $1`,
+	},
+}
+
+type (
+	// A Migration changes the schema by Up and takes the change back by
+	// Down.
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// A MigrationStatus expresses whether a migration is applied.
+	MigrationStatus struct {
+		Version   int64
+		Name      string
+		Applied   bool
+		AppliedAt time.Time
+		// Edited is true when the migration has changed since it was
+		// applied.
+		Edited bool
+		// Unknown is true when the migration is applied but not known to
+		// this build, which is older than the schema.
+		Unknown bool
+	}
+)
+
+// Checksum returns the SHA-256 of m.Up, which tells when an applied
+// migration has been edited.
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrations returns the migrations built in for driver.
+func Migrations(driver string) ([]*Migration, error) {
+	if _, ok := dialects[driver]; !ok {
+		return nil, fmt.Errorf("db: no migrations for driver %q", driver)
+	}
+	fsys, err := fs.Sub(migrationFiles, path.Join("migrations", driver))
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(fsys)
+}
+
+// LoadMigrations reads the migrations in the top directory of fsys. Each
+// needs both an up and a down file named like "0001_initial.up.sql".
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		m := migrationName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			return nil, fmt.Errorf("db: %s is not a migration", entry.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("db: %s: version must be a positive number", entry.Name())
+		}
+		b, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("db: %s: version %d is also %s", entry.Name(), version, migration.Name)
+		}
+		if m[3] == "up" {
+			migration.Up = string(b)
+		} else {
+			migration.Down = string(b)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("db: migration %d %s needs both up and down", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// A Migrator moves the schema of a DB between the versions of its
+// migrations, recording the applied ones in schema_migrations.
+//
+// Every run takes a lock, so concurrent runners take turns, and is one
+// transaction: it applies all of its migrations or none.
+type Migrator struct {
+	db         *sql.DB
+	dialect    *dialect
+	migrations []*Migration
+}
+
+// NewMigrator returns new Migrator applying migrations to db, which is
+// opened with driver.
+func NewMigrator(db *sql.DB, driver string, migrations []*Migration) (*Migrator, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("db: no migrations for driver %q", driver)
+	}
+	migrations = append([]*Migration(nil), migrations...)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("db: migration %s: version must be positive", m.Name)
+		}
+		if i > 0 && migrations[i-1].Version == m.Version {
+			return nil, fmt.Errorf("db: migration %d is both %s and %s", m.Version, migrations[i-1].Name, m.Name)
+		}
+	}
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// Up applies every migration not applied yet.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.migrate(ctx, func(applied []*MigrationStatus) (int64, error) {
+		var version int64
+		if len(m.migrations) > 0 {
+			version = m.migrations[len(m.migrations)-1].Version
+		}
+		// migrations unknown to this build are kept
+		if len(applied) > 0 && applied[len(applied)-1].Version > version {
+			version = applied[len(applied)-1].Version
+		}
+		return version, nil
+	})
+}
+
+// Down takes back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.migrate(ctx, func(applied []*MigrationStatus) (int64, error) {
+		if len(applied) < 2 {
+			return 0, nil
+		}
+		return applied[len(applied)-2].Version, nil
+	})
+}
+
+// To applies every migration up to version and takes back every one after
+// it. A version of 0 takes back all of them.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	return m.migrate(ctx, func(applied []*MigrationStatus) (int64, error) {
+		if version == 0 {
+			return 0, nil
+		}
+		for _, migration := range m.migrations {
+			if migration.Version == version {
+				return version, nil
+			}
+		}
+		return 0, fmt.Errorf("db: migration %d does not exist", version)
+	})
+}
+
+// Status returns the status of every migration, known or applied, in order
+// of version.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	var ret []*MigrationStatus
+	err := m.run(ctx, func(conn *sql.Conn, applied []*MigrationStatus) error {
+		ret = m.status(applied)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// status merges applied with the migrations of m.
+func (m *Migrator) status(applied []*MigrationStatus) []*MigrationStatus {
+	byVersion := make(map[int64]*MigrationStatus, len(applied))
+	ret := make([]*MigrationStatus, 0, len(m.migrations)+len(applied))
+	for _, s := range applied {
+		s.Unknown = true
+		byVersion[s.Version] = s
+		ret = append(ret, s)
+	}
+	for _, migration := range m.migrations {
+		s, ok := byVersion[migration.Version]
+		if !ok {
+			ret = append(ret, &MigrationStatus{Version: migration.Version, Name: migration.Name})
+			continue
+		}
+		s.Unknown = false
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version < ret[j].Version
+	})
+	return ret
+}
+
+// migrate moves the schema to the version target returns for the applied
+// migrations.
+func (m *Migrator) migrate(ctx context.Context, target func(applied []*MigrationStatus) (int64, error)) error {
+	return m.run(ctx, func(conn *sql.Conn, applied []*MigrationStatus) error {
+		for _, s := range applied {
+			if s.Edited {
+				return fmt.Errorf("db: migration %d %s has been edited since it was applied", s.Version, s.Name)
+			}
+		}
+		version, err := target(applied)
+		if err != nil {
+			return err
+		}
+
+		byVersion := make(map[int64]*Migration, len(m.migrations))
+		for _, migration := range m.migrations {
+			byVersion[migration.Version] = migration
+		}
+
+		// the latest is taken back first
+		isApplied := make(map[int64]bool, len(applied))
+		for i := len(applied) - 1; i >= 0; i-- {
+			s := applied[i]
+			isApplied[s.Version] = true
+			if s.Version <= version {
+				continue
+			}
+			migration, ok := byVersion[s.Version]
+			if !ok {
+				return fmt.Errorf("db: migration %d %s is unknown to this build, so it cannot be taken back", s.Version, s.Name)
+			}
+			if _, err := conn.ExecContext(ctx, migration.Down); err != nil {
+				return fmt.Errorf("db: migration %d %s down: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, m.dialect.delete, migration.Version); err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if isApplied[migration.Version] {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, migration.Up); err != nil {
+				return fmt.Errorf("db: migration %d %s up: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, m.dialect.insert, migration.Version, migration.Name, migration.Checksum()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// run calls fn with the applied migrations in order of version, in a
+// transaction holding the lock of migration runs. The transaction commits
+// when fn returns nil.
+//
+// The transaction is begun by hand on a connection of its own, because
+// database/sql cannot begin it the way dialect does.
+func (m *Migrator) run(ctx context.Context, fn func(conn *sql.Conn, applied []*MigrationStatus) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	committed := false
+	defer func() {
+		if !committed {
+			// ctx may be why the run failed, and the connection goes back
+			// to the pool, so it must not be left in the transaction
+			conn.ExecContext(context.Background(), `ROLLBACK`)
+		}
+	}()
+	for _, query := range m.dialect.begin {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := fn(conn, applied); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// applied reads schema_migrations, comparing the checksums with the ones
+// of the migrations of m.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) ([]*MigrationStatus, error) {
+	checksums := make(map[int64]string, len(m.migrations))
+	for _, migration := range m.migrations {
+		checksums[migration.Version] = migration.Checksum()
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := []*MigrationStatus{}
+	for rows.Next() {
+		var (
+			s        = &MigrationStatus{Applied: true}
+			checksum string
+		)
+		if err := rows.Scan(&s.Version, &s.Name, &checksum, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		if want, ok := checksums[s.Version]; ok && want != checksum {
+			s.Edited = true
+		}
+		applied = append(applied, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
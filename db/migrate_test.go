@@ -0,0 +1,391 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		files   fstest.MapFS
+		want    []int64
+		wantErr bool
+	}{
+		"Normal": {
+			files: fstest.MapFS{
+				"0002_add_b.up.sql":   {Data: []byte("b")},
+				"0002_add_b.down.sql": {Data: []byte("b")},
+				"0001_add_a.up.sql":   {Data: []byte("a")},
+				"0001_add_a.down.sql": {Data: []byte("a")},
+			},
+			want: []int64{1, 2},
+		},
+		"Down missing": {
+			files:   fstest.MapFS{"0001_add_a.up.sql": {Data: []byte("a")}},
+			wantErr: true,
+		},
+		"Version taken": {
+			files: fstest.MapFS{
+				"0001_add_a.up.sql":   {Data: []byte("a")},
+				"0001_add_b.down.sql": {Data: []byte("b")},
+			},
+			wantErr: true,
+		},
+		"Not a migration": {
+			files:   fstest.MapFS{"schema.sql": {Data: []byte("a")}},
+			wantErr: true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			migrations, err := db.LoadMigrations(c.files)
+			if (err != nil) != c.wantErr {
+				t.Fatal("unexpected error: ", err)
+			}
+			versions := []int64{}
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			if !c.wantErr && !reflect.DeepEqual(versions, c.want) {
+				t.Fatal("expected: ", c.want, ", actual: ", versions)
+			}
+		})
+	}
+
+	for _, driver := range []string{"sqlite3", "postgres"} {
+		if _, err := db.Migrations(driver); err != nil {
+			t.Error(driver, err)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	const path = "../.sqlite3/migrate_test.db"
+	t.Cleanup(func() {
+		if err := os.Remove(path); err != nil {
+			t.Error("failed to cleanup testdata, err =", err)
+		}
+	})
+
+	todoDB, err := db.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	migrations := []*db.Migration{
+		{Version: 1, Name: "add_a", Up: "CREATE TABLE a (id INTEGER)", Down: "DROP TABLE a"},
+		{Version: 2, Name: "add_b", Up: "CREATE TABLE b (id INTEGER)", Down: "DROP TABLE b"},
+		{Version: 3, Name: "add_a_name", Up: "ALTER TABLE a ADD COLUMN name TEXT", Down: "ALTER TABLE a DROP COLUMN name"},
+	}
+	newMigrator := func(t *testing.T, migrations ...*db.Migration) *db.Migrator {
+		t.Helper()
+		m, err := db.NewMigrator(todoDB, "sqlite3", migrations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	// status returns the applied versions, and the tables there are
+	status := func(t *testing.T, m *db.Migrator) ([]int64, []string) {
+		t.Helper()
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions := []int64{}
+		for _, s := range statuses {
+			if s.Applied {
+				versions = append(versions, s.Version)
+			}
+		}
+		tables := []string{}
+		rows, err := todoDB.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name IN ('a', 'b') ORDER BY name`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+			tables = append(tables, name)
+		}
+		return versions, tables
+	}
+
+	t.Run("move", func(t *testing.T) {
+		m := newMigrator(t, migrations...)
+		for _, tc := range []struct {
+			name       string
+			run        func() error
+			wantApply  []int64
+			wantTables []string
+		}{
+			{name: "status", run: func() error { return nil }, wantApply: []int64{}, wantTables: []string{}},
+			{name: "up", run: func() error { return m.Up(ctx) }, wantApply: []int64{1, 2, 3}, wantTables: []string{"a", "b"}},
+			{name: "up again", run: func() error { return m.Up(ctx) }, wantApply: []int64{1, 2, 3}, wantTables: []string{"a", "b"}},
+			{name: "down", run: func() error { return m.Down(ctx) }, wantApply: []int64{1, 2}, wantTables: []string{"a", "b"}},
+			{name: "to 1", run: func() error { return m.To(ctx, 1) }, wantApply: []int64{1}, wantTables: []string{"a"}},
+			{name: "to 3", run: func() error { return m.To(ctx, 3) }, wantApply: []int64{1, 2, 3}, wantTables: []string{"a", "b"}},
+			{name: "to 0", run: func() error { return m.To(ctx, 0) }, wantApply: []int64{}, wantTables: []string{}},
+			{name: "down at 0", run: func() error { return m.Down(ctx) }, wantApply: []int64{}, wantTables: []string{}},
+		} {
+			if err := tc.run(); err != nil {
+				t.Fatal(tc.name, err)
+			}
+			versions, tables := status(t, m)
+			if !reflect.DeepEqual(versions, tc.wantApply) || !reflect.DeepEqual(tables, tc.wantTables) {
+				t.Fatal(tc.name, " expected: ", tc.wantApply, tc.wantTables, ", actual: ", versions, tables)
+			}
+		}
+		if err := m.To(ctx, 4); err == nil {
+			t.Fatal("expected: error for a version that does not exist, actual: nil")
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		broken := &db.Migration{Version: 4, Name: "broken", Up: "CREATE TABLE a (id INTEGER)", Down: "SELECT 1"}
+		m := newMigrator(t, append(migrations[:3:3], broken)...)
+		if err := m.Up(ctx); err == nil {
+			t.Fatal("expected: error, actual: nil")
+		}
+		// the migrations before the broken one are rolled back with it
+		versions, tables := status(t, m)
+		if len(versions) != 0 || len(tables) != 0 {
+			t.Fatal("expected: nothing applied, actual: ", versions, tables)
+		}
+	})
+
+	t.Run("edited", func(t *testing.T) {
+		if err := newMigrator(t, migrations[0]).Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+		edited := *migrations[0]
+		edited.Up = "CREATE TABLE a (id INTEGER, name TEXT)"
+		m := newMigrator(t, &edited, migrations[1])
+
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !statuses[0].Edited || statuses[1].Edited {
+			t.Fatal("expected: migration 1 edited, actual: ", *statuses[0], *statuses[1])
+		}
+		if err := m.Up(ctx); err == nil {
+			t.Fatal("expected: error, actual: nil")
+		}
+		if err := newMigrator(t, migrations...).To(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if err := newMigrator(t, migrations...).Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// an older build keeps the migrations it does not know
+		m := newMigrator(t, migrations[:2]...)
+		if err := m.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 3 || !statuses[2].Unknown || !statuses[2].Applied {
+			t.Fatal("expected: migration 3 unknown, actual: ", statuses)
+		}
+		if err := m.To(ctx, 1); err == nil {
+			t.Fatal("expected: error, actual: nil")
+		}
+		if err := newMigrator(t, migrations...).To(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				other, err := db.Open("sqlite3", path)
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer other.Close()
+				m, err := db.NewMigrator(other, "sqlite3", migrations)
+				if err != nil {
+					errs <- err
+					return
+				}
+				errs <- m.Up(ctx)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var count int
+		if err := todoDB.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != len(migrations) {
+			t.Fatal("expected: ", len(migrations), ", actual: ", count)
+		}
+	})
+}
+
+// baselineSchema is how db/schema.sql set up databases before there were
+// migrations.
+const baselineSchema = `CREATE TABLE IF NOT EXISTS todos (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  subject     TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(subject <> '')
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;`
+
+func TestNewDBMigratesBaseline(t *testing.T) {
+	const path = "../.sqlite3/baseline_test.db"
+	t.Cleanup(func() {
+		if err := os.Remove(path); err != nil {
+			t.Error("failed to cleanup testdata, err =", err)
+		}
+	})
+
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	const insert = `INSERT INTO todos(subject, created_at, updated_at) VALUES(?, '2021-01-01 00:00:00', '2021-01-01 00:00:00')`
+	for _, subject := range []string{"first", "second"} {
+		if _, err := old.Exec(insert, subject); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old.Close()
+
+	todoDB, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	// the TODOs there are get the defaults of the columns added since
+	type row struct {
+		subject, status, position, updatedAt string
+		version                              int64
+	}
+	read := func(id int64) row {
+		t.Helper()
+		var r row
+		err := todoDB.QueryRow(`SELECT subject, status, position, STRFTIME('%Y-%m-%d %H:%M:%S', updated_at), version FROM todos WHERE id = ?`, id).
+			Scan(&r.subject, &r.status, &r.position, &r.updatedAt, &r.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	want := row{subject: "second", status: "open", position: "000000021", updatedAt: "2021-01-01 00:00:00", version: 1}
+	if got := read(2); got != want {
+		t.Fatal("expected: ", want, ", actual: ", got)
+	}
+	if first := read(1); first.position >= want.position {
+		t.Fatal("expected: first before second, actual: ", first.position)
+	}
+
+	// and the trigger of the latest schema
+	if _, err := todoDB.Exec(`UPDATE todos SET subject = 'changed' WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(2); got.version != 2 || got.updatedAt == want.updatedAt {
+		t.Fatal("expected: version 2 updated now, actual: ", got)
+	}
+
+	migrations, err := db.Migrations("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := todoDB.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrations) {
+		t.Fatal("expected: ", len(migrations), ", actual: ", count)
+	}
+}
+
+func TestMigrationsDown(t *testing.T) {
+	const path = "../.sqlite3/down_test.db"
+	t.Cleanup(func() {
+		if err := os.Remove(path); err != nil {
+			t.Error("failed to cleanup testdata, err =", err)
+		}
+	})
+
+	todoDB, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	migrations, err := db.Migrations("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := db.NewMigrator(todoDB, "sqlite3", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every migration is taken back one by one, then applied again
+	for range migrations {
+		if err := m.Down(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the FTS5 index is left to db.NewDB, which rebuilds it
+	var tables int
+	if err := todoDB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence') AND name NOT LIKE 'todos_fts%'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Fatal("expected: no tables, actual: ", tables)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS todos;
+DROP FUNCTION IF EXISTS todos_updated_at();
@@ -0,0 +1,39 @@
+-- The PostgreSQL schema keeps the columns of TODOs that do not refer to
+-- other tables; see service.PostgresTODORepository. Timestamps are stored
+-- in UTC to the second, the way DATETIME('now') does in SQLite.
+
+CREATE TABLE IF NOT EXISTS todos (
+  id           BIGSERIAL NOT NULL PRIMARY KEY,
+  subject      TEXT      NOT NULL,
+  description  TEXT      NOT NULL DEFAULT '',
+  status       TEXT      NOT NULL DEFAULT 'open',
+  completed_at TIMESTAMP,
+  start_at     TIMESTAMP,
+  due_at       TIMESTAMP,
+  time_zone    TEXT      NOT NULL DEFAULT 'UTC',
+  priority     INTEGER   NOT NULL DEFAULT 0,
+  -- ranks compare byte by byte, whatever the collation of the database
+  position     TEXT      COLLATE "C" NOT NULL DEFAULT '',
+  version      BIGINT    NOT NULL DEFAULT 1,
+  created_at   TIMESTAMP NOT NULL DEFAULT DATE_TRUNC('second', NOW() AT TIME ZONE 'UTC'),
+  updated_at   TIMESTAMP NOT NULL DEFAULT DATE_TRUNC('second', NOW() AT TIME ZONE 'UTC'),
+  CHECK(subject <> ''),
+  CHECK(start_at IS NULL OR due_at IS NULL OR start_at <= due_at),
+  CHECK(priority BETWEEN 0 AND 3),
+  CHECK(status IN ('open', 'in_progress', 'done', 'cancelled'))
+);
+
+CREATE INDEX IF NOT EXISTS index_todos_status ON todos(status);
+CREATE INDEX IF NOT EXISTS index_todos_position ON todos(position);
+
+CREATE OR REPLACE FUNCTION todos_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+  NEW.updated_at := DATE_TRUNC('second', NOW() AT TIME ZONE 'UTC');
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_todos_updated_at ON todos;
+CREATE TRIGGER trigger_todos_updated_at BEFORE UPDATE ON todos
+  FOR EACH ROW EXECUTE FUNCTION todos_updated_at();
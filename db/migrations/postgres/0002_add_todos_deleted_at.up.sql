@@ -0,0 +1,4 @@
+-- Deleted TODOs are kept in the trash until they are restored or purged.
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS index_todos_deleted_at ON todos(deleted_at);
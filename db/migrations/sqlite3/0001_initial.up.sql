@@ -1,3 +1,7 @@
+-- The schema the repository started with. Databases set up from it before
+-- there were migrations have it already, hence IF NOT EXISTS; every later
+-- change is a migration of its own.
+
 CREATE TABLE IF NOT EXISTS todos (
   id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
   subject     TEXT     NOT NULL,
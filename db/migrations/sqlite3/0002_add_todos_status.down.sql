@@ -0,0 +1,4 @@
+DROP INDEX index_todos_status;
+
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN status;
@@ -0,0 +1,5 @@
+ALTER TABLE todos ADD COLUMN status TEXT NOT NULL DEFAULT 'open'
+  CHECK(status IN ('open', 'in_progress', 'done', 'cancelled'));
+ALTER TABLE todos ADD COLUMN completed_at DATETIME;
+
+CREATE INDEX index_todos_status ON todos(status);
@@ -0,0 +1,7 @@
+DROP TRIGGER trigger_todos_updated_at;
+CREATE TRIGGER trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+ALTER TABLE todos DROP COLUMN version;
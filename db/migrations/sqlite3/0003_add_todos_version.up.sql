@@ -0,0 +1,7 @@
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+DROP TRIGGER trigger_todos_updated_at;
+CREATE TRIGGER trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now'), version = OLD.version + 1 WHERE id == NEW.id;
+END;
@@ -0,0 +1,4 @@
+DROP INDEX index_todos_project_id;
+ALTER TABLE todos DROP COLUMN project_id;
+
+DROP TABLE projects;
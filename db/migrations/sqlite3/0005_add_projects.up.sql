@@ -0,0 +1,18 @@
+CREATE TABLE projects (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name        TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  archived_at DATETIME,
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER trigger_projects_updated_at AFTER UPDATE ON projects
+BEGIN
+  UPDATE projects SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+ALTER TABLE todos ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+
+CREATE INDEX index_todos_project_id ON todos(project_id);
@@ -0,0 +1,2 @@
+DROP INDEX index_todos_parent_id;
+ALTER TABLE todos DROP COLUMN parent_id;
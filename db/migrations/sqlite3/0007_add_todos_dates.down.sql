@@ -0,0 +1,3 @@
+DROP INDEX index_todos_due_at;
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN start_at;
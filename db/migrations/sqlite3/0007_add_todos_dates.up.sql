@@ -0,0 +1,5 @@
+ALTER TABLE todos ADD COLUMN start_at DATETIME;
+ALTER TABLE todos ADD COLUMN due_at DATETIME
+  CHECK(start_at IS NULL OR due_at IS NULL OR start_at <= due_at);
+
+CREATE INDEX index_todos_due_at ON todos(due_at);
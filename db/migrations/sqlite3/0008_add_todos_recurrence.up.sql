@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE todos ADD COLUMN next_occurrence_id INTEGER REFERENCES todos(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX index_todos_position;
+ALTER TABLE todos DROP COLUMN position;
+ALTER TABLE todos DROP COLUMN priority;
@@ -0,0 +1,15 @@
+ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0
+  CHECK(priority BETWEEN 0 AND 3);
+ALTER TABLE todos ADD COLUMN position TEXT NOT NULL DEFAULT '';
+
+-- the TODOs there are keep their order by id, with ranks that never end in
+-- "0" like the ones of service.rankBetween. The trigger is left out so that
+-- they keep their version and updated_at.
+DROP TRIGGER trigger_todos_updated_at;
+UPDATE todos SET position = printf('%08d1', id);
+CREATE TRIGGER trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now'), version = OLD.version + 1 WHERE id == NEW.id;
+END;
+
+CREATE INDEX index_todos_position ON todos(position);
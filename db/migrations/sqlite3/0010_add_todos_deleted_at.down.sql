@@ -0,0 +1,2 @@
+DROP INDEX index_todos_deleted_at;
+ALTER TABLE todos DROP COLUMN deleted_at;
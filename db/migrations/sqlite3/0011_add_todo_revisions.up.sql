@@ -0,0 +1,11 @@
+CREATE TABLE todo_revisions (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  revision   INTEGER  NOT NULL,
+  action     TEXT     NOT NULL,
+  actor      TEXT     NOT NULL DEFAULT '',
+  data       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(todo_id, revision),
+  CHECK(action IN ('create', 'update', 'delete', 'restore', 'revert'))
+);
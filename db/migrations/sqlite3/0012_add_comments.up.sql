@@ -0,0 +1,16 @@
+CREATE TABLE comments (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  author     TEXT     NOT NULL DEFAULT '',
+  body       TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(body <> '')
+);
+
+CREATE INDEX index_comments_todo_id ON comments(todo_id);
+
+CREATE TRIGGER trigger_comments_updated_at AFTER UPDATE ON comments
+BEGIN
+  UPDATE comments SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
@@ -0,0 +1,13 @@
+CREATE TABLE attachments (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id      INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  name         TEXT     NOT NULL,
+  size         INTEGER  NOT NULL,
+  content_type TEXT     NOT NULL,
+  sha256       TEXT     NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE INDEX index_attachments_todo_id ON attachments(todo_id);
+CREATE INDEX index_attachments_sha256 ON attachments(sha256);
@@ -0,0 +1,9 @@
+CREATE TABLE todo_dependencies (
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  blocker_id INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  PRIMARY KEY (todo_id, blocker_id),
+  CHECK(todo_id <> blocker_id)
+);
+
+CREATE INDEX index_todo_dependencies_blocker_id ON todo_dependencies(blocker_id);
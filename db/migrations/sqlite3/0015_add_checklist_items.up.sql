@@ -0,0 +1,17 @@
+CREATE TABLE checklist_items (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id    INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  text       TEXT     NOT NULL,
+  checked    BOOLEAN  NOT NULL DEFAULT 0,
+  position   TEXT     NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(text <> '')
+);
+
+CREATE INDEX index_checklist_items_todo_id_position ON checklist_items(todo_id, position);
+
+CREATE TRIGGER trigger_checklist_items_updated_at AFTER UPDATE ON checklist_items
+BEGIN
+  UPDATE checklist_items SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
@@ -0,0 +1,2 @@
+DROP TABLE todo_field_values;
+DROP TABLE custom_fields;
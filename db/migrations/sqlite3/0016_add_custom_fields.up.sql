@@ -0,0 +1,23 @@
+CREATE TABLE custom_fields (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name       TEXT     NOT NULL UNIQUE COLLATE NOCASE,
+  type       TEXT     NOT NULL,
+  options    TEXT     NOT NULL DEFAULT '[]',
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> ''),
+  CHECK(type IN ('text', 'number', 'date', 'enum', 'boolean'))
+);
+
+CREATE TABLE todo_field_values (
+  todo_id       INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  field_id      INTEGER NOT NULL REFERENCES custom_fields(id) ON DELETE CASCADE,
+  text_value    TEXT,
+  number_value  REAL,
+  date_value    TEXT,
+  boolean_value BOOLEAN,
+  sort_key      TEXT    NOT NULL,
+  PRIMARY KEY (todo_id, field_id),
+  CHECK((text_value IS NOT NULL) + (number_value IS NOT NULL) + (date_value IS NOT NULL) + (boolean_value IS NOT NULL) = 1)
+);
+
+CREATE INDEX index_todo_field_values_field_id_sort_key ON todo_field_values(field_id, sort_key);
@@ -0,0 +1,4 @@
+-- deleted_root_id is the TODO whose deletion moved this one to the trash:
+-- itself, or the ancestor its subtree was deleted with. Rows trashed before
+-- it are NULL.
+ALTER TABLE todos ADD COLUMN deleted_root_id INTEGER;
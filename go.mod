@@ -5,5 +5,6 @@ go 1.16
 require (
 	github.com/google/go-cmp v0.5.6 // indirect
 	github.com/jstemmer/go-junit-report v0.9.1 // indirect
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.7
 )
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// actorHeader names the user making a request. There is no authentication,
+// so it is taken on trust and only used to attribute changes.
+const actorHeader = "X-User"
+
+// WithActor returns an http.Handler recording the changes made while
+// serving a request as made by the user in its X-User header.
+func WithActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := strings.TrimSpace(r.Header.Get(actorHeader)); actor != "" {
+			r = r.WithContext(service.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
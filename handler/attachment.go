@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// multipartOverhead is allowed on top of the size limit of an upload for
+// the headers and boundaries of the multipart body.
+const multipartOverhead = 64 << 10
+
+// An AttachmentHandler implements handling REST endpoints of the
+// attachments of a TODO, /todos/{id}/attachments. It is served by
+// TODOHandler.
+type AttachmentHandler struct {
+	svc *service.AttachmentService
+}
+
+// NewAttachmentHandler returns AttachmentHandler to pass to
+// TODOHandler.SetAttachmentHandler.
+func NewAttachmentHandler(svc *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		svc: svc,
+	}
+}
+
+// serve handles the endpoints below /todos/{id}/attachments; segments
+// follow "attachments" in the path.
+func (h *AttachmentHandler) serve(w http.ResponseWriter, r *http.Request, todoID int64, segments []string) {
+	if len(segments) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(segments) == 1 {
+		id, err := strconv.ParseInt(segments[0], 10, 64)
+		if err != nil || id <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "GET", "HEAD":
+			h.downloadHandler(w, r, todoID, id)
+		case "DELETE":
+			h.deleteHandler(w, r, todoID, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		h.uploadHandler(w, r, todoID)
+	case "GET":
+		h.readHandler(w, r, todoID)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// uploadHandler streams the part named "file" of a multipart/form-data
+// body to the store, without buffering it in memory.
+func (h *AttachmentHandler) uploadHandler(w http.ResponseWriter, r *http.Request, todoID int64) {
+	limit := h.svc.MaxSize()
+	if r.ContentLength > limit+multipartOverhead {
+		writeError(w, r, &model.ErrTooLarge{Limit: limit})
+		return
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{&limitReader{r: r.Body, n: limit + multipartOverhead, limit: limit}, r.Body}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*model.ErrTooLarge); ok {
+				writeError(w, r, err)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		ret, err := h.svc.CreateAttachment(r.Context(), todoID, part.FileName(), part)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeJSON(w, &model.CreateAttachmentResponse{Attachment: ret})
+		return
+	}
+	http.Error(w, "file not found", http.StatusBadRequest)
+}
+
+func (h *AttachmentHandler) readHandler(w http.ResponseWriter, r *http.Request, todoID int64) {
+	ret, err := h.Read(r.Context(), &model.ReadAttachmentRequest{TODOID: todoID})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// downloadHandler serves the content of the attachment, including the
+// ranges asked for with Range.
+func (h *AttachmentHandler) downloadHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	a, f, err := h.svc.OpenAttachment(r.Context(), todoID, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// the content never changes, so its hash is a strong entity tag
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, a.Name, a.CreatedAt, f)
+}
+
+func (h *AttachmentHandler) deleteHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ret, err := h.Delete(r.Context(), &model.DeleteAttachmentRequest{TODOID: todoID, ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Read handles the endpoint that reads the attachments of a TODO.
+func (h *AttachmentHandler) Read(ctx context.Context, req *model.ReadAttachmentRequest) (*model.ReadAttachmentResponse, error) {
+	ret, err := h.svc.ListAttachment(ctx, req.TODOID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadAttachmentResponse{Attachments: ret}, nil
+}
+
+// Delete handles the endpoint that deletes an attachment.
+func (h *AttachmentHandler) Delete(ctx context.Context, req *model.DeleteAttachmentRequest) (*model.DeleteAttachmentResponse, error) {
+	if err := h.svc.DeleteAttachment(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteAttachmentResponse{}, nil
+}
+
+// A limitReader reads at most n bytes from r and fails with ErrTooLarge
+// after that.
+type limitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, &model.ErrTooLarge{Limit: l.limit}
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
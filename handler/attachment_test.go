@@ -0,0 +1,186 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// multipartFile returns a multipart/form-data body holding content as the
+// file of field, and its Content-Type.
+func multipartFile(t *testing.T, field, filename, content string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(fw, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), mw.FormDataContentType()
+}
+
+func TestAttachment(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoSvc := service.NewTODOService(todoDB)
+	todoHandler := handler.NewTODOHandler(todoSvc)
+	todoHandler.SetAttachmentHandler(handler.NewAttachmentHandler(service.NewAttachmentService(todoDB, t.TempDir(), 32)))
+	ts := httptest.NewServer(todoHandler)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	if _, err := todoSvc.CreateTODO(context.Background(), "foo", ""); err != nil {
+		t.Fatal(err)
+	}
+	file, fileType := multipartFile(t, "file", "notes.txt", "0123456789")
+	large, largeType := multipartFile(t, "file", "large.txt", strings.Repeat("x", 33))
+	other, otherType := multipartFile(t, "other", "notes.txt", "0123456789")
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		header     map[string]string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "upload",
+			method:     "POST",
+			path:       "/todos/1/attachments",
+			header:     map[string]string{"Content-Type": fileType},
+			body:       file,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"notes.txt","size":10,"content_type":"text/plain; charset=utf-8"`,
+		},
+		{
+			name:       "upload too large",
+			method:     "POST",
+			path:       "/todos/1/attachments",
+			header:     map[string]string{"Content-Type": largeType},
+			body:       large,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "upload without file",
+			method:     "POST",
+			path:       "/todos/1/attachments",
+			header:     map[string]string{"Content-Type": otherType},
+			body:       other,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "upload without multipart",
+			method:     "POST",
+			path:       "/todos/1/attachments",
+			header:     map[string]string{"Content-Type": "application/json"},
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "upload to unknown TODO",
+			method:     "POST",
+			path:       "/todos/9999/attachments",
+			header:     map[string]string{"Content-Type": fileType},
+			body:       file,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "list",
+			method:     "GET",
+			path:       "/todos/1/attachments",
+			wantStatus: http.StatusOK,
+			wantBody:   `"attachments":[{"id":1,`,
+		},
+		{
+			name:       "download",
+			method:     "GET",
+			path:       "/todos/1/attachments/1",
+			wantStatus: http.StatusOK,
+			wantBody:   "0123456789",
+		},
+		{
+			name:       "download range",
+			method:     "GET",
+			path:       "/todos/1/attachments/1",
+			header:     map[string]string{"Range": "bytes=2-4"},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "234",
+		},
+		{
+			name:       "download unsatisfiable range",
+			method:     "GET",
+			path:       "/todos/1/attachments/1",
+			header:     map[string]string{"Range": "bytes=20-"},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/todos/1/attachments/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "download deleted",
+			method:     "GET",
+			path:       "/todos/1/attachments/1",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.header {
+				httpReq.Header.Set(k, v)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
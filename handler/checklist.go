@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A ChecklistHandler implements handling REST endpoints of the checklist of
+// a TODO, /todos/{id}/checklist. It is served by TODOHandler.
+type ChecklistHandler struct {
+	svc *service.ChecklistService
+}
+
+// NewChecklistHandler returns ChecklistHandler to pass to
+// TODOHandler.SetChecklistHandler.
+func NewChecklistHandler(svc *service.ChecklistService) *ChecklistHandler {
+	return &ChecklistHandler{
+		svc: svc,
+	}
+}
+
+// serve handles the endpoints below /todos/{id}/checklist; segments follow
+// "checklist" in the path.
+func (h *ChecklistHandler) serve(w http.ResponseWriter, r *http.Request, todoID int64, segments []string) {
+	if len(segments) == 0 {
+		switch r.Method {
+		case "POST":
+			h.createHandler(w, r, todoID)
+		case "GET":
+			h.readHandler(w, r, todoID)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil || id <= 0 || len(segments) > 2 || len(segments) == 2 && segments[1] != "move" {
+		http.NotFound(w, r)
+		return
+	}
+	if len(segments) == 2 {
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.moveHandler(w, r, todoID, id)
+		return
+	}
+
+	switch r.Method {
+	case "PUT", "PATCH":
+		h.updateHandler(w, r, todoID, id)
+	case "DELETE":
+		h.deleteHandler(w, r, todoID, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ChecklistHandler) createHandler(w http.ResponseWriter, r *http.Request, todoID int64) {
+	var reqBody model.CreateChecklistItemRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.TODOID = todoID
+
+	if strings.TrimSpace(reqBody.Text) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Create(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ChecklistHandler) readHandler(w http.ResponseWriter, r *http.Request, todoID int64) {
+	ret, err := h.Read(r.Context(), &model.ReadChecklistRequest{TODOID: todoID})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ChecklistHandler) updateHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	var reqBody model.UpdateChecklistItemRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.TODOID, reqBody.ID = todoID, id
+
+	ret, err := h.Update(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ChecklistHandler) moveHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	var reqBody model.MoveChecklistItemRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.TODOID, reqBody.ID = todoID, id
+
+	ret, err := h.Move(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ChecklistHandler) deleteHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ret, err := h.Delete(r.Context(), &model.DeleteChecklistItemRequest{TODOID: todoID, ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Create handles the endpoint that adds an item to a checklist.
+func (h *ChecklistHandler) Create(ctx context.Context, req *model.CreateChecklistItemRequest) (*model.CreateChecklistItemResponse, error) {
+	ret, err := h.svc.CreateChecklistItem(ctx, req.TODOID, req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateChecklistItemResponse{Item: ret}, nil
+}
+
+// Read handles the endpoint that reads the checklist of a TODO.
+func (h *ChecklistHandler) Read(ctx context.Context, req *model.ReadChecklistRequest) (*model.ReadChecklistResponse, error) {
+	ret, err := h.svc.ListChecklist(ctx, req.TODOID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadChecklistResponse{Items: ret}, nil
+}
+
+// Update handles the endpoint that edits or checks an item.
+func (h *ChecklistHandler) Update(ctx context.Context, req *model.UpdateChecklistItemRequest) (*model.UpdateChecklistItemResponse, error) {
+	ret, err := h.svc.UpdateChecklistItem(ctx, req.TODOID, req.ID, req.Text, req.Checked)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateChecklistItemResponse{Item: ret}, nil
+}
+
+// Move handles the endpoint that reorders an item.
+func (h *ChecklistHandler) Move(ctx context.Context, req *model.MoveChecklistItemRequest) (*model.MoveChecklistItemResponse, error) {
+	ret, err := h.svc.MoveChecklistItem(ctx, req.TODOID, req.ID, req.Before, req.After)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MoveChecklistItemResponse{Item: ret}, nil
+}
+
+// Delete handles the endpoint that removes an item from a checklist.
+func (h *ChecklistHandler) Delete(ctx context.Context, req *model.DeleteChecklistItemRequest) (*model.DeleteChecklistItemResponse, error) {
+	if err := h.svc.DeleteChecklistItem(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteChecklistItemResponse{}, nil
+}
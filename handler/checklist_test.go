@@ -0,0 +1,161 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestChecklist(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoHandler := handler.NewTODOHandler(service.NewTODOService(todoDB))
+	todoHandler.SetChecklistHandler(handler.NewChecklistHandler(service.NewChecklistService(todoDB)))
+	ts := httptest.NewServer(todoHandler)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create TODO",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/todos/1/checklist",
+			body:       `{"text":"milk"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"text":"milk","checked":false`,
+		},
+		{
+			name:       "create another",
+			method:     "POST",
+			path:       "/todos/1/checklist",
+			body:       `{"text":"eggs"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":2,`,
+		},
+		{
+			name:       "create empty",
+			method:     "POST",
+			path:       "/todos/1/checklist",
+			body:       `{"text":""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create on unknown TODO",
+			method:     "POST",
+			path:       "/todos/9999/checklist",
+			body:       `{"text":"milk"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "check",
+			method:     "PATCH",
+			path:       "/todos/1/checklist/1",
+			body:       `{"checked":true}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"text":"milk","checked":true`,
+		},
+		{
+			name:       "progress",
+			method:     "GET",
+			path:       "/todos",
+			wantStatus: http.StatusOK,
+			wantBody:   `"checklist_progress":{"total":2,"done":1}`,
+		},
+		{
+			name:       "move",
+			method:     "POST",
+			path:       "/todos/1/checklist/2/move",
+			body:       `{"before":1}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "read",
+			method:     "GET",
+			path:       "/todos/1/checklist",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"items":[{"id":2,`,
+		},
+		{
+			name:       "move next to unknown",
+			method:     "POST",
+			path:       "/todos/1/checklist/2/move",
+			body:       `{"after":9999}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/todos/1/checklist/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "delete again",
+			method:     "DELETE",
+			path:       "/todos/1/checklist/1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "get move",
+			method:     "GET",
+			path:       "/todos/1/checklist/2/move",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
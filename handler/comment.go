@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A CommentHandler implements handling REST endpoints of the comments of a
+// TODO, /todos/{id}/comments. It is served by TODOHandler.
+type CommentHandler struct {
+	svc *service.CommentService
+}
+
+// NewCommentHandler returns CommentHandler to pass to
+// TODOHandler.SetCommentHandler.
+func NewCommentHandler(svc *service.CommentService) *CommentHandler {
+	return &CommentHandler{
+		svc: svc,
+	}
+}
+
+// serve handles the endpoints below /todos/{id}/comments; segments follow
+// "comments" in the path.
+func (h *CommentHandler) serve(w http.ResponseWriter, r *http.Request, todoID int64, segments []string) {
+	if len(segments) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(segments) == 1 {
+		id, err := strconv.ParseInt(segments[0], 10, 64)
+		if err != nil || id <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "PUT", "PATCH":
+			h.updateHandler(w, r, todoID, id)
+		case "DELETE":
+			h.deleteHandler(w, r, todoID, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		h.createHandler(w, r, todoID)
+	case "GET":
+		h.readHandler(w, r, todoID)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CommentHandler) createHandler(w http.ResponseWriter, r *http.Request, todoID int64) {
+	var reqBody model.CreateCommentRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.TODOID = todoID
+
+	ret, err := h.Create(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *CommentHandler) readHandler(w http.ResponseWriter, r *http.Request, todoID int64) {
+	reqBody := model.ReadCommentRequest{TODOID: todoID}
+	for name, dst := range map[string]*int64{
+		"prev_id": &reqBody.PrevID,
+		"size":    &reqBody.Size,
+	} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("get %s: %v", name, err), http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	ret, err := h.Read(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *CommentHandler) updateHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	var reqBody model.UpdateCommentRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.TODOID, reqBody.ID = todoID, id
+
+	ret, err := h.Update(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *CommentHandler) deleteHandler(w http.ResponseWriter, r *http.Request, todoID, id int64) {
+	ret, err := h.Delete(r.Context(), &model.DeleteCommentRequest{TODOID: todoID, ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Create handles the endpoint that posts a comment.
+func (h *CommentHandler) Create(ctx context.Context, req *model.CreateCommentRequest) (*model.CreateCommentResponse, error) {
+	ret, err := h.svc.CreateComment(ctx, req.TODOID, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateCommentResponse{Comment: ret}, nil
+}
+
+// Read handles the endpoint that reads the comments of a TODO.
+func (h *CommentHandler) Read(ctx context.Context, req *model.ReadCommentRequest) (*model.ReadCommentResponse, error) {
+	return h.svc.ListComment(ctx, req)
+}
+
+// Update handles the endpoint that edits a comment.
+func (h *CommentHandler) Update(ctx context.Context, req *model.UpdateCommentRequest) (*model.UpdateCommentResponse, error) {
+	ret, err := h.svc.UpdateComment(ctx, req.TODOID, req.ID, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateCommentResponse{Comment: ret}, nil
+}
+
+// Delete handles the endpoint that deletes a comment.
+func (h *CommentHandler) Delete(ctx context.Context, req *model.DeleteCommentRequest) (*model.DeleteCommentResponse, error) {
+	if err := h.svc.DeleteComment(ctx, req.TODOID, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteCommentResponse{}, nil
+}
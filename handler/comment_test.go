@@ -0,0 +1,163 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestComment(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoHandler := handler.NewTODOHandler(service.NewTODOService(todoDB))
+	todoHandler.SetCommentHandler(handler.NewCommentHandler(service.NewCommentService(todoDB)))
+	ts := httptest.NewServer(handler.WithActor(todoHandler))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create TODO",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"comment_count":0`,
+		},
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/todos/1/comments",
+			body:       `{"body":"hello"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"author":"alice","body":"hello"`,
+		},
+		{
+			name:       "create another",
+			method:     "POST",
+			path:       "/todos/1/comments",
+			body:       `{"body":"world"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":2,`,
+		},
+		{
+			name:       "create empty",
+			method:     "POST",
+			path:       "/todos/1/comments",
+			body:       `{"body":""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create on unknown TODO",
+			method:     "POST",
+			path:       "/todos/9999/comments",
+			body:       `{"body":"hello"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "read",
+			method:     "GET",
+			path:       "/todos/1/comments?size=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"has_more":true`,
+		},
+		{
+			name:       "read next page",
+			method:     "GET",
+			path:       "/todos/1/comments?prev_id=1&size=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"body":"world"`,
+		},
+		{
+			name:       "count",
+			method:     "GET",
+			path:       "/todos",
+			wantStatus: http.StatusOK,
+			wantBody:   `"comment_count":2`,
+		},
+		{
+			name:       "edit",
+			method:     "PUT",
+			path:       "/todos/1/comments/2",
+			body:       `{"body":"everyone"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"body":"everyone"`,
+		},
+		{
+			name:       "edit unknown",
+			method:     "PUT",
+			path:       "/todos/1/comments/9999",
+			body:       `{"body":"everyone"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/todos/1/comments/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "delete again",
+			method:     "DELETE",
+			path:       "/todos/1/comments/1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "post to a comment",
+			method:     "POST",
+			path:       "/todos/1/comments/2",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpReq.Header.Set("X-User", "alice")
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// serveBlockers handles the endpoints of the TODOs a TODO waits for,
+// /todos/{id}/blockers and /todos/{id}/blockers/{blocker_id}.
+func (h *TODOHandler) serveBlockers(w http.ResponseWriter, r *http.Request, id int64, segments []string) {
+	if len(segments) == 0 {
+		switch r.Method {
+		case "GET":
+			h.blockersHandler(w, r, id)
+		case "POST":
+			h.addBlockerHandler(w, r, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	blockerID, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil || blockerID <= 0 || len(segments) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "DELETE" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	ret, err := h.RemoveBlocker(r.Context(), &model.RemoveBlockerRequest{ID: id, BlockerID: blockerID})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) blockersHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.Blockers(r.Context(), &model.ReadBlockerRequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) addBlockerHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	var reqBody model.AddBlockerRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	if reqBody.BlockerID <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.AddBlocker(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Blockers handles the endpoint that reads the TODOs the TODO waits for.
+func (h *TODOHandler) Blockers(ctx context.Context, req *model.ReadBlockerRequest) (*model.ReadBlockerResponse, error) {
+	ret, err := h.svc.ListBlockers(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadBlockerResponse{TODOs: ret}, nil
+}
+
+// AddBlocker handles the endpoint that makes the TODO wait for another.
+func (h *TODOHandler) AddBlocker(ctx context.Context, req *model.AddBlockerRequest) (*model.AddBlockerResponse, error) {
+	ret, err := h.svc.AddBlocker(ctx, req.ID, req.BlockerID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AddBlockerResponse{TODO: ret}, nil
+}
+
+// RemoveBlocker handles the endpoint that makes the TODO stop waiting for
+// another.
+func (h *TODOHandler) RemoveBlocker(ctx context.Context, req *model.RemoveBlockerRequest) (*model.RemoveBlockerResponse, error) {
+	ret, err := h.svc.RemoveBlocker(ctx, req.ID, req.BlockerID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RemoveBlockerResponse{TODO: ret}, nil
+}
@@ -0,0 +1,166 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestBlockers(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoSvc := service.NewTODOService(todoDB)
+	todoHandler := handler.NewTODOHandler(todoSvc)
+	projectHandler := handler.NewProjectHandler(service.NewProjectService(todoDB), todoSvc)
+	mux := http.NewServeMux()
+	mux.Handle("/projects", projectHandler)
+	mux.Handle("/projects/", projectHandler)
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create project",
+			method:     "POST",
+			path:       "/projects",
+			body:       `{"name":"project"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "create TODO",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"deploy","project_id":1}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"blocked":false`,
+		},
+		{
+			name:       "create blocker",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"build","project_id":1}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "add",
+			method:     "POST",
+			path:       "/todos/1/blockers",
+			body:       `{"id":2}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"blocked":true`,
+		},
+		{
+			name:       "add a cycle",
+			method:     "POST",
+			path:       "/todos/2/blockers",
+			body:       `{"id":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "add unknown",
+			method:     "POST",
+			path:       "/todos/1/blockers",
+			body:       `{"id":9999}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "add to unknown",
+			method:     "POST",
+			path:       "/todos/9999/blockers",
+			body:       `{"id":1}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "read",
+			method:     "GET",
+			path:       "/todos/1/blockers",
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"build"`,
+		},
+		{
+			name:       "topological",
+			method:     "GET",
+			path:       "/projects/1/todos/topological",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"todos":[{"id":2,`,
+		},
+		{
+			name:       "topological of unknown project",
+			method:     "GET",
+			path:       "/projects/9999/todos/topological",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "remove",
+			method:     "DELETE",
+			path:       "/todos/1/blockers/2",
+			wantStatus: http.StatusOK,
+			wantBody:   `"blocked":false`,
+		},
+		{
+			name:       "remove again",
+			method:     "DELETE",
+			path:       "/todos/1/blockers/2",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "get a blocker",
+			method:     "GET",
+			path:       "/todos/1/blockers/2",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag returns the entity tag of a TODO at version.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseETag returns the version encoded in a strong entity tag.
+func parseETag(tag string) (int64, error) {
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return 0, errors.New("malformed entity tag")
+	}
+	return strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+}
+
+// ifMatch returns the version required by the If-Match header, or 0 when
+// any version is acceptable. ok is false when the header can never match,
+// e.g. because it holds a weak or malformed entity tag.
+func ifMatch(r *http.Request) (version int64, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	version, err := parseETag(header)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// ifNoneMatch reports whether the If-None-Match header matches version,
+// using the weak comparison.
+func ifNoneMatch(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		v, err := parseETag(strings.TrimPrefix(tag, "W/"))
+		if err == nil && v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A CustomFieldHandler implements handling REST endpoints of custom fields.
+type CustomFieldHandler struct {
+	svc    *service.CustomFieldService
+	admins map[string]bool
+}
+
+// NewCustomFieldHandler returns CustomFieldHandler based http.Handler.
+func NewCustomFieldHandler(svc *service.CustomFieldService) *CustomFieldHandler {
+	return &CustomFieldHandler{
+		svc: svc,
+	}
+}
+
+// SetAdmins restricts defining fields to the users named, as given in the
+// X-User header. Anyone may define fields until it is set, and anyone may
+// read them.
+//
+// This is advisory, not access control: there is no authentication, so
+// X-User is taken on trust and any client can name an admin in it. It only
+// keeps well-behaved clients from changing fields by mistake.
+func (h *CustomFieldHandler) SetAdmins(admins []string) {
+	h.admins = make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		h.admins[admin] = true
+	}
+}
+
+func (h *CustomFieldHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && h.admins != nil && !h.admins[strings.TrimSpace(r.Header.Get(actorHeader))] {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	segments := splitPath(r.URL.Path, "/fields")
+	if len(segments) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(segments) == 1 {
+		id, err := strconv.ParseInt(segments[0], 10, 64)
+		if err != nil || id <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			h.updateHandler(w, r, id)
+		case "DELETE":
+			h.deleteHandler(w, r, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		h.createHandler(w, r)
+	case "GET":
+		h.readHandler(w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CustomFieldHandler) createHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody model.CreateCustomFieldRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Create(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *CustomFieldHandler) readHandler(w http.ResponseWriter, r *http.Request) {
+	ret, err := h.Read(r.Context(), &model.ReadCustomFieldRequest{})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *CustomFieldHandler) updateHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	var reqBody model.UpdateCustomFieldRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Update(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *CustomFieldHandler) deleteHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.Delete(r.Context(), &model.DeleteCustomFieldRequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Create handles the endpoint that defines the CustomField.
+func (h *CustomFieldHandler) Create(ctx context.Context, req *model.CreateCustomFieldRequest) (*model.CreateCustomFieldResponse, error) {
+	ret, err := h.svc.CreateCustomField(ctx, req.Name, req.Type, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateCustomFieldResponse{Field: ret}, nil
+}
+
+// Read handles the endpoint that reads the CustomFields.
+func (h *CustomFieldHandler) Read(ctx context.Context, req *model.ReadCustomFieldRequest) (*model.ReadCustomFieldResponse, error) {
+	ret, err := h.svc.ReadCustomField(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadCustomFieldResponse{Fields: ret}, nil
+}
+
+// Update handles the endpoint that renames the CustomField and replaces its
+// options.
+func (h *CustomFieldHandler) Update(ctx context.Context, req *model.UpdateCustomFieldRequest) (*model.UpdateCustomFieldResponse, error) {
+	ret, err := h.svc.UpdateCustomField(ctx, req.ID, req.Name, req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateCustomFieldResponse{Field: ret}, nil
+}
+
+// Delete handles the endpoint that deletes the CustomField with its values.
+func (h *CustomFieldHandler) Delete(ctx context.Context, req *model.DeleteCustomFieldRequest) (*model.DeleteCustomFieldResponse, error) {
+	if err := h.svc.DeleteCustomField(ctx, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteCustomFieldResponse{}, nil
+}
@@ -0,0 +1,202 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestCustomField(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoHandler := handler.NewTODOHandler(service.NewTODOService(todoDB))
+	fieldHandler := handler.NewCustomFieldHandler(service.NewCustomFieldService(todoDB))
+	fieldHandler.SetAdmins([]string{"alice"})
+	mux := http.NewServeMux()
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+	mux.Handle("/fields", fieldHandler)
+	mux.Handle("/fields/", fieldHandler)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		user       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/fields",
+			user:       "alice",
+			body:       `{"name":"severity","type":"enum","options":["low","high"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"severity","type":"enum","options":["low","high"]`,
+		},
+		{
+			name:       "create as another user",
+			method:     "POST",
+			path:       "/fields",
+			user:       "bob",
+			body:       `{"name":"points","type":"number"}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "create with invalid type",
+			method:     "POST",
+			path:       "/fields",
+			user:       "alice",
+			body:       `{"name":"points","type":"integer"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create another",
+			method:     "POST",
+			path:       "/fields",
+			user:       "alice",
+			body:       `{"name":"points","type":"number"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "create twice",
+			method:     "POST",
+			path:       "/fields",
+			user:       "alice",
+			body:       `{"name":"Points","type":"number"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "read",
+			method:     "GET",
+			path:       "/fields",
+			user:       "bob",
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"points","type":"number"`,
+		},
+		{
+			name:       "create TODO",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","fields":{"severity":"high","points":3}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"fields":{"points":3,"severity":"high"}`,
+		},
+		{
+			name:       "create another TODO",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"bar","fields":{"points":1}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"fields":{"points":1}`,
+		},
+		{
+			name:       "patch",
+			method:     "PATCH",
+			path:       "/todos/2",
+			body:       `{"fields":{"severity":"low"}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"fields":{"points":1,"severity":"low"}`,
+		},
+		{
+			name:       "patch with invalid value",
+			method:     "PATCH",
+			path:       "/todos/2",
+			body:       `{"fields":{"severity":"medium"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "filter",
+			method:     "GET",
+			path:       "/todos?field.severity=high",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"todos":[{"id":1,`,
+		},
+		{
+			name:       "sort",
+			method:     "GET",
+			path:       "/todos?sort=field.points",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"todos":[{"id":2,`,
+		},
+		{
+			name:       "sort by unknown field",
+			method:     "GET",
+			path:       "/todos?sort=field.size",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "drop an option in use",
+			method:     "PUT",
+			path:       "/fields/1",
+			user:       "alice",
+			body:       `{"name":"severity","options":["high"]}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/fields/1",
+			user:       "alice",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "values deleted",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"fields":{"points":3}`,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.user != "" {
+				httpReq.Header.Set("X-User", tc.user)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
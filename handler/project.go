@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A ProjectHandler implements handling REST endpoints of projects.
+type ProjectHandler struct {
+	svc     *service.ProjectService
+	todoSvc *service.TODOService
+}
+
+// NewProjectHandler returns ProjectHandler based http.Handler.
+func NewProjectHandler(svc *service.ProjectService, todoSvc *service.TODOService) *ProjectHandler {
+	return &ProjectHandler{
+		svc:     svc,
+		todoSvc: todoSvc,
+	}
+}
+
+func (h *ProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path, "/projects")
+	if len(segments) == 0 {
+		switch r.Method {
+		case "POST":
+			h.createHandler(w, r)
+		case "GET":
+			h.readHandler(w, r)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil || id <= 0 || len(segments) > 3 || len(segments) >= 2 && segments[1] != "todos" ||
+		len(segments) == 3 && segments[2] != "topological" {
+		http.NotFound(w, r)
+		return
+	}
+	if len(segments) >= 2 {
+		if r.Method != "GET" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if len(segments) == 3 {
+			h.topologicalHandler(w, r, id)
+			return
+		}
+		h.todosHandler(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.getHandler(w, r, id)
+	case "PUT":
+		h.updateHandler(w, r, id)
+	case "DELETE":
+		h.deleteHandler(w, r, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ProjectHandler) createHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody model.CreateProjectRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Create(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ProjectHandler) readHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody model.ReadProjectRequest
+	if v := r.URL.Query().Get("include_archived"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("get include_archived: %v", err), http.StatusBadRequest)
+			return
+		}
+		reqBody.IncludeArchived = b
+	}
+
+	ret, err := h.Read(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ProjectHandler) getHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.Get(r.Context(), &model.GetProjectRequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ProjectHandler) updateHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	var reqBody model.UpdateProjectRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Update(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ProjectHandler) deleteHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	reqBody := model.DeleteProjectRequest{ID: id, Mode: r.URL.Query().Get("mode")}
+	ret, err := h.Delete(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// todosHandler lists the TODOs of the project with the parameters and
+// pagination of GET /todos.
+func (h *ProjectHandler) todosHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	reqBody, err := parseReadTODORequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody.ProjectID = id
+
+	ret, err := h.ReadTODO(r.Context(), reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if link := pageLinks(r, ret.NextCursor, ret.PrevCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
+	writeJSON(w, ret)
+}
+
+func (h *ProjectHandler) topologicalHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.ReadTopological(r.Context(), &model.ReadTopologicalTODORequest{ProjectID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Create handles the endpoint that creates the Project.
+func (h *ProjectHandler) Create(ctx context.Context, req *model.CreateProjectRequest) (*model.CreateProjectResponse, error) {
+	ret, err := h.svc.CreateProject(ctx, req.Name, req.Description)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateProjectResponse{Project: ret}, nil
+}
+
+// Read handles the endpoint that reads the Projects.
+func (h *ProjectHandler) Read(ctx context.Context, req *model.ReadProjectRequest) (*model.ReadProjectResponse, error) {
+	ret, err := h.svc.ReadProject(ctx, req.IncludeArchived)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadProjectResponse{Projects: ret}, nil
+}
+
+// Get handles the endpoint that reads a single Project.
+func (h *ProjectHandler) Get(ctx context.Context, req *model.GetProjectRequest) (*model.GetProjectResponse, error) {
+	ret, err := h.svc.GetProject(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetProjectResponse{Project: ret}, nil
+}
+
+// Update handles the endpoint that updates the Project.
+func (h *ProjectHandler) Update(ctx context.Context, req *model.UpdateProjectRequest) (*model.UpdateProjectResponse, error) {
+	ret, err := h.svc.UpdateProject(ctx, req.ID, req.Name, req.Description, req.Archived)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateProjectResponse{Project: ret}, nil
+}
+
+// Delete handles the endpoint that archives or deletes the Project.
+func (h *ProjectHandler) Delete(ctx context.Context, req *model.DeleteProjectRequest) (*model.DeleteProjectResponse, error) {
+	if err := h.svc.DeleteProject(ctx, req.ID, req.Mode); err != nil {
+		return nil, err
+	}
+	return &model.DeleteProjectResponse{}, nil
+}
+
+// ReadTODO handles the endpoint that reads the TODOs of the Project.
+func (h *ProjectHandler) ReadTODO(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	if _, err := h.svc.GetProject(ctx, req.ProjectID); err != nil {
+		return nil, err
+	}
+	return h.todoSvc.ListTODO(ctx, req)
+}
+
+// ReadTopological handles the endpoint that reads the open TODOs of the
+// Project, each after the TODOs it waits for.
+func (h *ProjectHandler) ReadTopological(ctx context.Context, req *model.ReadTopologicalTODORequest) (*model.ReadTopologicalTODOResponse, error) {
+	if _, err := h.svc.GetProject(ctx, req.ProjectID); err != nil {
+		return nil, err
+	}
+	ret, err := h.todoSvc.ListTopological(ctx, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadTopologicalTODOResponse{TODOs: ret}, nil
+}
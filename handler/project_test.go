@@ -0,0 +1,177 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestProject(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoSvc := service.NewTODOService(todoDB)
+	projectHandler := handler.NewProjectHandler(service.NewProjectService(todoDB), todoSvc)
+	mux := http.NewServeMux()
+	mux.Handle("/projects", projectHandler)
+	mux.Handle("/projects/", projectHandler)
+	mux.Handle("/todos", handler.NewTODOHandler(todoSvc))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/projects",
+			body:       `{"name":"work"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"work"`,
+		},
+		{
+			name:       "create empty",
+			method:     "POST",
+			path:       "/projects",
+			body:       `{"name":" "}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create TODO in project",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","project_id":1}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"project_id":1`,
+		},
+		{
+			name:       "create TODO in unknown project",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","project_id":2}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "create TODO without project",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"bar"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"project_id":null`,
+		},
+		{
+			name:       "list TODOs of project",
+			method:     "GET",
+			path:       "/projects/1/todos?size=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"foo"`,
+		},
+		{
+			name:       "list TODOs of unknown project",
+			method:     "GET",
+			path:       "/projects/2/todos",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "filter TODOs by project",
+			method:     "GET",
+			path:       "/todos?project_id=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"foo"`,
+		},
+		{
+			name:       "archive",
+			method:     "DELETE",
+			path:       "/projects/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "archived project is hidden",
+			method:     "GET",
+			path:       "/projects",
+			wantStatus: http.StatusOK,
+			wantBody:   `"projects":[]`,
+		},
+		{
+			name:       "archived project is listed on request",
+			method:     "GET",
+			path:       "/projects?include_archived=true",
+			wantStatus: http.StatusOK,
+			wantBody:   `"archived_at":`,
+		},
+		{
+			name:       "unknown delete mode",
+			method:     "DELETE",
+			path:       "/projects/1?mode=purge",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "delete with TODOs",
+			method:     "DELETE",
+			path:       "/projects/1?mode=delete",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "TODOs of deleted project are gone",
+			method:     "GET",
+			path:       "/todos",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":2,`,
+		},
+		{
+			name:       "get deleted",
+			method:     "GET",
+			path:       "/projects/1",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
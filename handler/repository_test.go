@@ -0,0 +1,156 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTODORepository(t *testing.T) {
+	todoHandler := handler.NewTODOHandlerWithRepository(service.NewMemoryTODORepository())
+	mux := http.NewServeMux()
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+	mux.Handle("/tags", handler.NewNotImplementedHandler("tags"))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same repository
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"subject","description":"description"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"todo":{"id":1,"subject":"subject","description":"description","status":"open"`,
+		},
+		{
+			name:       "create with tags",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"subject","tags":["work"]}`,
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "read",
+			method:     "GET",
+			path:       "/todos?size=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":1,`,
+		},
+		{
+			name:       "update",
+			method:     "PUT",
+			path:       "/todos",
+			body:       `{"id":1,"subject":"changed"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"changed"`,
+		},
+		{
+			name:       "patch",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"status":"done"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"done"`,
+		},
+		{
+			name:       "get",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"version":3`,
+		},
+		{
+			name:       "history",
+			method:     "GET",
+			path:       "/todos/1/history",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "unknown subresource",
+			method:     "GET",
+			path:       "/todos/1/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "search",
+			method:     "GET",
+			path:       "/todos?q=changed",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "read by project",
+			method:     "GET",
+			path:       "/todos?project_id=1",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "patch parent",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"parent_id":2}`,
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "tags",
+			method:     "GET",
+			path:       "/tags",
+			wantStatus: http.StatusNotImplemented,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/todos",
+			body:       `{"ids":[1]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "get deleted",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+}
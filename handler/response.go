@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// splitPath returns the path segments following prefix, e.g. "/todos/1"
+// yields ["1"] for "/todos". It returns nil for the collection itself.
+func splitPath(path, prefix string) []string {
+	path = strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// contentType returns the media type of the request body without parameters.
+func contentType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(v); err != nil {
+		log.Print("json encode: ", err)
+		http.Error(w, fmt.Sprintf("json encode: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, buf.String())
+}
+
+// writeError writes the status code matching err.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err.(type) {
+	case *model.ErrNotFound:
+		http.NotFound(w, r)
+	case *model.ErrInvalidTransition, *model.ErrInvalidCursor, *model.ErrInvalidArgument:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case *model.ErrVersionMismatch:
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case *model.ErrConflict:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case *model.ErrTooLarge:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case *model.ErrNotImplemented:
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	default:
+		log.Print(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
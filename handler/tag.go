@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TagHandler implements handling REST endpoints of tags.
+type TagHandler struct {
+	svc *service.TagService
+}
+
+// NewTagHandler returns TagHandler based http.Handler.
+func NewTagHandler(svc *service.TagService) *TagHandler {
+	return &TagHandler{
+		svc: svc,
+	}
+}
+
+func (h *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path, "/tags")
+	if len(segments) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(segments) == 1 {
+		id, err := strconv.ParseInt(segments[0], 10, 64)
+		if err != nil || id <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			h.updateHandler(w, r, id)
+		case "DELETE":
+			h.deleteHandler(w, r, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		h.createHandler(w, r)
+	case "GET":
+		h.readHandler(w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TagHandler) createHandler(w http.ResponseWriter, r *http.Request) {
+	var reqBody model.CreateTagRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Create(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TagHandler) readHandler(w http.ResponseWriter, r *http.Request) {
+	ret, err := h.Read(r.Context(), &model.ReadTagRequest{})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TagHandler) updateHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	var reqBody model.UpdateTagRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	if strings.TrimSpace(reqBody.Name) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Update(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TagHandler) deleteHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.Delete(r.Context(), &model.DeleteTagRequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+// Create handles the endpoint that creates the Tag.
+func (h *TagHandler) Create(ctx context.Context, req *model.CreateTagRequest) (*model.CreateTagResponse, error) {
+	ret, err := h.svc.CreateTag(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreateTagResponse{Tag: ret}, nil
+}
+
+// Read handles the endpoint that reads the Tags.
+func (h *TagHandler) Read(ctx context.Context, req *model.ReadTagRequest) (*model.ReadTagResponse, error) {
+	ret, err := h.svc.ReadTag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadTagResponse{Tags: ret}, nil
+}
+
+// Update handles the endpoint that renames the Tag.
+func (h *TagHandler) Update(ctx context.Context, req *model.UpdateTagRequest) (*model.UpdateTagResponse, error) {
+	ret, err := h.svc.UpdateTag(ctx, req.ID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateTagResponse{Tag: ret}, nil
+}
+
+// Delete handles the endpoint that deletes the Tag.
+func (h *TagHandler) Delete(ctx context.Context, req *model.DeleteTagRequest) (*model.DeleteTagResponse, error) {
+	if err := h.svc.DeleteTag(ctx, req.ID); err != nil {
+		return nil, err
+	}
+	return &model.DeleteTagResponse{}, nil
+}
@@ -0,0 +1,136 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTag(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/tags", handler.NewTagHandler(service.NewTagService(todoDB)))
+	mux.Handle("/tags/", handler.NewTagHandler(service.NewTagService(todoDB)))
+	mux.Handle("/todos", handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/tags",
+			body:       `{"name":"work"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"work"`,
+		},
+		{
+			name:       "create duplicate",
+			method:     "POST",
+			path:       "/tags",
+			body:       `{"name":"Work"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "create empty",
+			method:     "POST",
+			path:       "/tags",
+			body:       `{"name":""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "rename",
+			method:     "PUT",
+			path:       "/tags/1",
+			body:       `{"name":"office"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"office"`,
+		},
+		{
+			name:       "create TODO with tags",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","tags":["office","home"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"tags":["home","office"]`,
+		},
+		{
+			name:       "filter TODOs by tag",
+			method:     "GET",
+			path:       "/todos?tag=home&tag=nothing&tag_mode=all",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[]`,
+		},
+		{
+			name:       "list",
+			method:     "GET",
+			path:       "/tags",
+			wantStatus: http.StatusOK,
+			wantBody:   `"name":"home"`,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/tags/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "delete not found",
+			method:     "DELETE",
+			path:       "/tags/1",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
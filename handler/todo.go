@@ -8,7 +8,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
@@ -16,17 +19,74 @@ import (
 
 // A TODOHandler implements handling REST endpoints.
 type TODOHandler struct {
+	repo service.TODORepository
+	// svc serves everything beyond the TODOs themselves. It is nil unless
+	// repo is a *service.TODOService.
 	svc *service.TODOService
+	// trash restores the TODOs in the trash. It is nil unless repo is a
+	// service.TrashRepository.
+	trash       service.TrashRepository
+	comments    *CommentHandler
+	attachments *AttachmentHandler
+	checklist   *ChecklistHandler
 }
 
 // NewTODOHandler returns TODOHandler based http.Handler.
 func NewTODOHandler(svc *service.TODOService) *TODOHandler {
+	return NewTODOHandlerWithRepository(svc)
+}
+
+// NewTODOHandlerWithRepository returns TODOHandler storing TODOs in repo.
+// Unless repo is a *service.TODOService, only the endpoints of the TODOs
+// themselves are served, along with restore if repo is a
+// service.TrashRepository: search and the other endpoints below a TODO
+// fail with 501 Not Implemented, as does any request using what repo does
+// not store.
+func NewTODOHandlerWithRepository(repo service.TODORepository) *TODOHandler {
+	svc, _ := repo.(*service.TODOService)
+	trash, _ := repo.(service.TrashRepository)
 	return &TODOHandler{
-		svc: svc,
+		repo:  repo,
+		svc:   svc,
+		trash: trash,
 	}
 }
 
+// SetCommentHandler serves the comments of each TODO, /todos/{id}/comments,
+// with comments. They are not served until it is set.
+func (h *TODOHandler) SetCommentHandler(comments *CommentHandler) {
+	h.comments = comments
+}
+
+// SetAttachmentHandler serves the attachments of each TODO,
+// /todos/{id}/attachments, with attachments. They are not served until it
+// is set.
+func (h *TODOHandler) SetAttachmentHandler(attachments *AttachmentHandler) {
+	h.attachments = attachments
+}
+
+// SetChecklistHandler serves the checklist of each TODO,
+// /todos/{id}/checklist, with checklist. It is not served until it is set.
+func (h *TODOHandler) SetChecklistHandler(checklist *ChecklistHandler) {
+	h.checklist = checklist
+}
+
 func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path, "/todos")
+	if len(segments) > 0 {
+		id, err := strconv.ParseInt(segments[0], 10, 64)
+		if err != nil || id <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if len(segments) > 1 {
+			h.serveSubresource(w, r, id, segments[1:])
+			return
+		}
+		h.serveTODO(w, r, id)
+		return
+	}
+
 	switch r.Method {
 	case "POST":
 		h.createHandler(w, r)
@@ -41,16 +101,134 @@ func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// serveTODO handles the endpoints of a single TODO, /todos/{id}.
+func (h *TODOHandler) serveTODO(w http.ResponseWriter, r *http.Request, id int64) {
+	switch r.Method {
+	case "GET":
+		h.getHandler(w, r, id)
+	case "PUT":
+		h.putHandler(w, r, id)
+	case "PATCH":
+		h.patchHandler(w, r, id)
+	case "DELETE":
+		h.deleteOneHandler(w, r, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// todoSubresources are the endpoints below a single TODO, /todos/{id}/...,
+// which only TODOService serves but for restore.
+var todoSubresources = map[string]bool{
+	"comments":    true,
+	"attachments": true,
+	"checklist":   true,
+	"blockers":    true,
+	"children":    true,
+	"subtree":     true,
+	"move":        true,
+	"restore":     true,
+	"history":     true,
+	"diff":        true,
+	"revert":      true,
+}
+
+// serveSubresource handles the endpoints below a single TODO,
+// /todos/{id}/...
+func (h *TODOHandler) serveSubresource(w http.ResponseWriter, r *http.Request, id int64, segments []string) {
+	if segments[0] == "comments" && h.comments != nil {
+		h.comments.serve(w, r, id, segments[1:])
+		return
+	}
+	if segments[0] == "attachments" && h.attachments != nil {
+		h.attachments.serve(w, r, id, segments[1:])
+		return
+	}
+	if segments[0] == "checklist" && h.checklist != nil {
+		h.checklist.serve(w, r, id, segments[1:])
+		return
+	}
+	// the trash is kept by repositories other than TODOService as well
+	restorable := segments[0] == "restore" && h.trash != nil
+	if h.svc == nil && !restorable {
+		if todoSubresources[segments[0]] {
+			http.Error(w, segments[0]+" not supported by this repository", http.StatusNotImplemented)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if segments[0] == "blockers" {
+		h.serveBlockers(w, r, id, segments[1:])
+		return
+	}
+	if len(segments) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch segments[0] {
+	case "children":
+		if r.Method != "GET" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.childrenHandler(w, r, id)
+	case "subtree":
+		if r.Method != "GET" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.subtreeHandler(w, r, id)
+	case "move":
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.moveHandler(w, r, id)
+	case "restore":
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.restoreHandler(w, r, id)
+	case "history":
+		if r.Method != "GET" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.historyHandler(w, r, id)
+	case "diff":
+		if r.Method != "GET" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.diffHandler(w, r, id)
+	case "revert":
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.revertHandler(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
 func (h *TODOHandler) createHandler(w http.ResponseWriter, r *http.Request) {
 	var reqBody model.CreateTODORequest
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&reqBody); err != nil {
-		http.Error(w, fmt.Errorf("json decode: %v", err).Error(), http.StatusInternalServerError)
+		http.Error(w, fmt.Errorf("json decode: %v", err).Error(), http.StatusBadRequest)
 		return
 	}
 
 	ret, err := h.Create(r.Context(), &reqBody)
 	if err != nil {
+		if _, ok := err.(*model.ErrNotImplemented); ok {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
@@ -79,11 +257,28 @@ func (h *TODOHandler) updateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+	reqBody.Version = version
+
 	ret, err := h.Update(r.Context(), &reqBody)
 	if err != nil {
-		http.NotFound(w, r)
+		switch err.(type) {
+		case *model.ErrInvalidTransition, *model.ErrInvalidArgument:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case *model.ErrVersionMismatch:
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		case *model.ErrNotImplemented:
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		default:
+			http.NotFound(w, r)
+		}
 		return
 	}
+	w.Header().Set("ETag", etag(ret.TODO.Version))
 
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -99,28 +294,34 @@ func (h *TODOHandler) updateHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h *TODOHandler) readHandler(w http.ResponseWriter, r *http.Request) {
 	params := r.URL.Query()
-	var reqBody model.ReadTODORequest
-	if params.Get("prev_id") != "" {
-		prevId, err := strconv.Atoi(params.Get("prev_id"))
-		if err != nil {
-			http.Error(w, fmt.Sprintf("get prev_id: %v", err), http.StatusInternalServerError)
-		}
-		reqBody.PrevID = int64(prevId)
+	reqBody, err := parseReadTODORequest(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if params.Get("size") != "" {
-		size, err := strconv.Atoi(params.Get("size"))
-		if err != nil {
-			http.Error(w, fmt.Sprintf("get size: %v", err), http.StatusInternalServerError)
-		}
-		reqBody.Size = int64(size)
+
+	if q := strings.TrimSpace(params.Get("q")); q != "" {
+		h.searchHandler(w, r, &model.SearchTODORequest{Query: q, Size: reqBody.Size})
+		return
 	}
 
-	ret, err := h.Read(r.Context(), &reqBody)
+	ret, err := h.Read(r.Context(), reqBody)
 	if err != nil {
+		switch err.(type) {
+		case *model.ErrInvalidCursor, *model.ErrInvalidArgument:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case *model.ErrNotImplemented:
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
 		log.Print(err)
 		http.NotFound(w, r)
 		return
 	}
+	if link := pageLinks(r, ret.NextCursor, ret.PrevCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
 
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -134,6 +335,94 @@ func (h *TODOHandler) readHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, buf.String())
 }
 
+// parseReadTODORequest reads the list parameters shared by every endpoint
+// listing TODOs.
+func parseReadTODORequest(params url.Values) (*model.ReadTODORequest, error) {
+	var req model.ReadTODORequest
+	for name, dst := range map[string]*int64{
+		"prev_id":    &req.PrevID,
+		"size":       &req.Size,
+		"project_id": &req.ProjectID,
+		"parent_id":  &req.ParentID,
+	} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("get %s: %v", name, err)
+			}
+			*dst = n
+		}
+	}
+	req.Statuses = params["status"]
+	req.SubjectPrefix = params.Get("subject_prefix")
+	req.Sort = params.Get("sort")
+	req.Order = params.Get("order")
+	req.Cursor = params.Get("cursor")
+	req.Tags = params["tag"]
+	req.TagMode = params.Get("tag_mode")
+	req.Due = params.Get("due")
+	for key, values := range params {
+		if strings.HasPrefix(key, "field.") {
+			if req.Fields == nil {
+				req.Fields = map[string][]string{}
+			}
+			req.Fields[strings.TrimPrefix(key, "field.")] = values
+		}
+	}
+	if tz := params.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("get tz: %v", err)
+		}
+		req.Location = loc
+	}
+	for name, dst := range map[string]*time.Time{
+		"created_after":  &req.CreatedAfter,
+		"created_before": &req.CreatedBefore,
+		"updated_after":  &req.UpdatedAfter,
+		"updated_before": &req.UpdatedBefore,
+	} {
+		if v := params.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("get %s: %v", name, err)
+			}
+			*dst = t
+		}
+	}
+	return &req, nil
+}
+
+// pageLinks returns the RFC 8288 Link header value pointing to the pages
+// around the current one, or "" when there are none.
+func pageLinks(r *http.Request, next, prev string) string {
+	var links []string
+	for _, l := range []struct{ rel, cursor string }{{"next", next}, {"prev", prev}} {
+		if l.cursor == "" {
+			continue
+		}
+		params := r.URL.Query()
+		params.Del("prev_id")
+		params.Set("cursor", l.cursor)
+		u := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), l.rel))
+	}
+	return strings.Join(links, ", ")
+}
+
+func (h *TODOHandler) searchHandler(w http.ResponseWriter, r *http.Request, req *model.SearchTODORequest) {
+	if h.svc == nil {
+		http.Error(w, "search is not supported by this repository", http.StatusNotImplemented)
+		return
+	}
+	ret, err := h.Search(r.Context(), req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
 func (h *TODOHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	var reqBody model.DeleteTODORequest
 	dec := json.NewDecoder(r.Body)
@@ -172,13 +461,221 @@ func (h *TODOHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *TODOHandler) getHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.Get(r.Context(), &model.GetTODORequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(ret.TODO.Version))
+	if ifNoneMatch(r, ret.TODO.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) putHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	var reqBody model.UpdateTODORequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	if reqBody.Subject == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+	reqBody.Version = version
+
+	ret, err := h.Update(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(ret.TODO.Version))
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) patchHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	switch contentType(r) {
+	case "", "application/json", "application/merge-patch+json":
+	default:
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var reqBody model.PatchTODORequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	if reqBody.Subject.Set && reqBody.Subject.Value == "" || reqBody.Status.Set && reqBody.Status.Null {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+	reqBody.Version = version
+
+	ret, err := h.Patch(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(ret.TODO.Version))
+	writeJSON(w, ret)
+}
+
+// childrenHandler lists the direct subtasks of the TODO with the parameters
+// and pagination of GET /todos.
+func (h *TODOHandler) childrenHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	reqBody, err := parseReadTODORequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reqBody.ParentID = id
+
+	ret, err := h.ReadChildren(r.Context(), reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if link := pageLinks(r, ret.NextCursor, ret.PrevCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) subtreeHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.GetTree(r.Context(), &model.GetTODOTreeRequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) moveHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	var reqBody model.MoveTODORequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&reqBody); err != nil {
+		http.Error(w, fmt.Sprintf("json decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	reqBody.ID = id
+
+	ret, err := h.Move(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(ret.TODO.Version))
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) restoreHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.Restore(r.Context(), &model.RestoreTODORequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(ret.TODO.Version))
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) historyHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	ret, err := h.History(r.Context(), &model.ReadTODOHistoryRequest{ID: id})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) diffHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	reqBody := model.DiffTODORequest{ID: id}
+	for name, dst := range map[string]*int64{
+		"from": &reqBody.From,
+		"to":   &reqBody.To,
+	} {
+		n, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("get %s: %v", name, err), http.StatusBadRequest)
+			return
+		}
+		*dst = n
+	}
+
+	ret, err := h.Diff(r.Context(), &reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) revertHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	rev, err := strconv.ParseInt(r.URL.Query().Get("rev"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("get rev: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+
+	ret, err := h.Revert(r.Context(), &model.RevertTODORequest{ID: id, Revision: rev, Version: version})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(ret.TODO.Version))
+	writeJSON(w, ret)
+}
+
+func (h *TODOHandler) deleteOneHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	version, ok := ifMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+
+	ret, err := h.Delete(r.Context(), &model.DeleteTODORequest{IDs: []int64{id}, Version: version})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, ret)
+}
+
 // Create handles the endpoint that creates the TODO.
 func (h *TODOHandler) Create(ctx context.Context, req *model.CreateTODORequest) (*model.CreateTODOResponse, error) {
 	if req.Subject == "" {
 		return nil, errors.New("subject empty")
 	}
 
-	ret, err := h.svc.CreateTODO(ctx, req.Subject, req.Description)
+	ret, err := h.repo.CreateTODOFrom(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -187,16 +684,136 @@ func (h *TODOHandler) Create(ctx context.Context, req *model.CreateTODORequest)
 
 // Read handles the endpoint that reads the TODOs.
 func (h *TODOHandler) Read(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
-	ret, err := h.svc.ReadTODO(ctx, req.PrevID, req.Size)
+	return h.repo.ListTODO(ctx, req)
+}
+
+// Search handles the endpoint that searches the TODOs.
+func (h *TODOHandler) Search(ctx context.Context, req *model.SearchTODORequest) (*model.SearchTODOResponse, error) {
+	ret, err := h.svc.SearchTODO(ctx, req.Query, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &model.SearchTODOResponse{Results: ret}, nil
+}
+
+// Get handles the endpoint that reads a single TODO.
+func (h *TODOHandler) Get(ctx context.Context, req *model.GetTODORequest) (*model.GetTODOResponse, error) {
+	ret, err := h.repo.GetTODO(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTODOResponse{TODO: ret}, nil
+}
+
+// ReadChildren handles the endpoint that reads the subtasks of a TODO.
+func (h *TODOHandler) ReadChildren(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	if _, err := h.repo.GetTODO(ctx, req.ParentID); err != nil {
+		return nil, err
+	}
+	return h.repo.ListTODO(ctx, req)
+}
+
+// GetTree handles the endpoint that reads a TODO with all of its subtasks.
+func (h *TODOHandler) GetTree(ctx context.Context, req *model.GetTODOTreeRequest) (*model.GetTODOTreeResponse, error) {
+	ret, err := h.svc.GetTODOTree(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTODOTreeResponse{TODO: ret}, nil
+}
+
+// Move handles the endpoint that reorders the TODO.
+func (h *TODOHandler) Move(ctx context.Context, req *model.MoveTODORequest) (*model.MoveTODOResponse, error) {
+	ret, err := h.svc.MoveTODO(ctx, req.ID, req.Before, req.After)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MoveTODOResponse{TODO: ret}, nil
+}
+
+// Restore handles the endpoint that takes the TODO out of the trash.
+func (h *TODOHandler) Restore(ctx context.Context, req *model.RestoreTODORequest) (*model.RestoreTODOResponse, error) {
+	ret, err := h.trash.RestoreTODO(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RestoreTODOResponse{TODO: ret}, nil
+}
+
+// History handles the endpoint that reads the revisions of the TODO.
+func (h *TODOHandler) History(ctx context.Context, req *model.ReadTODOHistoryRequest) (*model.ReadTODOHistoryResponse, error) {
+	ret, err := h.svc.ReadTODOHistory(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReadTODOHistoryResponse{Revisions: ret}, nil
+}
+
+// Diff handles the endpoint that compares two revisions of the TODO.
+func (h *TODOHandler) Diff(ctx context.Context, req *model.DiffTODORequest) (*model.DiffTODOResponse, error) {
+	ret, err := h.svc.DiffTODO(ctx, req.ID, req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	return &model.DiffTODOResponse{From: req.From, To: req.To, Changes: ret}, nil
+}
+
+// Revert handles the endpoint that brings the TODO back to a revision.
+func (h *TODOHandler) Revert(ctx context.Context, req *model.RevertTODORequest) (*model.RevertTODOResponse, error) {
+	ret, err := h.svc.RevertTODO(ctx, req.ID, req.Revision, req.Version)
 	if err != nil {
 		return nil, err
 	}
-	return &model.ReadTODOResponse{TODOs: ret}, nil
+	return &model.RevertTODOResponse{TODO: ret}, nil
 }
 
 // Update handles the endpoint that updates the TODO.
 func (h *TODOHandler) Update(ctx context.Context, req *model.UpdateTODORequest) (*model.UpdateTODOResponse, error) {
-	ret, err := h.svc.UpdateTODO(ctx, req.ID, req.Subject, req.Description)
+	// a full update is a patch of every member, applied atomically
+	patch := &model.PatchTODORequest{
+		ID:          req.ID,
+		Version:     req.Version,
+		Subject:     model.OptionalString{Set: true, Value: req.Subject},
+		Description: model.OptionalString{Set: true, Value: req.Description},
+	}
+	if req.Status != "" {
+		patch.Status = model.OptionalString{Set: true, Value: req.Status}
+	}
+	if req.Tags != nil {
+		patch.Tags = model.OptionalStrings{Set: true, Value: req.Tags}
+	}
+	if req.ProjectID != nil {
+		patch.ProjectID = model.OptionalInt64{Set: true, Value: *req.ProjectID}
+	}
+	if req.ParentID != nil {
+		patch.ParentID = model.OptionalInt64{Set: true, Value: *req.ParentID}
+	}
+	if req.StartAt != nil {
+		patch.StartAt = model.OptionalTime{Set: true, Value: *req.StartAt}
+	}
+	if req.DueAt != nil {
+		patch.DueAt = model.OptionalTime{Set: true, Value: *req.DueAt}
+	}
+	if req.Recurrence != nil {
+		patch.Recurrence = model.OptionalString{Set: true, Value: *req.Recurrence}
+	}
+	if req.TimeZone != nil {
+		patch.TimeZone = model.OptionalString{Set: true, Value: *req.TimeZone}
+	}
+	if req.Priority != nil {
+		patch.Priority = model.OptionalInt64{Set: true, Value: *req.Priority}
+	}
+	patch.Fields = req.Fields
+	ret, err := h.repo.PatchTODO(ctx, patch)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UpdateTODOResponse{TODO: ret}, nil
+}
+
+// Patch handles the endpoint that partially updates the TODO.
+func (h *TODOHandler) Patch(ctx context.Context, req *model.PatchTODORequest) (*model.UpdateTODOResponse, error) {
+	ret, err := h.repo.PatchTODO(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -205,7 +822,12 @@ func (h *TODOHandler) Update(ctx context.Context, req *model.UpdateTODORequest)
 
 // Delete handles the endpoint that deletes the TODOs.
 func (h *TODOHandler) Delete(ctx context.Context, req *model.DeleteTODORequest) (*model.DeleteTODOResponse, error) {
-	err := h.svc.DeleteTODO(ctx, req.IDs)
+	var err error
+	if len(req.IDs) == 1 && req.Version != 0 {
+		err = h.repo.DeleteTODOIfMatch(ctx, req.IDs[0], req.Version)
+	} else {
+		err = h.repo.DeleteTODO(ctx, req.IDs)
+	}
 	if err != nil {
 		return nil, err
 	}
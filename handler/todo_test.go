@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/TechBowl-japan/go-stations/db"
@@ -269,6 +271,26 @@ func TestUpdate(t *testing.T) {
 			},
 			wantStatus: http.StatusNotFound,
 		},
+		{
+			name: "status",
+			req: model.UpdateTODORequest{
+				ID:      2,
+				Subject: "hello",
+				Status:  model.TODOStatusDone,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			// done only moves back to open, and the subject is not
+			// changed either
+			name: "invalid transition",
+			req: model.UpdateTODORequest{
+				ID:      2,
+				Subject: "lost",
+				Status:  model.TODOStatusInProgress,
+			},
+			wantStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range testcase {
@@ -313,6 +335,14 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 
+	todo, err := service.NewTODOService(todoDB).GetTODO(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Subject != "hello" || todo.Status != model.TODOStatusDone {
+		t.Fatal("expected: hello done, actual: ", todo.Subject, " ", todo.Status)
+	}
+
 	if err := os.Remove(dbpath); err != nil {
 		t.Log(err)
 	}
@@ -403,3 +433,1141 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestTODOItem(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, description) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range init_data {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := httptest.NewServer(handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		wantStatus      int
+		wantSubject     string
+		wantDescription string
+	}{
+		{
+			name:            "get",
+			method:          "GET",
+			path:            "/todos/1",
+			wantStatus:      http.StatusOK,
+			wantSubject:     "foo",
+			wantDescription: "this is foo",
+		},
+		{
+			name:       "get not found",
+			method:     "GET",
+			path:       "/todos/9999",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid id",
+			method:     "GET",
+			path:       "/todos/abc",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:            "put",
+			method:          "PUT",
+			path:            "/todos/1",
+			body:            `{"subject":"hello","description":"update"}`,
+			wantStatus:      http.StatusOK,
+			wantSubject:     "hello",
+			wantDescription: "update",
+		},
+		{
+			name:            "patch subject only",
+			method:          "PATCH",
+			path:            "/todos/1",
+			body:            `{"subject":"patched"}`,
+			wantStatus:      http.StatusOK,
+			wantSubject:     "patched",
+			wantDescription: "update",
+		},
+		{
+			name:            "patch null description",
+			method:          "PATCH",
+			path:            "/todos/1",
+			body:            `{"description":null}`,
+			wantStatus:      http.StatusOK,
+			wantSubject:     "patched",
+			wantDescription: "",
+		},
+		{
+			name:       "patch not an object",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `["subject"]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "patch empty subject",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"subject":""}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "patch invalid transition",
+			method:     "PATCH",
+			path:       "/todos/2",
+			body:       `{"status":"archived"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "patch not found",
+			method:     "PATCH",
+			path:       "/todos/9999",
+			body:       `{"subject":"patched"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "get deleted",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "delete not found",
+			method:     "DELETE",
+			path:       "/todos/1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not allowed method",
+			method:     "POST",
+			path:       "/todos/2",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+			if tc.wantSubject != "" {
+				var resBody model.GetTODOResponse
+				dec := json.NewDecoder(res.Body)
+				if err := dec.Decode(&resBody); err != nil {
+					t.Fatal(err)
+				}
+				if resBody.TODO == nil {
+					t.Fatal("TODO empty")
+				}
+				if resBody.TODO.Subject != tc.wantSubject {
+					t.Fatal("Incorrect handling Subject: ", resBody.TODO.Subject)
+				}
+				if resBody.TODO.Description != tc.wantDescription {
+					t.Fatal("Incorrect handling description: ", resBody.TODO.Description)
+				}
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODOETag(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, description) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range init_data {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := httptest.NewServer(handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		header     http.Header
+		body       string
+		wantStatus int
+		wantETag   string
+	}{
+		{
+			name:       "get",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "get not modified",
+			method:     "GET",
+			path:       "/todos/1",
+			header:     http.Header{"If-None-Match": {`W/"1"`}},
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "put matching",
+			method:     "PUT",
+			path:       "/todos/1",
+			header:     http.Header{"If-Match": {`"1"`}},
+			body:       `{"subject":"hello"}`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"2"`,
+		},
+		{
+			name:       "put stale",
+			method:     "PUT",
+			path:       "/todos/1",
+			header:     http.Header{"If-Match": {`"1"`}},
+			body:       `{"subject":"conflict"}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "put collection stale",
+			method:     "PUT",
+			path:       "/todos",
+			header:     http.Header{"If-Match": {`"1"`}},
+			body:       `{"id":1,"subject":"conflict"}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "patch weak tag",
+			method:     "PATCH",
+			path:       "/todos/1",
+			header:     http.Header{"If-Match": {`W/"2"`}},
+			body:       `{"subject":"conflict"}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "patch any",
+			method:     "PATCH",
+			path:       "/todos/1",
+			header:     http.Header{"If-Match": {`*`}},
+			body:       `{"subject":"patched"}`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"3"`,
+		},
+		{
+			name:       "get modified",
+			method:     "GET",
+			path:       "/todos/1",
+			header:     http.Header{"If-None-Match": {`"1", "2"`}},
+			wantStatus: http.StatusOK,
+			wantETag:   `"3"`,
+		},
+		{
+			name:       "delete stale",
+			method:     "DELETE",
+			path:       "/todos/1",
+			header:     http.Header{"If-Match": {`"2"`}},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "delete matching",
+			method:     "DELETE",
+			path:       "/todos/1",
+			header:     http.Header{"If-Match": {`"3"`}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.header {
+				httpReq.Header[k] = v
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+			if got := res.Header.Get("ETag"); got != tc.wantETag {
+				t.Fatalf("Incorrect ETag: %v", got)
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestReadPagination(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, description) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range init_data {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/todos", handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/todos?size=2&prev_id=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first model.ReadTODOResponse
+	if err := json.NewDecoder(res.Body).Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(first.TODOs) != 2 || !first.HasMore || first.NextCursor == "" || first.PrevCursor == "" {
+		t.Fatalf("Incorrect first page: %+v", first)
+	}
+	wantLink := `</todos?cursor=` + first.NextCursor + `&size=2>; rel="next", </todos?cursor=` + first.PrevCursor + `&size=2>; rel="prev"`
+	if got := res.Header.Get("Link"); got != wantLink {
+		t.Fatalf("Incorrect Link header: %v", got)
+	}
+
+	res, err = http.Get(ts.URL + "/todos?size=2&cursor=" + first.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var second model.ReadTODOResponse
+	if err := json.NewDecoder(res.Body).Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if len(second.TODOs) != 1 || second.TODOs[0].ID != 1 || second.HasMore {
+		t.Fatalf("Incorrect second page: %+v", second)
+	}
+
+	res, err = http.Get(ts.URL + "/todos?cursor=invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Incorrect response status: %v", res.StatusCode)
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestReadInvalidArgument(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/todos", handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	testcase := []struct {
+		name  string
+		query string
+	}{
+		{name: "sort", query: "sort=bogus"},
+		{name: "order", query: "order=up"},
+		{name: "status", query: "status=archived"},
+		{name: "tag mode", query: "tag=a&tag_mode=x"},
+		{name: "prev_id by created_at", query: "prev_id=3&sort=created_at"},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + "/todos?" + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusBadRequest {
+				t.Fatal("expected: ", http.StatusBadRequest, ", actual: ", res.StatusCode)
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODOSubtasks(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ts := httptest.NewServer(handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create parent",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"parent"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"parent_id":null`,
+		},
+		{
+			name:       "create child",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"child","parent_id":1}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"parent_id":1`,
+		},
+		{
+			name:       "create grandchild",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"grandchild","parent_id":2}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "complete grandchild",
+			method:     "PATCH",
+			path:       "/todos/3",
+			body:       `{"status":"done"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "progress",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"progress":{"total":2,"done":1}`,
+		},
+		{
+			name:       "cycle",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"parent_id":3}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "children",
+			method:     "GET",
+			path:       "/todos/1/children",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":2,`,
+		},
+		{
+			name:       "children of unknown",
+			method:     "GET",
+			path:       "/todos/9999/children",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "subtree",
+			method:     "GET",
+			path:       "/todos/1/subtree",
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"child",`,
+		},
+		{
+			name:       "unknown subresource",
+			method:     "GET",
+			path:       "/todos/1/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "delete subtree",
+			method:     "DELETE",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "grandchild is deleted",
+			method:     "GET",
+			path:       "/todos/3",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODODue(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ts := httptest.NewServer(handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create with offset",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","start_at":"2021-01-01T09:00:00+09:00","due_at":"2021-01-02T09:00:00+09:00"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"start_at":"2021-01-01T00:00:00Z","due_at":"2021-01-02T00:00:00Z"`,
+		},
+		{
+			name:       "create without offset",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","due_at":"2021-01-02 09:00:00"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "start after due",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"start_at":"2021-01-03T00:00:00Z"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "overdue",
+			method:     "GET",
+			path:       "/todos?due=overdue&tz=Asia/Tokyo",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":1,`,
+		},
+		{
+			name:       "clear due",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"due_at":null}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"due_at":null`,
+		},
+		{
+			name:       "unknown view",
+			method:     "GET",
+			path:       "/todos?due=month",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown time zone",
+			method:     "GET",
+			path:       "/todos?due=today&tz=Mars/Olympus",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODORecurrence(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ts := httptest.NewServer(handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","due_at":"2021-01-31T09:00:00+09:00","recurrence":"freq=monthly","time_zone":"Asia/Tokyo"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"recurrence":"FREQ=MONTHLY","time_zone":"Asia/Tokyo"`,
+		},
+		{
+			name:       "create with invalid rule",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"foo","recurrence":"FREQ=SECONDLY"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "complete",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"status":"done"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"next_occurrence_id":2`,
+		},
+		{
+			name:       "next occurrence skips February",
+			method:     "GET",
+			path:       "/todos/2",
+			wantStatus: http.StatusOK,
+			wantBody:   `"due_at":"2021-03-31T00:00:00Z"`,
+		},
+		{
+			name:       "stop recurring",
+			method:     "PATCH",
+			path:       "/todos/2",
+			body:       `{"recurrence":null}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"recurrence":""`,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODOMove(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ts := httptest.NewServer(handler.NewTODOHandler(service.NewTODOService(todoDB)))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	for _, subject := range []string{"a", "b", "c"} {
+		res, err := http.Post(ts.URL+"/todos", "application/json", strings.NewReader(`{"subject":"`+subject+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "move before",
+			method:     "POST",
+			path:       "/todos/3/move",
+			body:       `{"before":1}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":3`,
+		},
+		{
+			name:       "list by position",
+			method:     "GET",
+			path:       "/todos?sort=position",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":3,`,
+		},
+		{
+			name:       "move next to unknown",
+			method:     "POST",
+			path:       "/todos/3/move",
+			body:       `{"after":9999}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "move unknown",
+			method:     "POST",
+			path:       "/todos/9999/move",
+			body:       `{"after":1}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "move with GET",
+			method:     "GET",
+			path:       "/todos/3/move",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "set priority",
+			method:     "PATCH",
+			path:       "/todos/2",
+			body:       `{"priority":3}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"priority":3`,
+		},
+		{
+			name:       "list by priority",
+			method:     "GET",
+			path:       "/todos?sort=priority",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":2,`,
+		},
+		{
+			name:       "invalid priority",
+			method:     "PATCH",
+			path:       "/todos/2",
+			body:       `{"priority":5}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODOTrash(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	todoSvc := service.NewTODOService(todoDB)
+	mux := http.NewServeMux()
+	mux.Handle("/todos", handler.NewTODOHandler(todoSvc))
+	mux.Handle("/todos/", handler.NewTODOHandler(todoSvc))
+	mux.Handle("/trash", handler.NewTrashHandler(todoSvc))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	for _, d := range init_data {
+		if _, err := todoSvc.CreateTODO(context.Background(), d.subject, d.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "delete",
+			method:     "DELETE",
+			path:       "/todos",
+			body:       `{"ids":[1,2]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "deleted is gone",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "list without deleted",
+			method:     "GET",
+			path:       "/todos",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":3,`,
+		},
+		{
+			name:       "list trash",
+			method:     "GET",
+			path:       "/trash",
+			wantStatus: http.StatusOK,
+			wantBody:   `"todos":[{"id":2,`,
+		},
+		{
+			name:       "list trash with size",
+			method:     "GET",
+			path:       "/trash?size=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"has_more":true`,
+		},
+		{
+			name:       "restore",
+			method:     "POST",
+			path:       "/todos/1/restore",
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":1,`,
+		},
+		{
+			name:       "restored is back",
+			method:     "GET",
+			path:       "/todos/1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"foo"`,
+		},
+		{
+			name:       "restore not in trash",
+			method:     "POST",
+			path:       "/todos/3/restore",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "restore with GET",
+			method:     "GET",
+			path:       "/todos/2/restore",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "trash with POST",
+			method:     "POST",
+			path:       "/trash",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
+
+func TestTODOHistory(t *testing.T) {
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ts := httptest.NewServer(handler.WithActor(handler.NewTODOHandler(service.NewTODOService(todoDB))))
+	defer ts.Close()
+
+	cli := http.DefaultClient
+
+	// the cases run in order against the same DB
+	testcase := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "create",
+			method:     "POST",
+			path:       "/todos",
+			body:       `{"subject":"draft"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "update",
+			method:     "PATCH",
+			path:       "/todos/1",
+			body:       `{"subject":"final"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"version":2`,
+		},
+		{
+			name:       "history",
+			method:     "GET",
+			path:       "/todos/1/history",
+			wantStatus: http.StatusOK,
+			wantBody:   `"action":"update","actor":"alice"`,
+		},
+		{
+			name:       "history changes",
+			method:     "GET",
+			path:       "/todos/1/history",
+			wantStatus: http.StatusOK,
+			wantBody:   `"changes":[{"field":"subject","old":"draft","new":"final"}]`,
+		},
+		{
+			name:       "diff",
+			method:     "GET",
+			path:       "/todos/1/diff?from=2&to=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"changes":[{"field":"subject","old":"final","new":"draft"}]`,
+		},
+		{
+			name:       "diff without to",
+			method:     "GET",
+			path:       "/todos/1/diff?from=1",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "diff unknown revision",
+			method:     "GET",
+			path:       "/todos/1/diff?from=1&to=9",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "revert",
+			method:     "POST",
+			path:       "/todos/1/revert?rev=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"subject":"draft"`,
+		},
+		{
+			name:       "reverted",
+			method:     "GET",
+			path:       "/todos/1/history",
+			wantStatus: http.StatusOK,
+			wantBody:   `"revision":3,"action":"revert"`,
+		},
+		{
+			name:       "revert without rev",
+			method:     "POST",
+			path:       "/todos/1/revert",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "history of unknown",
+			method:     "GET",
+			path:       "/todos/9999/history",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			httpReq, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpReq.Header.Set("X-User", "alice")
+
+			res, err := cli.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("Incorrect response status: %v", res.StatusCode)
+			}
+
+			var body strings.Builder
+			if _, err := io.Copy(&body, res.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Fatalf("Incorrect response body: %v", body.String())
+			}
+		})
+	}
+
+	if err := os.Remove(dbpath); err != nil {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TrashHandler implements handling REST endpoints of deleted TODOs.
+type TrashHandler struct {
+	repo service.TrashRepository
+}
+
+// NewTrashHandler returns TrashHandler based http.Handler.
+func NewTrashHandler(repo service.TrashRepository) *TrashHandler {
+	return &TrashHandler{
+		repo: repo,
+	}
+}
+
+func (h *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(splitPath(r.URL.Path, "/trash")) > 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	reqBody, err := parseReadTODORequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ret, err := h.Read(r.Context(), reqBody)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if link := pageLinks(r, ret.NextCursor, ret.PrevCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
+	writeJSON(w, ret)
+}
+
+// Read handles the endpoint that reads the TODOs in the trash.
+func (h *TrashHandler) Read(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	req.Trashed = true
+	return h.repo.ListTODO(ctx, req)
+}
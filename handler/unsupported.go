@@ -0,0 +1,20 @@
+package handler
+
+import "net/http"
+
+// A NotImplementedHandler fails every request with 501 Not Implemented,
+// for the endpoints the storage in use does not support.
+type NotImplementedHandler struct {
+	what string
+}
+
+// NewNotImplementedHandler returns NotImplementedHandler based http.Handler
+// refusing what, e.g. "tags".
+func NewNotImplementedHandler(what string) *NotImplementedHandler {
+	return &NotImplementedHandler{what: what}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *NotImplementedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, h.what+" not supported by this repository", http.StatusNotImplemented)
+}
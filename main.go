@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/TechBowl-japan/go-stations/db"
@@ -12,7 +19,12 @@ import (
 )
 
 func main() {
-	err := realMain()
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = migrateMain(os.Args[2:])
+	} else {
+		err = realMain()
+	}
 	if err != nil {
 		log.Fatalln("main: failed to exit successfully, err =", err)
 	}
@@ -21,41 +33,268 @@ func main() {
 func realMain() error {
 	// config values
 	const (
-		defaultPort   = ":8080"
-		defaultDBPath = ".sqlite3/todo.db"
+		defaultPort = ":8080"
+		// attachments are stored next to the DB by default
+		defaultAttachmentDir     = ".sqlite3/attachments"
+		defaultAttachmentMaxSize = 10 << 20
+		// TODOs are kept in the trash for 30 days
+		defaultTrashRetention     = 30 * 24 * time.Hour
+		defaultTrashPurgeInterval = time.Hour
 	)
 
+	// the server is stopped by SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = defaultPort
 	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = defaultDBPath
+	dbDriver, dbSource, err := dbConfig()
+	if err != nil {
+		return err
+	}
+
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	if attachmentDir == "" {
+		attachmentDir = defaultAttachmentDir
+	}
+
+	attachmentMaxSize := int64(defaultAttachmentMaxSize)
+	if v := os.Getenv("ATTACHMENT_MAX_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("ATTACHMENT_MAX_SIZE: must be a positive number of bytes: %q", v)
+		}
+		attachmentMaxSize = n
+	}
+
+	trashRetention, err := durationEnv("TRASH_RETENTION", defaultTrashRetention)
+	if err != nil {
+		return err
+	}
+	trashPurgeInterval, err := durationEnv("TRASH_PURGE_INTERVAL", defaultTrashPurgeInterval)
+	if err != nil {
+		return err
 	}
 
 	// set time zone
-	var err error
 	time.Local, err = time.LoadLocation("Asia/Tokyo")
 	if err != nil {
 		return err
 	}
 
+	if dbDriver == "postgres" {
+		return servePostgres(ctx, port, dbSource, trashRetention, trashPurgeInterval)
+	}
+
 	// set up sqlite3
-	todoDB, err := db.NewDB(dbPath)
+	todoDB, err := db.NewDB(dbSource)
 	if err != nil {
 		return err
 	}
 	defer todoDB.Close()
 
+	if fts, err := db.HasFTS(todoDB); err != nil {
+		return err
+	} else if !fts {
+		log.Print("main: FTS5 is not available, so search results are not ranked; build with -tags sqlite_fts5 (see Makefile)")
+	}
+
 	// set http handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", handler.NewHealthzHandler().ServeHTTP)
-	mux.HandleFunc("/todos", handler.NewTODOHandler(service.NewTODOService(todoDB)).ServeHTTP)
+	todoSvc := service.NewTODOService(todoDB)
+	defer todoSvc.Close()
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		todoSvc.SetCursorSecret([]byte(secret))
+	}
+
+	// the purger and the pruner are stopped and waited for before the
+	// service and the DB they use are closed, including when serve fails
+	var workers sync.WaitGroup
+	defer func() {
+		stop()
+		workers.Wait()
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		todoSvc.RunPurger(ctx, trashRetention, trashPurgeInterval)
+	}()
+
+	todoHandler := handler.NewTODOHandler(todoSvc)
+	todoHandler.SetCommentHandler(handler.NewCommentHandler(service.NewCommentService(todoDB)))
+	attachmentSvc := service.NewAttachmentService(todoDB, attachmentDir, attachmentMaxSize)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		attachmentSvc.RunPruner(ctx, trashPurgeInterval)
+	}()
+	todoHandler.SetAttachmentHandler(handler.NewAttachmentHandler(attachmentSvc))
+	todoHandler.SetChecklistHandler(handler.NewChecklistHandler(service.NewChecklistService(todoDB)))
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+	mux.Handle("/trash", handler.NewTrashHandler(todoSvc))
+
+	tagHandler := handler.NewTagHandler(service.NewTagService(todoDB))
+	mux.Handle("/tags", tagHandler)
+	mux.Handle("/tags/", tagHandler)
+
+	projectHandler := handler.NewProjectHandler(service.NewProjectService(todoDB), todoSvc)
+	mux.Handle("/projects", projectHandler)
+	mux.Handle("/projects/", projectHandler)
+
+	fieldHandler := handler.NewCustomFieldHandler(service.NewCustomFieldService(todoDB))
+	// FIELD_ADMINS is a comma separated list of the users allowed to define
+	// fields. X-User is not authenticated, so it is advisory only
+	if v := os.Getenv("FIELD_ADMINS"); v != "" {
+		var admins []string
+		for _, admin := range strings.Split(v, ",") {
+			if admin = strings.TrimSpace(admin); admin != "" {
+				admins = append(admins, admin)
+			}
+		}
+		fieldHandler.SetAdmins(admins)
+	}
+	mux.Handle("/fields", fieldHandler)
+	mux.Handle("/fields/", fieldHandler)
 
 	// TODO: ここから実装を行う
-	log.Fatal(http.ListenAndServe(port, mux))
+	return serve(ctx, port, handler.WithActor(mux))
+}
+
+// serve serves h on port until ctx is done, then shuts the server down,
+// letting the requests in flight finish first, so that what they use can be
+// closed once serve returns.
+func serve(ctx context.Context, port string, h http.Handler) error {
+	// requests still running this long after ctx is done are cut off
+	const shutdownTimeout = 10 * time.Second
+
+	srv := &http.Server{Addr: port, Handler: h}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// dbConfig reads the DB config values: the driver, and the path of SQLite
+// or the DSN of PostgreSQL.
+func dbConfig() (driver, source string, err error) {
+	const (
+		defaultDBPath = ".sqlite3/todo.db"
+		// DB_DRIVER is sqlite3 or postgres
+		defaultDBDriver = "sqlite3"
+	)
+
+	driver = os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = defaultDBDriver
+	}
+
+	switch driver {
+	case "sqlite3":
+		source = os.Getenv("DB_PATH")
+		if source == "" {
+			source = defaultDBPath
+		}
+	case "postgres":
+		// DB_DSN is the connection string of PostgreSQL, which has no default
+		source = os.Getenv("DB_DSN")
+		if source == "" {
+			return "", "", fmt.Errorf("DB_DSN: must be set for %s", driver)
+		}
+	default:
+		return "", "", fmt.Errorf("DB_DRIVER: must be sqlite3 or postgres: %q", driver)
+	}
+	return driver, source, nil
+}
+
+// servePostgres serves the TODOs stored in the PostgreSQL database of dsn.
+// Only the columns of TODOs and the trash are stored there so far: /todos,
+// /trash and /healthz are served, purging the trash the way the SQLite
+// server does, and the rest fails with 501 Not Implemented.
+func servePostgres(ctx context.Context, port, dsn string, trashRetention, trashPurgeInterval time.Duration) error {
+	todoDB, err := db.NewPostgresDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer todoDB.Close()
+
+	repo := service.NewPostgresTODORepository(todoDB)
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		repo.SetCursorSecret([]byte(secret))
+	}
+
+	// the purger is stopped and waited for before the DB is closed
+	ctx, stop := context.WithCancel(ctx)
+	var purger sync.WaitGroup
+	defer func() {
+		stop()
+		purger.Wait()
+	}()
+	purger.Add(1)
+	go func() {
+		defer purger.Done()
+		repo.RunPurger(ctx, trashRetention, trashPurgeInterval)
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handler.NewHealthzHandler().ServeHTTP)
+	todoHandler := handler.NewTODOHandlerWithRepository(repo)
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+	mux.Handle("/trash", handler.NewTrashHandler(repo))
+	for _, what := range []string{"tags", "projects", "fields"} {
+		h := handler.NewNotImplementedHandler(what)
+		mux.Handle("/"+what, h)
+		mux.Handle("/"+what+"/", h)
+	}
+
+	return serve(ctx, port, handler.WithActor(mux))
+}
+
+// durationEnv reads the environment variable key as a time.Duration such as
+// "720h", falling back to def when it is not set.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s: must be positive", key)
+	}
+	return d, nil
+}
+
+// migrateMain runs the migrate subcommand of args on the DB the server
+// would use, then prints the status of the migrations.
+func migrateMain(args []string) error {
+	driver, source, err := dbConfig()
+	if err != nil {
+		return err
+	}
+	todoDB, err := db.Open(driver, source)
+	if err != nil {
+		return err
+	}
+	defer todoDB.Close()
 
-	return nil
+	return db.RunMigrate(context.Background(), todoDB, driver, args, os.Stdout)
 }
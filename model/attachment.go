@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+type (
+	// An Attachment is a file attached to a TODO. Its content is stored
+	// once per SHA256, however many attachments share it.
+	Attachment struct {
+		ID          int64     `json:"id"`
+		TODOID      int64     `json:"todo_id"`
+		Name        string    `json:"name"`
+		Size        int64     `json:"size"`
+		ContentType string    `json:"content_type"`
+		SHA256      string    `json:"sha256"`
+		CreatedAt   time.Time `json:"created_at"`
+	}
+
+	// A CreateAttachmentResponse expresses ...
+	CreateAttachmentResponse struct {
+		Attachment *Attachment `json:"attachment"`
+	}
+
+	// A ReadAttachmentRequest expresses ...
+	ReadAttachmentRequest struct {
+		TODOID int64
+	}
+	// A ReadAttachmentResponse expresses ...
+	ReadAttachmentResponse struct {
+		Attachments []*Attachment `json:"attachments"`
+	}
+
+	// A DeleteAttachmentRequest expresses ...
+	DeleteAttachmentRequest struct {
+		TODOID int64
+		ID     int64
+	}
+	// A DeleteAttachmentResponse expresses ...
+	DeleteAttachmentResponse struct{}
+)
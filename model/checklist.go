@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+type (
+	// A ChecklistItem is a step of a TODO too small to be a subtask.
+	ChecklistItem struct {
+		ID      int64  `json:"id"`
+		TODOID  int64  `json:"todo_id"`
+		Text    string `json:"text"`
+		Checked bool   `json:"checked"`
+		// Position ranks the item in its checklist; ranks compare byte by
+		// byte.
+		Position  string    `json:"position"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A CreateChecklistItemRequest adds an item at the end of the checklist
+	// of a TODO.
+	CreateChecklistItemRequest struct {
+		TODOID int64  `json:"-"`
+		Text   string `json:"text"`
+	}
+	// A CreateChecklistItemResponse expresses ...
+	CreateChecklistItemResponse struct {
+		Item *ChecklistItem `json:"item"`
+	}
+
+	// A ReadChecklistRequest expresses ...
+	ReadChecklistRequest struct {
+		TODOID int64
+	}
+	// A ReadChecklistResponse expresses ...
+	ReadChecklistResponse struct {
+		Items []*ChecklistItem `json:"items"`
+	}
+
+	// A UpdateChecklistItemRequest changes the members of an item that are
+	// not nil.
+	UpdateChecklistItemRequest struct {
+		TODOID  int64   `json:"-"`
+		ID      int64   `json:"-"`
+		Text    *string `json:"text"`
+		Checked *bool   `json:"checked"`
+	}
+	// A UpdateChecklistItemResponse expresses ...
+	UpdateChecklistItemResponse struct {
+		Item *ChecklistItem `json:"item"`
+	}
+
+	// A MoveChecklistItemRequest places an item right before or after
+	// another one of the same checklist. Exactly one of Before and After is
+	// set.
+	MoveChecklistItemRequest struct {
+		TODOID int64 `json:"-"`
+		ID     int64 `json:"-"`
+		Before int64 `json:"before,omitempty"`
+		After  int64 `json:"after,omitempty"`
+	}
+	// A MoveChecklistItemResponse expresses ...
+	MoveChecklistItemResponse struct {
+		Item *ChecklistItem `json:"item"`
+	}
+
+	// A DeleteChecklistItemRequest expresses ...
+	DeleteChecklistItemRequest struct {
+		TODOID int64
+		ID     int64
+	}
+	// A DeleteChecklistItemResponse expresses ...
+	DeleteChecklistItemResponse struct{}
+)
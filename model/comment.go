@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+type (
+	// A Comment is a message posted on a TODO.
+	Comment struct {
+		ID        int64     `json:"id"`
+		TODOID    int64     `json:"todo_id"`
+		Author    string    `json:"author"`
+		Body      string    `json:"body"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A CreateCommentRequest expresses ...
+	CreateCommentRequest struct {
+		TODOID int64  `json:"-"`
+		Body   string `json:"body"`
+	}
+	// A CreateCommentResponse expresses ...
+	CreateCommentResponse struct {
+		Comment *Comment `json:"comment"`
+	}
+
+	// A ReadCommentRequest reads the comments of a TODO oldest first. Only
+	// comments after PrevID are read, at most Size of them unless 0.
+	ReadCommentRequest struct {
+		TODOID int64
+		PrevID int64
+		Size   int64
+	}
+	// A ReadCommentResponse expresses ...
+	ReadCommentResponse struct {
+		Comments []*Comment `json:"comments"`
+		// HasMore reports whether there are comments after this page.
+		HasMore bool `json:"has_more"`
+	}
+
+	// A UpdateCommentRequest expresses ...
+	UpdateCommentRequest struct {
+		TODOID int64  `json:"-"`
+		ID     int64  `json:"-"`
+		Body   string `json:"body"`
+	}
+	// A UpdateCommentResponse expresses ...
+	UpdateCommentResponse struct {
+		Comment *Comment `json:"comment"`
+	}
+
+	// A DeleteCommentRequest expresses ...
+	DeleteCommentRequest struct {
+		TODOID int64
+		ID     int64
+	}
+	// A DeleteCommentResponse expresses ...
+	DeleteCommentResponse struct{}
+)
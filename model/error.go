@@ -1,5 +1,7 @@
 package model
 
+import "fmt"
+
 type ErrNotFound struct {
 	What string
 }
@@ -7,3 +9,72 @@ type ErrNotFound struct {
 func (e *ErrNotFound) Error() string {
 	return e.What
 }
+
+// An ErrInvalidTransition is returned when a TODO is not allowed to move
+// from its current status to the requested one.
+type ErrInvalidTransition struct {
+	From string
+	To   string
+}
+
+func (e *ErrInvalidTransition) Error() string {
+	return fmt.Sprintf("invalid status transition: %q -> %q", e.From, e.To)
+}
+
+// An ErrVersionMismatch is returned when a TODO is not at the version the
+// caller expected, i.e. someone else changed it in the meantime.
+type ErrVersionMismatch struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *ErrVersionMismatch) Error() string {
+	return fmt.Sprintf("version mismatch: expected %d, actual %d", e.Expected, e.Actual)
+}
+
+// An ErrInvalidCursor is returned for a pagination cursor that was not
+// issued by this server or does not fit the request.
+type ErrInvalidCursor struct{}
+
+func (e *ErrInvalidCursor) Error() string {
+	return "invalid cursor"
+}
+
+// An ErrConflict is returned when an entity clashes with an existing one,
+// e.g. a tag of the same name.
+type ErrConflict struct {
+	What string
+}
+
+func (e *ErrConflict) Error() string {
+	return e.What
+}
+
+// An ErrInvalidArgument is returned when a request is malformed, e.g. a
+// required field is empty or refers to something that does not exist.
+type ErrInvalidArgument struct {
+	What string
+}
+
+func (e *ErrInvalidArgument) Error() string {
+	return e.What
+}
+
+// An ErrNotImplemented is returned for a feature the storage in use does
+// not support, e.g. tags of TODOs stored in PostgreSQL.
+type ErrNotImplemented struct {
+	What string
+}
+
+func (e *ErrNotImplemented) Error() string {
+	return e.What
+}
+
+// An ErrTooLarge is returned when an upload exceeds the size limit.
+type ErrTooLarge struct {
+	Limit int64
+}
+
+func (e *ErrTooLarge) Error() string {
+	return fmt.Sprintf("too large: the limit is %d bytes", e.Limit)
+}
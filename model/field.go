@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// Types of custom fields.
+const (
+	FieldTypeText    = "text"
+	FieldTypeNumber  = "number"
+	FieldTypeDate    = "date"
+	FieldTypeEnum    = "enum"
+	FieldTypeBoolean = "boolean"
+)
+
+type (
+	// A CustomField is a typed member every TODO can have a value for,
+	// defined at runtime. Values of an enum field are one of its Options.
+	CustomField struct {
+		ID        int64     `json:"id"`
+		Name      string    `json:"name"`
+		Type      string    `json:"type"`
+		Options   []string  `json:"options,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// A CreateCustomFieldRequest expresses ...
+	CreateCustomFieldRequest struct {
+		Name    string   `json:"name"`
+		Type    string   `json:"type"`
+		Options []string `json:"options"`
+	}
+	// A CreateCustomFieldResponse expresses ...
+	CreateCustomFieldResponse struct {
+		Field *CustomField `json:"field"`
+	}
+
+	// A ReadCustomFieldRequest expresses ...
+	ReadCustomFieldRequest struct{}
+	// A ReadCustomFieldResponse expresses ...
+	ReadCustomFieldResponse struct {
+		Fields []*CustomField `json:"fields"`
+	}
+
+	// A UpdateCustomFieldRequest renames a field and replaces its options.
+	// The type of a field cannot be changed.
+	UpdateCustomFieldRequest struct {
+		ID      int64    `json:"-"`
+		Name    string   `json:"name"`
+		Options []string `json:"options"`
+	}
+	// A UpdateCustomFieldResponse expresses ...
+	UpdateCustomFieldResponse struct {
+		Field *CustomField `json:"field"`
+	}
+
+	// A DeleteCustomFieldRequest expresses ...
+	DeleteCustomFieldRequest struct {
+		ID int64
+	}
+	// A DeleteCustomFieldResponse expresses ...
+	DeleteCustomFieldResponse struct{}
+)
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// An OptionalString is a string member of a JSON Merge Patch (RFC 7396)
+// document. Set reports whether the member was present at all and Null
+// whether it was explicitly null.
+type OptionalString struct {
+	Set   bool
+	Null  bool
+	Value string
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It is only called
+// for members present in the document, including null ones.
+func (s *OptionalString) UnmarshalJSON(b []byte) error {
+	s.Set = true
+	if string(b) == "null" {
+		s.Null = true
+		s.Value = ""
+		return nil
+	}
+	s.Null = false
+	return json.Unmarshal(b, &s.Value)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (s OptionalString) MarshalJSON() ([]byte, error) {
+	if s.Null || !s.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.Value)
+}
+
+// An OptionalStrings is a string array member of a JSON Merge Patch
+// document. As with any array in a merge patch, a present member replaces
+// the whole array.
+type OptionalStrings struct {
+	Set   bool
+	Null  bool
+	Value []string
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (s *OptionalStrings) UnmarshalJSON(b []byte) error {
+	s.Set = true
+	if string(b) == "null" {
+		s.Null = true
+		s.Value = nil
+		return nil
+	}
+	s.Null = false
+	return json.Unmarshal(b, &s.Value)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (s OptionalStrings) MarshalJSON() ([]byte, error) {
+	if s.Null || !s.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.Value)
+}
+
+// An OptionalInt64 is an integer member of a JSON Merge Patch document.
+type OptionalInt64 struct {
+	Set   bool
+	Null  bool
+	Value int64
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (i *OptionalInt64) UnmarshalJSON(b []byte) error {
+	i.Set = true
+	if string(b) == "null" {
+		i.Null = true
+		i.Value = 0
+		return nil
+	}
+	i.Null = false
+	return json.Unmarshal(b, &i.Value)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (i OptionalInt64) MarshalJSON() ([]byte, error) {
+	if i.Null || !i.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(i.Value)
+}
+
+// An OptionalTime is an RFC 3339 date-time member of a JSON Merge Patch
+// document.
+type OptionalTime struct {
+	Set   bool
+	Null  bool
+	Value time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (t *OptionalTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	if string(b) == "null" {
+		t.Null = true
+		t.Value = time.Time{}
+		return nil
+	}
+	t.Null = false
+	return json.Unmarshal(b, &t.Value)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (t OptionalTime) MarshalJSON() ([]byte, error) {
+	if t.Null || !t.Set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Value)
+}
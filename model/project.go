@@ -0,0 +1,87 @@
+package model
+
+import "time"
+
+// Modes of deleting a project.
+const (
+	// ProjectDeleteArchive hides the project but keeps it and its TODOs.
+	ProjectDeleteArchive = "archive"
+	// ProjectDeleteCascade deletes the project and moves its TODOs to the
+	// trash.
+	ProjectDeleteCascade = "delete"
+	// ProjectDeleteDetach deletes the project and keeps its TODOs without
+	// a project.
+	ProjectDeleteDetach = "detach"
+)
+
+type (
+	// A Project is a named list TODOs are grouped by.
+	Project struct {
+		ID          int64      `json:"id"`
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   time.Time  `json:"updated_at"`
+	}
+
+	// A CreateProjectRequest expresses ...
+	CreateProjectRequest struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	// A CreateProjectResponse expresses ...
+	CreateProjectResponse struct {
+		Project *Project `json:"project"`
+	}
+
+	// A ReadProjectRequest expresses ...
+	ReadProjectRequest struct {
+		IncludeArchived bool
+	}
+	// A ReadProjectResponse expresses ...
+	ReadProjectResponse struct {
+		Projects []*Project `json:"projects"`
+	}
+
+	// A GetProjectRequest expresses ...
+	GetProjectRequest struct {
+		ID int64
+	}
+	// A GetProjectResponse expresses ...
+	GetProjectResponse struct {
+		Project *Project `json:"project"`
+	}
+
+	// A UpdateProjectRequest expresses ...
+	UpdateProjectRequest struct {
+		ID          int64  `json:"-"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		// Archived archives or unarchives the project unless nil.
+		Archived *bool `json:"archived,omitempty"`
+	}
+	// A UpdateProjectResponse expresses ...
+	UpdateProjectResponse struct {
+		Project *Project `json:"project"`
+	}
+
+	// A ReadTopologicalTODORequest expresses ...
+	ReadTopologicalTODORequest struct {
+		ProjectID int64
+	}
+	// A ReadTopologicalTODOResponse lists TODOs so that every TODO comes
+	// after its blockers.
+	ReadTopologicalTODOResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+
+	// A DeleteProjectRequest expresses ...
+	DeleteProjectRequest struct {
+		ID int64 `json:"-"`
+		// Mode is one of archive, delete or detach. It defaults to archive.
+		Mode string `json:"-"`
+	}
+	// A DeleteProjectResponse expresses ...
+	DeleteProjectResponse struct{}
+)
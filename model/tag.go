@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+type (
+	// A Tag is a label TODOs can be organised by.
+	Tag struct {
+		ID        int64     `json:"id"`
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// A CreateTagRequest expresses ...
+	CreateTagRequest struct {
+		Name string `json:"name"`
+	}
+	// A CreateTagResponse expresses ...
+	CreateTagResponse struct {
+		Tag *Tag `json:"tag"`
+	}
+
+	// A ReadTagRequest expresses ...
+	ReadTagRequest struct{}
+	// A ReadTagResponse expresses ...
+	ReadTagResponse struct {
+		Tags []*Tag `json:"tags"`
+	}
+
+	// A UpdateTagRequest expresses ...
+	UpdateTagRequest struct {
+		ID   int64  `json:"-"`
+		Name string `json:"name"`
+	}
+	// A UpdateTagResponse expresses ...
+	UpdateTagResponse struct {
+		Tag *Tag `json:"tag"`
+	}
+
+	// A DeleteTagRequest expresses ...
+	DeleteTagRequest struct {
+		ID int64 `json:"-"`
+	}
+	// A DeleteTagResponse expresses ...
+	DeleteTagResponse struct{}
+)
@@ -2,20 +2,112 @@ package model
 
 import "time"
 
+// Statuses a TODO can be in.
+const (
+	TODOStatusOpen       = "open"
+	TODOStatusInProgress = "in_progress"
+	TODOStatusDone       = "done"
+	TODOStatusCancelled  = "cancelled"
+)
+
+// Priorities of a TODO, from lowest to highest.
+const (
+	TODOPriorityNone   = 0
+	TODOPriorityLow    = 1
+	TODOPriorityMedium = 2
+	TODOPriorityHigh   = 3
+)
+
+// Views of TODOs by due date.
+const (
+	// TODODueOverdue is every TODO past its due date that is neither done
+	// nor cancelled.
+	TODODueOverdue = "overdue"
+	// TODODueToday is every TODO due on the current day.
+	TODODueToday = "today"
+	// TODODueWeek is every TODO due in the current week, from Monday to
+	// Sunday.
+	TODODueWeek = "week"
+)
+
+// Actions recorded in the revision history of a TODO.
+const (
+	TODOActionCreate  = "create"
+	TODOActionUpdate  = "update"
+	TODOActionDelete  = "delete"
+	TODOActionRestore = "restore"
+	TODOActionRevert  = "revert"
+)
+
 type (
 	// A TODO expresses ...
 	TODO struct {
-		ID          int64     `json:"id"`
-		Subject     string    `json:"subject"`
-		Description string    `json:"description"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		ID          int64      `json:"id"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		Status      string     `json:"status"`
+		CompletedAt *time.Time `json:"completed_at,omitempty"`
+		ProjectID   *int64     `json:"project_id"`
+		ParentID    *int64     `json:"parent_id"`
+		StartAt     *time.Time `json:"start_at"`
+		DueAt       *time.Time `json:"due_at"`
+		// Recurrence is an RRULE of RFC 5545 evaluated in TimeZone. When
+		// the TODO is done, its next occurrence is created as a new TODO.
+		Recurrence       string `json:"recurrence"`
+		TimeZone         string `json:"time_zone"`
+		NextOccurrenceID *int64 `json:"next_occurrence_id,omitempty"`
+		Priority         int64  `json:"priority"`
+		// Position is the rank of the TODO in manual order. Ranks compare
+		// byte by byte.
+		Position string `json:"position"`
+		// DeletedAt is set while the TODO is in the trash.
+		DeletedAt *time.Time `json:"deleted_at,omitempty"`
+		Version   int64      `json:"version"`
+		Tags      []string   `json:"tags"`
+		// Fields holds the values of custom fields by field name: strings
+		// for text, enum and date (YYYY-MM-DD) fields, numbers and booleans.
+		Fields map[string]interface{} `json:"fields"`
+		// Blocked reports whether the TODO waits for a blocker that is
+		// neither done nor cancelled.
+		Blocked bool `json:"blocked"`
+		// CommentCount is the number of comments posted on the TODO.
+		CommentCount int64 `json:"comment_count"`
+		// Progress is nil unless the TODO has subtasks that are not cancelled.
+		Progress *TODOProgress `json:"progress,omitempty"`
+		// ChecklistProgress is nil unless the TODO has checklist items; Done
+		// counts the checked ones.
+		ChecklistProgress *TODOProgress `json:"checklist_progress,omitempty"`
+		CreatedAt         time.Time     `json:"created_at"`
+		UpdatedAt         time.Time     `json:"updated_at"`
+	}
+
+	// A TODOProgress is the completion of the subtasks of a TODO, however
+	// deep, leaving out cancelled ones, or of its checklist.
+	TODOProgress struct {
+		Total int64 `json:"total"`
+		Done  int64 `json:"done"`
+	}
+
+	// A TODONode is a TODO with its subtasks.
+	TODONode struct {
+		*TODO
+		Children []*TODONode `json:"children"`
 	}
 
 	// A CreateTODORequest expresses ...
 	CreateTODORequest struct {
-		Subject     string `json:"subject"`
-		Description string `json:"description"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		Tags        []string   `json:"tags,omitempty"`
+		ProjectID   *int64     `json:"project_id,omitempty"`
+		ParentID    *int64     `json:"parent_id,omitempty"`
+		StartAt     *time.Time `json:"start_at,omitempty"`
+		DueAt       *time.Time `json:"due_at,omitempty"`
+		Recurrence  string     `json:"recurrence,omitempty"`
+		TimeZone    string     `json:"time_zone,omitempty"`
+		Priority    int64      `json:"priority,omitempty"`
+		// Fields holds the values of custom fields by field name.
+		Fields map[string]interface{} `json:"fields,omitempty"`
 	}
 	// A CreateTODOResponse expresses ...
 	CreateTODOResponse struct {
@@ -23,13 +115,83 @@ type (
 	}
 
 	// A ReadTODORequest expresses ...
-	ReadTODORequest struct{
-		PrevID int64
-		Size   int64
+	ReadTODORequest struct {
+		PrevID        int64
+		Size          int64
+		Statuses      []string
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+		UpdatedAfter  time.Time
+		UpdatedBefore time.Time
+		SubjectPrefix string
+		ProjectID     int64
+		ParentID      int64
+		// Due is one of overdue, today or week. The current day and week
+		// are those of Location, which defaults to UTC.
+		Due      string
+		Location *time.Location
+		// Tags filters by tag names. With TagMode "all" a TODO must have
+		// every tag, otherwise any of them.
+		Tags    []string
+		TagMode string
+		// Fields filters by custom field values given as in a query string.
+		// A TODO must have one of the values of every field.
+		Fields map[string][]string
+		// Sort is one of id, created_at, updated_at, position, priority or
+		// field.<name> and Order is asc or desc. They default to id and
+		// desc, but position, priority and fields default to asc. priority
+		// sorts from high to low priority, then by position. TODOs without a
+		// value of a field sort before the others.
+		Sort  string
+		Order string
+		// Cursor is a next_cursor or prev_cursor of a previous response.
+		Cursor string
+		// Trashed lists the TODOs in the trash instead of the others.
+		Trashed bool
 	}
 	// A ReadTODOResponse expresses ...
-	ReadTODOResponse struct{
-		TODOs []*TODO `json:"todos"`
+	ReadTODOResponse struct {
+		TODOs      []*TODO `json:"todos"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+		PrevCursor string  `json:"prev_cursor,omitempty"`
+		// HasMore reports whether there are TODOs after this page.
+		HasMore bool `json:"has_more"`
+	}
+
+	// A SearchTODORequest expresses ...
+	SearchTODORequest struct {
+		Query string
+		Size  int64
+	}
+	// A SearchTODOResult is a TODO matching a search, with a snippet of the
+	// matching text as HTML: the text is escaped, and matched terms are
+	// wrapped in <mark></mark>.
+	SearchTODOResult struct {
+		TODO    *TODO   `json:"todo"`
+		Snippet string  `json:"snippet"`
+		Rank    float64 `json:"rank"`
+	}
+	// A SearchTODOResponse expresses ...
+	SearchTODOResponse struct {
+		Results []*SearchTODOResult `json:"results"`
+	}
+
+	// A GetTODORequest expresses ...
+	GetTODORequest struct {
+		ID int64
+	}
+	// A GetTODOResponse expresses ...
+	GetTODOResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+
+	// A GetTODOTreeRequest expresses ...
+	GetTODOTreeRequest struct {
+		ID int64
+	}
+	// A GetTODOTreeResponse expresses ...
+	GetTODOTreeResponse struct {
+		TODO *TODONode `json:"todo"`
 	}
 
 	// A UpdateTODORequest expresses ...
@@ -37,15 +199,164 @@ type (
 		ID          int64  `json:"id"`
 		Subject     string `json:"subject"`
 		Description string `json:"description"`
+		Status      string `json:"status,omitempty"`
+		// Tags replaces the tags of the TODO unless nil.
+		Tags []string `json:"tags,omitempty"`
+		// ProjectID moves the TODO to the project unless nil.
+		ProjectID *int64 `json:"project_id,omitempty"`
+		// ParentID makes the TODO a subtask of the parent unless nil.
+		ParentID *int64 `json:"parent_id,omitempty"`
+		// StartAt and DueAt replace the dates of the TODO unless nil.
+		StartAt *time.Time `json:"start_at,omitempty"`
+		DueAt   *time.Time `json:"due_at,omitempty"`
+		// Recurrence and TimeZone replace those of the TODO unless nil.
+		Recurrence *string `json:"recurrence,omitempty"`
+		TimeZone   *string `json:"time_zone,omitempty"`
+		// Priority replaces the priority of the TODO unless nil.
+		Priority *int64 `json:"priority,omitempty"`
+		// Fields sets the custom fields it has members for; a null value
+		// clears the field.
+		Fields map[string]interface{} `json:"fields,omitempty"`
+		// Version is the version the client expects the TODO to be at,
+		// taken from If-Match. 0 means any version.
+		Version int64 `json:"-"`
 	}
 	// A UpdateTODOResponse expresses ...
 	UpdateTODOResponse struct {
 		TODO *TODO `json:"todo"`
 	}
 
+	// A PatchTODORequest expresses a JSON Merge Patch of a TODO. Absent
+	// members are left untouched and a null description clears it.
+	PatchTODORequest struct {
+		ID          int64           `json:"-"`
+		Version     int64           `json:"-"`
+		Subject     OptionalString  `json:"subject"`
+		Description OptionalString  `json:"description"`
+		Status      OptionalString  `json:"status"`
+		Tags        OptionalStrings `json:"tags"`
+		ProjectID   OptionalInt64   `json:"project_id"`
+		ParentID    OptionalInt64   `json:"parent_id"`
+		StartAt     OptionalTime    `json:"start_at"`
+		DueAt       OptionalTime    `json:"due_at"`
+		Recurrence  OptionalString  `json:"recurrence"`
+		TimeZone    OptionalString  `json:"time_zone"`
+		Priority    OptionalInt64   `json:"priority"`
+		// Fields sets the custom fields it has members for; a null value
+		// clears the field.
+		Fields map[string]interface{} `json:"fields"`
+	}
+
+	// A MoveTODORequest places a TODO right before or after another one in
+	// manual order. Exactly one of Before and After is set.
+	MoveTODORequest struct {
+		ID     int64 `json:"-"`
+		Before int64 `json:"before,omitempty"`
+		After  int64 `json:"after,omitempty"`
+	}
+	// A MoveTODOResponse expresses ...
+	MoveTODOResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+
+	// A RestoreTODORequest expresses ...
+	RestoreTODORequest struct {
+		ID int64
+	}
+	// A RestoreTODOResponse expresses ...
+	RestoreTODOResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+
+	// A ReadBlockerRequest expresses ...
+	ReadBlockerRequest struct {
+		ID int64
+	}
+	// A ReadBlockerResponse expresses ...
+	ReadBlockerResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+
+	// An AddBlockerRequest makes the TODO wait for the TODO BlockerID.
+	AddBlockerRequest struct {
+		ID        int64 `json:"-"`
+		BlockerID int64 `json:"id"`
+	}
+	// An AddBlockerResponse expresses ...
+	AddBlockerResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+
+	// A RemoveBlockerRequest expresses ...
+	RemoveBlockerRequest struct {
+		ID        int64
+		BlockerID int64
+	}
+	// A RemoveBlockerResponse expresses ...
+	RemoveBlockerResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+
+	// A TODORevision is the state of a TODO right after a change. Revision
+	// is the version the TODO had then.
+	TODORevision struct {
+		Revision int64  `json:"revision"`
+		Action   string `json:"action"`
+		Actor    string `json:"actor"`
+		TODO     *TODO  `json:"todo"`
+		// Changes lists what the change did to the previous revision.
+		Changes   []*TODOChange `json:"changes"`
+		CreatedAt time.Time     `json:"created_at"`
+	}
+
+	// A TODOChange is the old and new value of a field of a TODO. Values
+	// are those of the JSON representation of the TODO.
+	TODOChange struct {
+		Field string      `json:"field"`
+		Old   interface{} `json:"old"`
+		New   interface{} `json:"new"`
+	}
+
+	// A ReadTODOHistoryRequest expresses ...
+	ReadTODOHistoryRequest struct {
+		ID int64
+	}
+	// A ReadTODOHistoryResponse expresses ...
+	ReadTODOHistoryResponse struct {
+		Revisions []*TODORevision `json:"revisions"`
+	}
+
+	// A DiffTODORequest expresses ...
+	DiffTODORequest struct {
+		ID   int64
+		From int64
+		To   int64
+	}
+	// A DiffTODOResponse expresses ...
+	DiffTODOResponse struct {
+		From    int64         `json:"from"`
+		To      int64         `json:"to"`
+		Changes []*TODOChange `json:"changes"`
+	}
+
+	// A RevertTODORequest expresses ...
+	RevertTODORequest struct {
+		ID       int64
+		Revision int64
+		// Version is the version the client expects the TODO to be at,
+		// taken from If-Match. 0 means any version.
+		Version int64
+	}
+	// A RevertTODOResponse expresses ...
+	RevertTODOResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+
 	// A DeleteTODORequest expresses ...
-	DeleteTODORequest struct{
-		IDs	[]int64	`json:"ids"`
+	DeleteTODORequest struct {
+		IDs []int64 `json:"ids"`
+		// Version is only honoured when a single ID is given.
+		Version int64 `json:"-"`
 	}
 	// A DeleteTODOResponse expresses ...
 	DeleteTODOResponse struct{}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// An AttachmentService implements CRUD of Attachment entities. Contents are
+// stored as files under dir named by their SHA256, so identical uploads
+// share one file.
+type AttachmentService struct {
+	db      *sql.DB
+	dir     string
+	maxSize int64
+	// blobs is held shared by an upload from renaming its content into
+	// place until its row is inserted, and exclusively by PruneBlobs from
+	// checking a content is unused until it is removed
+	blobs sync.RWMutex
+}
+
+// NewAttachmentService returns new AttachmentService storing contents under
+// dir. Uploads larger than maxSize bytes are refused.
+func NewAttachmentService(db *sql.DB, dir string, maxSize int64) *AttachmentService {
+	return &AttachmentService{
+		db:      db,
+		dir:     dir,
+		maxSize: maxSize,
+	}
+}
+
+// MaxSize returns the size limit of an upload in bytes.
+func (s *AttachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+const attachmentColumns = `id, todo_id, name, size, content_type, sha256, created_at`
+
+// scanAttachment reads an Attachment selected with attachmentColumns.
+func scanAttachment(row rowScanner) (*model.Attachment, error) {
+	var a model.Attachment
+	err := row.Scan(&a.ID, &a.TODOID, &a.Name, &a.Size, &a.ContentType, &a.SHA256, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// getAttachment reads the Attachment of the TODO with q, which may be a
+// transaction.
+func getAttachment(ctx context.Context, q queryer, todoID, id int64) (*model.Attachment, error) {
+	const read = `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = ? AND todo_id = ?`
+
+	a, err := scanAttachment(q.QueryRowContext(ctx, read, id, todoID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// blobPath returns the file the content with the given hex SHA256 is
+// stored in. Files are spread over directories by the first two digits.
+func (s *AttachmentService) blobPath(sum string) string {
+	return filepath.Join(s.dir, sum[:2], sum)
+}
+
+// CreateAttachment attaches content to the TODO under name. The content
+// type is sniffed from the content rather than trusted from the client.
+func (s *AttachmentService) CreateAttachment(ctx context.Context, todoID int64, name string, content io.Reader) (*model.Attachment, error) {
+	const insert = `INSERT INTO attachments(todo_id, name, size, content_type, sha256) VALUES(?, ?, ?, ?, ?)`
+
+	// the content goes to disk first, so check the TODO up front
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+	name = attachmentName(name)
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	br := bufio.NewReaderSize(content, 512)
+	// Peek fails on content shorter than 512 bytes, which is sniffed all the same
+	head, _ := br.Peek(512)
+	contentType := http.DetectContentType(head)
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(br, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > s.maxSize {
+		return nil, &model.ErrTooLarge{Limit: s.maxSize}
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	// an existing file has the same content and is replaced atomically,
+	// and is not pruned until the row referring to it is inserted
+	sum := hex.EncodeToString(hash.Sum(nil))
+	blob := s.blobPath(sum)
+	s.blobs.RLock()
+	ret, err := s.storeBlob(ctx, tmp.Name(), blob, insert, todoID, name, size, contentType, sum)
+	s.blobs.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return getAttachment(ctx, s.db, todoID, id)
+}
+
+// storeBlob renames the uploaded file tmp to blob and inserts the row
+// referring to it with the args of insert.
+func (s *AttachmentService) storeBlob(ctx context.Context, tmp, blob, insert string, args ...interface{}) (sql.Result, error) {
+	if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, blob); err != nil {
+		return nil, err
+	}
+	return s.db.ExecContext(ctx, insert, args...)
+}
+
+// attachmentName keeps the base name of a file name sent by a client,
+// which may be a Windows or Unix path.
+func attachmentName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// ListAttachment reads the attachments of the TODO, oldest first.
+func (s *AttachmentService) ListAttachment(ctx context.Context, todoID int64) ([]*model.Attachment, error) {
+	const read = `SELECT ` + attachmentColumns + ` FROM attachments WHERE todo_id = ? ORDER BY id`
+
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, read, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*model.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// OpenAttachment reads the Attachment and opens its content. The caller
+// closes the file.
+func (s *AttachmentService) OpenAttachment(ctx context.Context, todoID, id int64) (*model.Attachment, *os.File, error) {
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, nil, err
+	}
+	a, err := getAttachment(ctx, s.db, todoID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.blobPath(a.SHA256))
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, f, nil
+}
+
+// DeleteAttachment deletes the Attachment. Its content is left to
+// PruneBlobs, even when no other attachment shares it: removing it here
+// would race an upload of the same content, which renames its file onto
+// this one before inserting its row.
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, todoID, id int64) error {
+	const deleteOne = `DELETE FROM attachments WHERE id = ? AND todo_id = ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTODO(ctx, tx, todoID); err != nil {
+		return err
+	}
+	if _, err := getAttachment(ctx, tx, todoID, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, deleteOne, id, todoID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pruneGrace is how long a stored content is kept without any attachment
+// referring to it, so that uploads in progress are left alone.
+const pruneGrace = time.Minute
+
+// PruneBlobs removes the stored contents no attachment refers to anymore,
+// e.g. those of deleted attachments or of TODOs purged from the trash, and
+// reports how many there were.
+func (s *AttachmentService) PruneBlobs(ctx context.Context) (int, error) {
+	var pruned int
+	err := filepath.Walk(s.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// skip directories and uploads in progress
+		if info.IsDir() || len(info.Name()) != sha256.Size*2 || time.Since(info.ModTime()) < pruneGrace {
+			return nil
+		}
+		removed, err := s.pruneBlob(ctx, p, info.Name())
+		if removed {
+			pruned++
+		}
+		return err
+	})
+	return pruned, err
+}
+
+// pruneBlob removes the stored content p of the given hex SHA256 unless an
+// attachment refers to it, and reports whether it did. Uploads wait for it,
+// so that none refers to the content between the check and the removal.
+func (s *AttachmentService) pruneBlob(ctx context.Context, p, sum string) (bool, error) {
+	const used = `SELECT COUNT(*) > 0 FROM attachments WHERE sha256 = ?`
+
+	s.blobs.Lock()
+	defer s.blobs.Unlock()
+
+	var keep bool
+	if err := s.db.QueryRowContext(ctx, used, sum).Scan(&keep); err != nil {
+		return false, err
+	}
+	if keep {
+		return false, nil
+	}
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RunPruner runs PruneBlobs every interval, until ctx is done.
+func (s *AttachmentService) RunPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.PruneBlobs(ctx)
+		if err != nil {
+			log.Print("prune attachments: ", err)
+		} else if n > 0 {
+			log.Printf("prune attachments: %d files deleted", n)
+		}
+	}
+}
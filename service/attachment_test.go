@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestAttachmentService(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	svc := service.NewAttachmentService(todoDB, dir, 16)
+	todoSvc := service.NewTODOService(todoDB)
+
+	for _, subject := range []string{"foo", "bar"} {
+		if _, err := todoSvc.CreateTODO(ctx, subject, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// blobs lists the stored contents.
+	blobs := func(t *testing.T) []string {
+		t.Helper()
+		names := []string{}
+		err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				names = append(names, info.Name())
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	testcase := []struct {
+		name            string
+		todoID          int64
+		file            string
+		content         string
+		wantErr         interface{}
+		wantName        string
+		wantContentType string
+	}{
+		{name: "text", todoID: 1, file: "notes.txt", content: "hello", wantName: "notes.txt", wantContentType: "text/plain; charset=utf-8"},
+		{name: "same content", todoID: 2, file: `C:\logs\copy.log`, content: "hello", wantName: "copy.log", wantContentType: "text/plain; charset=utf-8"},
+		{name: "sniffed", todoID: 1, file: "fake.txt", content: "\x89PNG\r\n\x1a\n", wantName: "fake.txt", wantContentType: "image/png"},
+		{name: "too large", todoID: 1, file: "big.txt", content: strings.Repeat("x", 17), wantErr: &model.ErrTooLarge{}},
+		{name: "unknown TODO", todoID: 9999, file: "a.txt", content: "a", wantErr: &model.ErrNotFound{}},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := svc.CreateAttachment(ctx, tc.todoID, tc.file, strings.NewReader(tc.content))
+			if tc.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Name != tc.wantName || a.ContentType != tc.wantContentType || a.Size != int64(len(tc.content)) {
+				t.Fatal("expected: ", tc.wantName, tc.wantContentType, ", actual: ", a)
+			}
+
+			_, f, err := svc.OpenAttachment(ctx, tc.todoID, a.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			content, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tc.content {
+				t.Fatal("expected: ", tc.content, ", actual: ", string(content))
+			}
+		})
+	}
+
+	t.Run("deduplicated", func(t *testing.T) {
+		if n := len(blobs(t)); n != 2 {
+			t.Fatal("expected: 2, actual: ", n)
+		}
+	})
+
+	t.Run("delete shared", func(t *testing.T) {
+		if err := svc.DeleteAttachment(ctx, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(blobs(t)); n != 2 {
+			t.Fatal("expected: 2, actual: ", n)
+		}
+		if _, _, err := svc.OpenAttachment(ctx, 2, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.DeleteAttachment(ctx, 1, 1); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("delete last", func(t *testing.T) {
+		if err := svc.DeleteAttachment(ctx, 2, 2); err != nil {
+			t.Fatal(err)
+		}
+		// the content is left to PruneBlobs
+		if n := len(blobs(t)); n != 2 {
+			t.Fatal("expected: 2, actual: ", n)
+		}
+		// and can be uploaded again meanwhile
+		a, err := svc.CreateAttachment(ctx, 2, "again.txt", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, f, err := svc.OpenAttachment(ctx, 2, a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if err := svc.DeleteAttachment(ctx, 2, a.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("prune purged", func(t *testing.T) {
+		if err := todoSvc.DeleteTODO(ctx, []int64{1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := todoSvc.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		// fresh contents are kept for uploads in progress
+		old := time.Now().Add(-time.Hour)
+		for _, name := range blobs(t) {
+			if err := os.Chtimes(filepath.Join(dir, name[:2], name), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		n, err := svc.PruneBlobs(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 || len(blobs(t)) != 0 {
+			t.Fatal("expected: 2 pruned, actual: ", n, blobs(t))
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A ChecklistService implements CRUD of the ChecklistItem entities of TODOs.
+type ChecklistService struct {
+	db *sql.DB
+}
+
+// NewChecklistService returns new ChecklistService.
+func NewChecklistService(db *sql.DB) *ChecklistService {
+	return &ChecklistService{
+		db: db,
+	}
+}
+
+const checklistColumns = `id, todo_id, text, checked, position, created_at, updated_at`
+
+// scanChecklistItem reads a ChecklistItem selected with checklistColumns.
+func scanChecklistItem(row rowScanner) (*model.ChecklistItem, error) {
+	var item model.ChecklistItem
+	err := row.Scan(&item.ID, &item.TODOID, &item.Text, &item.Checked, &item.Position, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// getChecklistItem reads the item of the TODO with q, which may be a
+// transaction.
+func getChecklistItem(ctx context.Context, q queryer, todoID, id int64) (*model.ChecklistItem, error) {
+	const read = `SELECT ` + checklistColumns + ` FROM checklist_items WHERE id = ? AND todo_id = ?`
+
+	item, err := scanChecklistItem(q.QueryRowContext(ctx, read, id, todoID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+// CreateChecklistItem adds an unchecked item at the end of the checklist of
+// the TODO.
+func (s *ChecklistService) CreateChecklistItem(ctx context.Context, todoID int64, text string) (*model.ChecklistItem, error) {
+	const (
+		readLast = `SELECT COALESCE(MAX(position), '') FROM checklist_items WHERE todo_id = ?`
+		insert   = `INSERT INTO checklist_items(todo_id, text, position) VALUES(?, ?, ?)`
+	)
+
+	if strings.TrimSpace(text) == "" {
+		return nil, &model.ErrInvalidArgument{What: "text not found"}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := touchTODO(ctx, tx, todoID); err != nil {
+		return nil, err
+	}
+	var last string
+	if err := tx.QueryRowContext(ctx, readLast, todoID).Scan(&last); err != nil {
+		return nil, err
+	}
+	ret, err := tx.ExecContext(ctx, insert, todoID, text, rankBetween(last, ""))
+	if err != nil {
+		return nil, err
+	}
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := getChecklistItem(ctx, tx, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ListChecklist reads the checklist of the TODO in order.
+func (s *ChecklistService) ListChecklist(ctx context.Context, todoID int64) ([]*model.ChecklistItem, error) {
+	const read = `SELECT ` + checklistColumns + ` FROM checklist_items WHERE todo_id = ? ORDER BY position, id`
+
+	if err := checkTODO(ctx, s.db, todoID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, read, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*model.ChecklistItem{}
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// UpdateChecklistItem changes the text and checked flag of the item, each
+// unless nil.
+func (s *ChecklistService) UpdateChecklistItem(ctx context.Context, todoID, id int64, text *string, checked *bool) (*model.ChecklistItem, error) {
+	const update = `UPDATE checklist_items SET text = COALESCE(?, text), checked = COALESCE(?, checked)
+		WHERE id = ? AND todo_id = ?`
+
+	var textArg, checkedArg interface{}
+	if text != nil {
+		if strings.TrimSpace(*text) == "" {
+			return nil, &model.ErrInvalidArgument{What: "text not found"}
+		}
+		textArg = *text
+	}
+	if checked != nil {
+		checkedArg = *checked
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := touchTODO(ctx, tx, todoID); err != nil {
+		return nil, err
+	}
+	ret, err := tx.ExecContext(ctx, update, textArg, checkedArg, id, todoID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+
+	item, err := getChecklistItem(ctx, tx, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// MoveChecklistItem places the item right before or after another one of
+// the same checklist. Exactly one of before and after must be non-zero.
+// Only the moved item is written, and the version of the TODO bumped.
+func (s *ChecklistService) MoveChecklistItem(ctx context.Context, todoID, id, before, after int64) (*model.ChecklistItem, error) {
+	const (
+		readPosition = `SELECT position FROM checklist_items WHERE id = ? AND todo_id = ?`
+		readPrev     = `SELECT COALESCE(MAX(position), '') FROM checklist_items WHERE todo_id = ? AND position < ? AND id <> ?`
+		readNext     = `SELECT COALESCE(MIN(position), '') FROM checklist_items WHERE todo_id = ? AND position > ? AND id <> ?`
+		update       = `UPDATE checklist_items SET position = ? WHERE id = ?`
+	)
+
+	if (before == 0) == (after == 0) {
+		return nil, &model.ErrInvalidArgument{What: "either before or after must be given"}
+	}
+	anchor := before + after
+	if anchor == id {
+		return nil, &model.ErrInvalidArgument{What: "cannot move an item next to itself"}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := touchTODO(ctx, tx, todoID); err != nil {
+		return nil, err
+	}
+	if _, err := getChecklistItem(ctx, tx, todoID, id); err != nil {
+		return nil, err
+	}
+	var position string
+	if err := tx.QueryRowContext(ctx, readPosition, anchor, todoID).Scan(&position); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrInvalidArgument{What: "item to move next to not found"}
+		}
+		return nil, err
+	}
+
+	// the neighbour on the other side of the anchor bounds the new rank
+	lower, upper := position, ""
+	neighbour := readNext
+	if before != 0 {
+		lower, upper = "", position
+		neighbour = readPrev
+	}
+	var bound string
+	if err := tx.QueryRowContext(ctx, neighbour, todoID, position, id).Scan(&bound); err != nil {
+		return nil, err
+	}
+	if before != 0 {
+		lower = bound
+	} else {
+		upper = bound
+	}
+
+	if _, err := tx.ExecContext(ctx, update, rankBetween(lower, upper), id); err != nil {
+		return nil, err
+	}
+	item, err := getChecklistItem(ctx, tx, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// DeleteChecklistItem removes the item from the checklist.
+func (s *ChecklistService) DeleteChecklistItem(ctx context.Context, todoID, id int64) error {
+	const deleteOne = `DELETE FROM checklist_items WHERE id = ? AND todo_id = ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := touchTODO(ctx, tx, todoID); err != nil {
+		return err
+	}
+	ret, err := tx.ExecContext(ctx, deleteOne, id, todoID)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return tx.Commit()
+}
+
+// fillChecklistProgress sets ChecklistProgress of the TODOs in byID that
+// have checklist items.
+func fillChecklistProgress(ctx context.Context, q queryer, byID map[int64]*model.TODO, ids []interface{}) error {
+	query := `SELECT todo_id, COUNT(*), COALESCE(SUM(checked), 0) FROM checklist_items
+		WHERE todo_id IN (` + placeholders(len(ids)) + `) GROUP BY todo_id`
+
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id       int64
+			progress model.TODOProgress
+		)
+		if err := rows.Scan(&id, &progress.Total, &progress.Done); err != nil {
+			return err
+		}
+		byID[id].ChecklistProgress = &progress
+	}
+	return rows.Err()
+}
@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestChecklistService(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	todoSvc := service.NewTODOService(todoDB)
+	svc := service.NewChecklistService(todoDB)
+
+	todo, err := todoSvc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.ChecklistProgress != nil {
+		t.Fatal("expected: nil, actual: ", todo.ChecklistProgress)
+	}
+	for _, text := range []string{"first", "second", "third"} {
+		if _, err := svc.CreateChecklistItem(ctx, todo.ID, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	texts := func(t *testing.T) []string {
+		t.Helper()
+		items, err := svc.ListChecklist(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts := []string{}
+		for _, item := range items {
+			texts = append(texts, item.Text)
+		}
+		return texts
+	}
+
+	t.Run("create", func(t *testing.T) {
+		if ret, want := texts(t), []string{"first", "second", "third"}; !reflect.DeepEqual(ret, want) {
+			t.Fatal("expected: ", want, ", actual: ", ret)
+		}
+		if _, err := svc.CreateChecklistItem(ctx, todo.ID, " "); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrInvalidArgument{}) {
+			t.Fatal("expected: *model.ErrInvalidArgument, actual: ", err)
+		}
+		if _, err := svc.CreateChecklistItem(ctx, 9999, "item"); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		checked := true
+		item, err := svc.UpdateChecklistItem(ctx, todo.ID, 2, nil, &checked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !item.Checked || item.Text != "second" {
+			t.Fatal("expected: second checked, actual: ", item)
+		}
+		ret, err := todoSvc.GetTODO(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (&model.TODOProgress{Total: 3, Done: 1}); !reflect.DeepEqual(ret.ChecklistProgress, want) {
+			t.Fatal("expected: ", want, ", actual: ", ret.ChecklistProgress)
+		}
+		if _, err := svc.UpdateChecklistItem(ctx, todo.ID, 9999, nil, &checked); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("move", func(t *testing.T) {
+		for _, tc := range []struct {
+			name          string
+			id            int64
+			before, after int64
+			want          []string
+			wantErr       error
+		}{
+			{name: "before the first", id: 3, before: 1, want: []string{"third", "first", "second"}},
+			{name: "after the last", id: 1, after: 2, want: []string{"third", "second", "first"}},
+			{name: "between", id: 2, before: 3, want: []string{"second", "third", "first"}},
+			{name: "next to itself", id: 2, after: 2, wantErr: &model.ErrInvalidArgument{}},
+			{name: "next to unknown", id: 2, after: 9999, wantErr: &model.ErrInvalidArgument{}},
+			{name: "both", id: 2, before: 1, after: 3, wantErr: &model.ErrInvalidArgument{}},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := svc.MoveChecklistItem(ctx, todo.ID, tc.id, tc.before, tc.after)
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+				if tc.want == nil {
+					return
+				}
+				if ret := texts(t); !reflect.DeepEqual(ret, tc.want) {
+					t.Fatal("expected: ", tc.want, ", actual: ", ret)
+				}
+			})
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := svc.DeleteChecklistItem(ctx, todo.ID, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.DeleteChecklistItem(ctx, todo.ID, 2); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		ret, err := todoSvc.GetTODO(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (&model.TODOProgress{Total: 2}); !reflect.DeepEqual(ret.ChecklistProgress, want) {
+			t.Fatal("expected: ", want, ", actual: ", ret.ChecklistProgress)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		version := func(t *testing.T) int64 {
+			t.Helper()
+			ret, err := todoSvc.GetTODO(ctx, todo.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ret.Version
+		}
+
+		checked := false
+		for _, tc := range []struct {
+			name  string
+			write func() error
+		}{
+			{name: "create", write: func() error {
+				_, err := svc.CreateChecklistItem(ctx, todo.ID, "fourth")
+				return err
+			}},
+			{name: "update", write: func() error {
+				_, err := svc.UpdateChecklistItem(ctx, todo.ID, 4, nil, &checked)
+				return err
+			}},
+			{name: "move", write: func() error {
+				_, err := svc.MoveChecklistItem(ctx, todo.ID, 4, 1, 0)
+				return err
+			}},
+			{name: "delete", write: func() error {
+				return svc.DeleteChecklistItem(ctx, todo.ID, 4)
+			}},
+		} {
+			before := version(t)
+			if err := tc.write(); err != nil {
+				t.Fatal(tc.name, ": ", err)
+			}
+			if after := version(t); after == before {
+				t.Fatal(tc.name, ": expected: a new version, actual: ", after)
+			}
+		}
+
+		before := version(t)
+		if err := svc.DeleteChecklistItem(ctx, todo.ID, 4); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if after := version(t); after != before {
+			t.Fatal("expected: ", before, ", actual: ", after)
+		}
+	})
+}
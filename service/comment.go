@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A CommentService implements CRUD of Comment entities.
+type CommentService struct {
+	db *sql.DB
+}
+
+// NewCommentService returns new CommentService.
+func NewCommentService(db *sql.DB) *CommentService {
+	return &CommentService{
+		db: db,
+	}
+}
+
+const commentColumns = `id, todo_id, author, body, created_at, updated_at`
+
+// scanComment reads a Comment selected with commentColumns.
+func scanComment(row rowScanner) (*model.Comment, error) {
+	var comment model.Comment
+	err := row.Scan(&comment.ID, &comment.TODOID, &comment.Author, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// getComment reads the Comment of the TODO with q, which may be a
+// transaction.
+func getComment(ctx context.Context, q queryer, todoID, id int64) (*model.Comment, error) {
+	const read = `SELECT ` + commentColumns + ` FROM comments WHERE id = ? AND todo_id = ?`
+
+	comment, err := scanComment(q.QueryRowContext(ctx, read, id, todoID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
+// checkTODO reports whether the TODO exists and is not in the trash.
+func checkTODO(ctx context.Context, q queryer, id int64) error {
+	const read = `SELECT COUNT(*) > 0 FROM todos WHERE id = ? AND deleted_at IS NULL`
+
+	var found bool
+	if err := q.QueryRowContext(ctx, read, id).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return nil
+}
+
+// touchTODO bumps the version of the TODO within tx, so that its ETag
+// changes along with what it shows but does not store, such as its comment
+// count. Like checkTODO, it reports whether the TODO exists and is not in
+// the trash.
+func touchTODO(ctx context.Context, tx *sql.Tx, id int64) error {
+	const touch = `UPDATE todos SET updated_at = DATETIME('now') WHERE id = ? AND deleted_at IS NULL`
+
+	ret, err := tx.ExecContext(ctx, touch, id)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return nil
+}
+
+// CreateComment posts a Comment on the TODO. The author is the actor of ctx.
+func (s *CommentService) CreateComment(ctx context.Context, todoID int64, body string) (*model.Comment, error) {
+	const insert = `INSERT INTO comments(todo_id, author, body) VALUES(?, ?, ?)`
+
+	if strings.TrimSpace(body) == "" {
+		return nil, &model.ErrInvalidArgument{What: "body not found"}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := touchTODO(ctx, tx, todoID); err != nil {
+		return nil, err
+	}
+	ret, err := tx.ExecContext(ctx, insert, todoID, actorFrom(ctx), body)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := getComment(ctx, tx, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// ListComment reads a page of the comments of the TODO, oldest first.
+func (s *CommentService) ListComment(ctx context.Context, req *model.ReadCommentRequest) (*model.ReadCommentResponse, error) {
+	if req.PrevID < 0 || req.Size < 0 {
+		return nil, &model.ErrInvalidArgument{What: "prev_id and size must not be negative"}
+	}
+	if err := checkTODO(ctx, s.db, req.TODOID); err != nil {
+		return nil, err
+	}
+
+	q := newSelectQuery(commentColumns, "comments").
+		Where("todo_id = ?", req.TODOID).
+		Where("id > ?", req.PrevID).
+		OrderBy("id", false)
+	if req.Size > 0 {
+		// one more row tells whether there is a next page
+		q.Limit(req.Size + 1)
+	}
+	query, args := q.Build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*model.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := req.Size > 0 && int64(len(comments)) > req.Size
+	if more {
+		comments = comments[:req.Size]
+	}
+	return &model.ReadCommentResponse{Comments: comments, HasMore: more}, nil
+}
+
+// UpdateComment replaces the body of the Comment.
+func (s *CommentService) UpdateComment(ctx context.Context, todoID, id int64, body string) (*model.Comment, error) {
+	const update = `UPDATE comments SET body = ? WHERE id = ? AND todo_id = ?`
+
+	if strings.TrimSpace(body) == "" {
+		return nil, &model.ErrInvalidArgument{What: "body not found"}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTODO(ctx, tx, todoID); err != nil {
+		return nil, err
+	}
+	ret, err := tx.ExecContext(ctx, update, body, id, todoID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+
+	comment, err := getComment(ctx, tx, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// DeleteComment deletes the Comment.
+func (s *CommentService) DeleteComment(ctx context.Context, todoID, id int64) error {
+	const deleteOne = `DELETE FROM comments WHERE id = ? AND todo_id = ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := touchTODO(ctx, tx, todoID); err != nil {
+		return err
+	}
+	ret, err := tx.ExecContext(ctx, deleteOne, id, todoID)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return tx.Commit()
+}
+
+// fillCommentCounts sets CommentCount of the TODOs in byID.
+func fillCommentCounts(ctx context.Context, q queryer, byID map[int64]*model.TODO, ids []interface{}) error {
+	query := `SELECT todo_id, COUNT(*) FROM comments WHERE todo_id IN (` + placeholders(len(ids)) + `) GROUP BY todo_id`
+
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, count int64
+		if err := rows.Scan(&id, &count); err != nil {
+			return err
+		}
+		byID[id].CommentCount = count
+	}
+	return rows.Err()
+}
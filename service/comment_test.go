@@ -0,0 +1,159 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestCommentService(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := service.WithActor(context.Background(), "alice")
+	svc := service.NewCommentService(todoDB)
+	todoSvc := service.NewTODOService(todoDB)
+
+	for _, subject := range []string{"foo", "bar", "baz"} {
+		if _, err := todoSvc.CreateTODO(ctx, subject, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		todoID int64
+		body   string
+	}{
+		{1, "first"}, {1, "second"}, {1, "third"}, {2, "other"},
+	} {
+		comment, err := svc.CreateComment(ctx, c.todoID, c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comment.Author != "alice" || comment.Body != c.body {
+			t.Fatal("expected: alice and ", c.body, ", actual: ", comment.Author, " and ", comment.Body)
+		}
+	}
+
+	t.Run("list", func(t *testing.T) {
+		testcase := []struct {
+			name     string
+			req      *model.ReadCommentRequest
+			wantErr  interface{}
+			wantIDs  []int64
+			wantMore bool
+		}{
+			{name: "all", req: &model.ReadCommentRequest{TODOID: 1}, wantIDs: []int64{1, 2, 3}},
+			{name: "first page", req: &model.ReadCommentRequest{TODOID: 1, Size: 2}, wantIDs: []int64{1, 2}, wantMore: true},
+			{name: "next page", req: &model.ReadCommentRequest{TODOID: 1, PrevID: 2, Size: 2}, wantIDs: []int64{3}},
+			{name: "none", req: &model.ReadCommentRequest{TODOID: 3}, wantIDs: []int64{}},
+			{name: "unknown TODO", req: &model.ReadCommentRequest{TODOID: 9999}, wantErr: &model.ErrNotFound{}},
+		}
+		for _, tc := range testcase {
+			t.Run(tc.name, func(t *testing.T) {
+				ret, err := svc.ListComment(ctx, tc.req)
+				if tc.wantErr != nil {
+					if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+						t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []int64{}
+				for _, comment := range ret.Comments {
+					ids = append(ids, comment.ID)
+				}
+				if !reflect.DeepEqual(ids, tc.wantIDs) || ret.HasMore != tc.wantMore {
+					t.Fatal("expected: ", tc.wantIDs, tc.wantMore, ", actual: ", ids, ret.HasMore)
+				}
+			})
+		}
+	})
+
+	t.Run("count", func(t *testing.T) {
+		ret, err := todoSvc.ListTODO(ctx, &model.ReadTODORequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[int64]int64{}
+		for _, todo := range ret.TODOs {
+			counts[todo.ID] = todo.CommentCount
+		}
+		if want := map[int64]int64{1: 3, 2: 1, 3: 0}; !reflect.DeepEqual(counts, want) {
+			t.Fatal("expected: ", want, ", actual: ", counts)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		comment, err := svc.UpdateComment(ctx, 1, 2, "edited")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comment.Body != "edited" {
+			t.Fatal("expected: edited, actual: ", comment.Body)
+		}
+		if _, err := svc.UpdateComment(ctx, 2, 2, "edited"); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if _, err := svc.UpdateComment(ctx, 1, 2, " "); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrInvalidArgument{}) {
+			t.Fatal("expected: *model.ErrInvalidArgument, actual: ", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := svc.DeleteComment(ctx, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.DeleteComment(ctx, 1, 1); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		version := func() int64 {
+			todo, err := todoSvc.GetTODO(ctx, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return todo.Version
+		}
+
+		before := version()
+		comment, err := svc.CreateComment(ctx, 3, "new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		created := version()
+		if created == before {
+			t.Fatal("expected: a new version on create, actual: ", created)
+		}
+		if err := svc.DeleteComment(ctx, 3, comment.ID); err != nil {
+			t.Fatal(err)
+		}
+		if deleted := version(); deleted == created {
+			t.Fatal("expected: a new version on delete, actual: ", deleted)
+		}
+		if err := svc.DeleteComment(ctx, 3, comment.ID); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("TODO in trash", func(t *testing.T) {
+		if err := todoSvc.DeleteTODO(ctx, []int64{2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.CreateComment(ctx, 2, "late"); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+}
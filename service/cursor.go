@@ -0,0 +1,134 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A cursor marks the position of a TODO in a sorted list: its sort key and
+// its ID as tie-breaker. Prev tells which way the next page lies.
+type cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Key  string `json:"k,omitempty"`
+	ID   int64  `json:"i"`
+	Prev bool   `json:"p,omitempty"`
+}
+
+// newCursor returns the cursor of todo in a list sorted by the todoSorts
+// key name.
+func newCursor(todo *model.TODO, name string, desc, prev bool) *cursor {
+	c := &cursor{Sort: name, Desc: desc, ID: todo.ID, Prev: prev}
+	switch name {
+	case "created_at":
+		c.Key = formatTime(todo.CreatedAt)
+	case "updated_at":
+		c.Key = formatTime(todo.UpdatedAt)
+	case "position":
+		c.Key = todo.Position
+	case "priority":
+		c.Key = strconv.FormatInt(model.TODOPriorityHigh-todo.Priority, 10) + todo.Position
+	default:
+		if strings.HasPrefix(name, fieldSortPrefix) {
+			c.Key = fieldSortKey(todo.Fields[strings.TrimPrefix(name, fieldSortPrefix)])
+		}
+	}
+	return c
+}
+
+// newTODOPage returns the page of a list sorted by the todoSorts key name.
+// todos are read in the direction of the page from cur, or from the start
+// of the list when cur is nil, with one more TODO than size when there are
+// more. prevID is the prev_id the page was read after, if any.
+func newTODOPage(secret []byte, todos []*model.TODO, size int64, name string, desc bool, cur *cursor, prevID int64) *model.ReadTODOResponse {
+	// the page before a cursor is read in reverse and flipped here
+	backward := cur != nil && cur.Prev
+	more := size > 0 && int64(len(todos)) > size
+	if more {
+		todos = todos[:size]
+	}
+	if backward {
+		for i, j := 0, len(todos)-1; i < j; i, j = i+1, j-1 {
+			todos[i], todos[j] = todos[j], todos[i]
+		}
+	}
+
+	ret := &model.ReadTODOResponse{TODOs: todos}
+	if len(todos) > 0 {
+		first, last := todos[0], todos[len(todos)-1]
+		if backward || more {
+			ret.NextCursor = encodeCursor(secret, newCursor(last, name, desc, false))
+		}
+		if backward && more || !backward && (cur != nil || prevID != 0) {
+			ret.PrevCursor = encodeCursor(secret, newCursor(first, name, desc, true))
+		}
+	}
+	ret.HasMore = ret.NextCursor != ""
+	return ret
+}
+
+// SetCursorSecret sets the key cursors are signed with. Cursors signed with
+// another key are rejected, so every instance serving the same clients must
+// share it. By default a random key is used.
+func (s *TODOService) SetCursorSecret(secret []byte) {
+	s.cursorSecret = secret
+}
+
+// randomCursorSecret returns the random key cursors are signed with until
+// another one is set.
+func randomCursorSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+// encodeCursor returns c as an opaque token signed with secret: the base64
+// encoded JSON of c and its HMAC-SHA256, separated by a dot.
+func encodeCursor(secret []byte, c *cursor) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		// a cursor only holds strings, numbers and booleans
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signCursor(secret, payload))
+}
+
+// decodeCursor parses a token returned by encodeCursor with the same secret.
+func decodeCursor(secret []byte, token string) (*cursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, &model.ErrInvalidCursor{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, &model.ErrInvalidCursor{}
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(mac, signCursor(secret, payload)) {
+		return nil, &model.ErrInvalidCursor{}
+	}
+
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, &model.ErrInvalidCursor{}
+	}
+	if _, ok := todoSorts[c.Sort]; !ok && !strings.HasPrefix(c.Sort, fieldSortPrefix) {
+		return nil, &model.ErrInvalidCursor{}
+	}
+	return &c, nil
+}
+
+func signCursor(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// AddBlocker makes the TODO wait for blockerID. Adding a blocker twice is
+// not an error, while one that would make the TODO wait for itself, however
+// indirectly, is refused.
+func (s *TODOService) AddBlocker(ctx context.Context, id, blockerID int64) (*model.TODO, error) {
+	const insert = `INSERT OR IGNORE INTO todo_dependencies(todo_id, blocker_id) VALUES(?, ?)`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTODO(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := checkBlocker(ctx, tx, id, blockerID); err != nil {
+		return nil, err
+	}
+	ret, err := tx.ExecContext(ctx, insert, id, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected > 0 {
+		if err := touchTODO(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	todo, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// checkBlocker reports whether the TODO can wait for blockerID, i.e. the
+// blocker exists and does not wait for the TODO itself.
+func checkBlocker(ctx context.Context, q queryer, id, blockerID int64) error {
+	const read = `WITH RECURSIVE blockers(id) AS (
+			SELECT ?
+			UNION
+			SELECT todo_dependencies.blocker_id FROM todo_dependencies JOIN blockers ON todo_dependencies.todo_id = blockers.id
+		)
+		SELECT COUNT(*) > 0 FROM blockers WHERE id = ?`
+
+	if id == blockerID {
+		return &model.ErrInvalidArgument{What: "a TODO cannot block itself"}
+	}
+	if err := checkTODO(ctx, q, blockerID); err != nil {
+		return &model.ErrInvalidArgument{What: "blocker not found"}
+	}
+	// edges of TODOs in the trash count as well, as they come back on restore
+	var cycle bool
+	if err := q.QueryRowContext(ctx, read, blockerID, id).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return &model.ErrInvalidArgument{What: "blocker would make a cycle"}
+	}
+	return nil
+}
+
+// RemoveBlocker makes the TODO stop waiting for blockerID.
+func (s *TODOService) RemoveBlocker(ctx context.Context, id, blockerID int64) (*model.TODO, error) {
+	const deleteOne = `DELETE FROM todo_dependencies WHERE todo_id = ? AND blocker_id = ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkTODO(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	ret, err := tx.ExecContext(ctx, deleteOne, id, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, &model.ErrNotFound{What: "blocker not found"}
+	}
+	if err := touchTODO(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	todo, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// touchDependents bumps the version of the TODOs whose blocked or progress
+// follows the TODOs of ids, once their status, parent or place in the trash
+// has changed within tx: the TODOs waiting for them and their ancestors.
+// The TODOs of ids are left out, as are the ones in the trash.
+func touchDependents(ctx context.Context, tx *sql.Tx, ids ...int64) error {
+	const touchFmt = `WITH RECURSIVE changed(id) AS (
+			SELECT id FROM todos WHERE id IN (%s)
+		), ancestors(id) AS (
+			SELECT parent_id FROM todos WHERE id IN (SELECT id FROM changed)
+			UNION
+			SELECT todos.parent_id FROM todos JOIN ancestors ON todos.id = ancestors.id
+		)
+		UPDATE todos SET updated_at = DATETIME('now')
+		WHERE id IN (SELECT id FROM ancestors UNION SELECT todo_id FROM todo_dependencies WHERE blocker_id IN (SELECT id FROM changed))
+		AND id NOT IN (SELECT id FROM changed) AND +deleted_at IS NULL`
+
+	for len(ids) > maxQueryIDs {
+		if err := touchDependents(ctx, tx, ids[:maxQueryIDs]...); err != nil {
+			return err
+		}
+		ids = ids[maxQueryIDs:]
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(touchFmt, placeholders(len(ids))), args...)
+	return err
+}
+
+// ListBlockers reads the TODOs the TODO waits for, ordered by id. Blockers
+// in the trash are left out.
+func (s *TODOService) ListBlockers(ctx context.Context, id int64) ([]*model.TODO, error) {
+	const read = `SELECT %s FROM todo_dependencies JOIN todos ON todos.id = todo_dependencies.blocker_id
+		WHERE todo_dependencies.todo_id = ? AND todos.deleted_at IS NULL ORDER BY todos.id`
+
+	if err := checkTODO(ctx, s.db, id); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(read, prefixColumns("todos", todoColumns)), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*model.TODO{}
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := fillTODOs(ctx, s.db, todos...); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// ListTopological reads the open and in-progress TODOs of the project so
+// that each comes after the TODOs of the project it waits for. TODOs that
+// could go in either order are ordered by position, then by id.
+func (s *TODOService) ListTopological(ctx context.Context, projectID int64) ([]*model.TODO, error) {
+	const (
+		read = `SELECT ` + todoColumns + ` FROM todos
+			WHERE project_id = ? AND status IN ('open', 'in_progress') AND deleted_at IS NULL
+			ORDER BY position, id`
+		readEdges = `SELECT todo_dependencies.todo_id, todo_dependencies.blocker_id FROM todo_dependencies
+			JOIN todos ON todos.id = todo_dependencies.todo_id
+			WHERE todos.project_id = ? AND todos.deleted_at IS NULL`
+	)
+
+	rows, err := s.db.QueryContext(ctx, read, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*model.TODO{}
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	edges, err := s.db.QueryContext(ctx, readEdges, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer edges.Close()
+
+	blockers := map[int64][]int64{}
+	for edges.Next() {
+		var id, blockerID int64
+		if err := edges.Scan(&id, &blockerID); err != nil {
+			return nil, err
+		}
+		blockers[id] = append(blockers[id], blockerID)
+	}
+	if err := edges.Err(); err != nil {
+		return nil, err
+	}
+
+	todos = topoSort(todos, blockers)
+	if err := fillTODOs(ctx, s.db, todos...); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// topoSort orders todos so that each comes after its blockers among them.
+// Otherwise the given order is kept: of the TODOs that are ready, the one
+// given first goes first. Blockers not in todos are ignored.
+func topoSort(todos []*model.TODO, blockers map[int64][]int64) []*model.TODO {
+	index := make(map[int64]int, len(todos))
+	for i, todo := range todos {
+		index[todo.ID] = i
+	}
+
+	waiting := make([]int, len(todos))
+	blocks := make([][]int, len(todos))
+	for i, todo := range todos {
+		for _, blockerID := range blockers[todo.ID] {
+			if j, ok := index[blockerID]; ok {
+				waiting[i]++
+				blocks[j] = append(blocks[j], i)
+			}
+		}
+	}
+
+	// ready holds the indexes of the TODOs waiting for nothing, sorted
+	ready := []int{}
+	for i := range todos {
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	sorted := make([]*model.TODO, 0, len(todos))
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, todos[i])
+		for _, j := range blocks[i] {
+			waiting[j]--
+			if waiting[j] == 0 {
+				k := sort.SearchInts(ready, j)
+				ready = append(ready, 0)
+				copy(ready[k+1:], ready[k:])
+				ready[k] = j
+			}
+		}
+	}
+
+	// cycles are refused by AddBlocker, but keep every TODO all the same
+	if len(sorted) < len(todos) {
+		for i, todo := range todos {
+			if waiting[i] > 0 {
+				sorted = append(sorted, todo)
+			}
+		}
+	}
+	return sorted
+}
+
+// fillBlocked sets Blocked of the TODOs in byID that wait for a TODO that
+// is neither done nor cancelled.
+func fillBlocked(ctx context.Context, q queryer, byID map[int64]*model.TODO, ids []interface{}) error {
+	// the blockers are looked up by id: the + keeps SQLite from scanning
+	// index_todos_deleted_at for them instead, which nearly every TODO is in
+	query := `SELECT DISTINCT todo_dependencies.todo_id FROM todo_dependencies
+		JOIN todos ON todos.id = todo_dependencies.blocker_id
+		WHERE todo_dependencies.todo_id IN (` + placeholders(len(ids)) + `)
+		AND todos.status NOT IN ('done', 'cancelled') AND +todos.deleted_at IS NULL`
+
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		byID[id].Blocked = true
+	}
+	return rows.Err()
+}
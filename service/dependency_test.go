@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestDependencies(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	project, err := service.NewProjectService(todoDB).CreateProject(ctx, "project", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "subject", ProjectID: &project.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topological := func(t *testing.T) []int64 {
+		t.Helper()
+		todos, err := svc.ListTopological(ctx, project.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range todos {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+
+	t.Run("add", func(t *testing.T) {
+		// 4 ─── 3 ─── 1
+		for _, edge := range [][2]int64{{1, 3}, {3, 4}, {1, 3}} {
+			todo, err := svc.AddBlocker(ctx, edge[0], edge[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !todo.Blocked {
+				t.Fatal("expected: blocked, actual: ", todo.Blocked)
+			}
+		}
+		blockers, err := svc.ListBlockers(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blockers) != 1 || blockers[0].ID != 3 || !blockers[0].Blocked {
+			t.Fatal("expected: [3] blocked by 4, actual: ", blockers)
+		}
+		if ids, want := topological(t), []int64{2, 4, 3, 1}; !reflect.DeepEqual(ids, want) {
+			t.Fatal("expected: ", want, ", actual: ", ids)
+		}
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		for _, tc := range []struct {
+			name        string
+			id, blocker int64
+			wantErr     error
+		}{
+			{name: "self", id: 2, blocker: 2, wantErr: &model.ErrInvalidArgument{}},
+			{name: "cycle", id: 4, blocker: 1, wantErr: &model.ErrInvalidArgument{}},
+			{name: "unknown blocker", id: 2, blocker: 99, wantErr: &model.ErrInvalidArgument{}},
+			{name: "unknown TODO", id: 99, blocker: 2, wantErr: &model.ErrNotFound{}},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				if _, err := svc.AddBlocker(ctx, tc.id, tc.blocker); reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+			})
+		}
+	})
+
+	t.Run("done blockers", func(t *testing.T) {
+		status := model.OptionalString{Set: true, Value: model.TODOStatusDone}
+		if _, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: 3, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+		todo, err := svc.GetTODO(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Blocked {
+			t.Fatal("expected: not blocked, actual: ", todo.Blocked)
+		}
+		if ids, want := topological(t), []int64{1, 2, 4}; !reflect.DeepEqual(ids, want) {
+			t.Fatal("expected: ", want, ", actual: ", ids)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if _, err := svc.RemoveBlocker(ctx, 3, 4); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.RemoveBlocker(ctx, 3, 4); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		// the cycle is gone with the blocker
+		if _, err := svc.AddBlocker(ctx, 4, 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		versions := func(t *testing.T, ids ...int64) map[int64]int64 {
+			t.Helper()
+			versions := map[int64]int64{}
+			for _, id := range ids {
+				todo, err := svc.GetTODO(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				versions[id] = todo.Version
+			}
+			return versions
+		}
+
+		// 2 and 4 wait for 1
+		for _, tc := range []struct {
+			name  string
+			write func() error
+			want  []int64
+		}{
+			{name: "add", want: []int64{2}, write: func() error {
+				_, err := svc.AddBlocker(ctx, 2, 1)
+				return err
+			}},
+			{name: "blocker status", want: []int64{2, 4}, write: func() error {
+				_, err := svc.UpdateTODOStatus(ctx, 1, model.TODOStatusDone)
+				return err
+			}},
+			{name: "blocker deleted", want: []int64{2, 4}, write: func() error {
+				return svc.DeleteTODO(ctx, []int64{1})
+			}},
+			{name: "blocker restored", want: []int64{2, 4}, write: func() error {
+				_, err := svc.RestoreTODO(ctx, 1)
+				return err
+			}},
+			{name: "remove", want: []int64{2}, write: func() error {
+				_, err := svc.RemoveBlocker(ctx, 2, 1)
+				return err
+			}},
+		} {
+			before := versions(t, 2, 4)
+			if err := tc.write(); err != nil {
+				t.Fatal(tc.name, ": ", err)
+			}
+			after := versions(t, 2, 4)
+			for _, id := range tc.want {
+				if after[id] == before[id] {
+					t.Fatal(tc.name, ": expected: a new version of ", id, ", actual: ", after[id])
+				}
+			}
+		}
+	})
+}
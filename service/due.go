@@ -0,0 +1,26 @@
+package service
+
+import (
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// dueRange returns the range of due_at the view covers, from inclusive and
+// to exclusive, with the days starting at midnight in the location of now.
+// from is zero when the range is open.
+func dueRange(view string, now time.Time) (from, to time.Time, err error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch view {
+	case model.TODODueOverdue:
+		return time.Time{}, now, nil
+	case model.TODODueToday:
+		return today, today.AddDate(0, 0, 1), nil
+	case model.TODODueWeek:
+		// weeks start on Monday
+		monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+		return monday, monday.AddDate(0, 0, 7), nil
+	default:
+		return time.Time{}, time.Time{}, &model.ErrInvalidArgument{What: "invalid due: " + view}
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueRange(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a Sunday afternoon in New York is already Monday in Tokyo
+	now := time.Date(2021, 3, 14, 15, 0, 0, 0, newYork)
+
+	testcase := []struct {
+		name     string
+		view     string
+		now      time.Time
+		wantFrom time.Time
+		wantTo   time.Time
+		isError  bool
+	}{
+		{
+			name:   "overdue",
+			view:   "overdue",
+			now:    now,
+			wantTo: now,
+		},
+		{
+			name:     "today in New York",
+			view:     "today",
+			now:      now,
+			wantFrom: time.Date(2021, 3, 14, 0, 0, 0, 0, newYork),
+			wantTo:   time.Date(2021, 3, 15, 0, 0, 0, 0, newYork),
+		},
+		{
+			name:     "today in Tokyo",
+			view:     "today",
+			now:      now.In(tokyo),
+			wantFrom: time.Date(2021, 3, 15, 0, 0, 0, 0, tokyo),
+			wantTo:   time.Date(2021, 3, 16, 0, 0, 0, 0, tokyo),
+		},
+		{
+			name:     "week in New York across DST",
+			view:     "week",
+			now:      now,
+			wantFrom: time.Date(2021, 3, 8, 0, 0, 0, 0, newYork),
+			wantTo:   time.Date(2021, 3, 15, 0, 0, 0, 0, newYork),
+		},
+		{
+			name:     "week in Tokyo",
+			view:     "week",
+			now:      now.In(tokyo),
+			wantFrom: time.Date(2021, 3, 15, 0, 0, 0, 0, tokyo),
+			wantTo:   time.Date(2021, 3, 22, 0, 0, 0, 0, tokyo),
+		},
+		{
+			name:    "unknown",
+			view:    "month",
+			now:     now,
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to, err := dueRange(tc.view, tc.now)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case !tc.isError && err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+			if !from.Equal(tc.wantFrom) {
+				t.Fatal("expected: ", tc.wantFrom, ", actual: ", from)
+			}
+			if !to.Equal(tc.wantTo) {
+				t.Fatal("expected: ", tc.wantTo, ", actual: ", to)
+			}
+		})
+	}
+}
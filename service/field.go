@@ -0,0 +1,476 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A CustomFieldService implements CRUD of CustomField entities. The values
+// of the fields are written with the TODOs. Fields are global: there is
+// no workspace, so every TODO has the same fields.
+type CustomFieldService struct {
+	db *sql.DB
+}
+
+// NewCustomFieldService returns new CustomFieldService.
+func NewCustomFieldService(db *sql.DB) *CustomFieldService {
+	return &CustomFieldService{
+		db: db,
+	}
+}
+
+const customFieldColumns = `id, name, type, options, created_at`
+
+// dateLayout is the format of the values of date fields.
+const dateLayout = "2006-01-02"
+
+// scanCustomField reads a CustomField selected with customFieldColumns.
+func scanCustomField(row rowScanner) (*model.CustomField, error) {
+	var (
+		field   model.CustomField
+		options []byte
+	)
+	if err := row.Scan(&field.ID, &field.Name, &field.Type, &options, &field.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(options, &field.Options); err != nil {
+		return nil, err
+	}
+	if len(field.Options) == 0 {
+		field.Options = nil
+	}
+	return &field, nil
+}
+
+// getCustomField reads the field with q, which may be a transaction.
+func getCustomField(ctx context.Context, q queryer, id int64) (*model.CustomField, error) {
+	const read = `SELECT ` + customFieldColumns + ` FROM custom_fields WHERE id = ?`
+
+	field, err := scanCustomField(q.QueryRowContext(ctx, read, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	return field, nil
+}
+
+// findCustomField reads the field named name, ignoring ASCII case.
+func findCustomField(ctx context.Context, q queryer, name string) (*model.CustomField, error) {
+	const read = `SELECT ` + customFieldColumns + ` FROM custom_fields WHERE name = ?`
+
+	field, err := scanCustomField(q.QueryRowContext(ctx, read, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrInvalidArgument{What: fmt.Sprintf("unknown field: %q", name)}
+		}
+		return nil, err
+	}
+	return field, nil
+}
+
+// checkOptions reports whether options suit a field of type typ: an enum
+// needs distinct, non-empty options and the other types none.
+func checkOptions(typ string, options []string) error {
+	if typ != model.FieldTypeEnum {
+		if len(options) > 0 {
+			return &model.ErrInvalidArgument{What: "only enum fields have options"}
+		}
+		return nil
+	}
+	if len(options) == 0 {
+		return &model.ErrInvalidArgument{What: "enum fields need options"}
+	}
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		if strings.TrimSpace(option) == "" {
+			return &model.ErrInvalidArgument{What: "option must not be empty"}
+		}
+		if seen[option] {
+			return &model.ErrInvalidArgument{What: fmt.Sprintf("duplicate option: %q", option)}
+		}
+		seen[option] = true
+	}
+	return nil
+}
+
+// CreateCustomField defines a field of type typ. options are the values an
+// enum field accepts.
+func (s *CustomFieldService) CreateCustomField(ctx context.Context, name, typ string, options []string) (*model.CustomField, error) {
+	const insert = `INSERT INTO custom_fields(name, type, options) VALUES(?, ?, ?) ON CONFLICT(name) DO NOTHING`
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &model.ErrInvalidArgument{What: "name not found"}
+	}
+	switch typ {
+	case model.FieldTypeText, model.FieldTypeNumber, model.FieldTypeDate, model.FieldTypeEnum, model.FieldTypeBoolean:
+	default:
+		return nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid type: %q", typ)}
+	}
+	if err := checkOptions(typ, options); err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = []string{}
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := s.db.ExecContext(ctx, insert, name, typ, string(data))
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, &model.ErrConflict{What: fmt.Sprintf("field %q already exists", name)}
+	}
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return getCustomField(ctx, s.db, id)
+}
+
+// ReadCustomField reads every field ordered by name.
+func (s *CustomFieldService) ReadCustomField(ctx context.Context) ([]*model.CustomField, error) {
+	const read = `SELECT ` + customFieldColumns + ` FROM custom_fields ORDER BY name`
+
+	rows, err := s.db.QueryContext(ctx, read)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []*model.CustomField{}
+	for rows.Next() {
+		field, err := scanCustomField(rows)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// UpdateCustomField renames the field and replaces its options. An option
+// cannot be dropped while a TODO has it as value.
+func (s *CustomFieldService) UpdateCustomField(ctx context.Context, id int64, name string, options []string) (*model.CustomField, error) {
+	const (
+		used   = `SELECT COUNT(*) > 0 FROM todo_field_values WHERE field_id = ? AND text_value NOT IN (%s)`
+		taken  = `SELECT COUNT(*) > 0 FROM custom_fields WHERE name = ? AND id <> ?`
+		update = `UPDATE custom_fields SET name = ?, options = ? WHERE id = ?`
+	)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &model.ErrInvalidArgument{What: "name not found"}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	field, err := getCustomField(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptions(field.Type, options); err != nil {
+		return nil, err
+	}
+	if field.Type == model.FieldTypeEnum {
+		args := []interface{}{id}
+		for _, option := range options {
+			args = append(args, option)
+		}
+		var inUse bool
+		if err := tx.QueryRowContext(ctx, fmt.Sprintf(used, placeholders(len(options))), args...).Scan(&inUse); err != nil {
+			return nil, err
+		}
+		if inUse {
+			return nil, &model.ErrConflict{What: "a dropped option is in use"}
+		}
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, taken, name, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, &model.ErrConflict{What: fmt.Sprintf("field %q already exists", name)}
+	}
+
+	if options == nil {
+		options = []string{}
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, update, name, string(data), id); err != nil {
+		return nil, err
+	}
+
+	field, err = getCustomField(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// DeleteCustomField deletes the field together with its values.
+func (s *CustomFieldService) DeleteCustomField(ctx context.Context, id int64) error {
+	const deleteOne = `DELETE FROM custom_fields WHERE id = ?`
+
+	ret, err := s.db.ExecContext(ctx, deleteOne, id)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return nil
+}
+
+// A fieldValue is a value of a custom field as stored: in the column of its
+// type, with a key that sorts values of the field byte by byte.
+type fieldValue struct {
+	column string
+	value  interface{}
+	key    string
+}
+
+// newFieldValue checks v, as decoded from JSON, against the field.
+func newFieldValue(field *model.CustomField, v interface{}) (*fieldValue, error) {
+	invalid := &model.ErrInvalidArgument{What: fmt.Sprintf("invalid value of %s field %q: %v", field.Type, field.Name, v)}
+
+	switch field.Type {
+	case model.FieldTypeText:
+		if s, ok := v.(string); ok {
+			return &fieldValue{column: "text_value", value: s, key: fieldSortKey(s)}, nil
+		}
+	case model.FieldTypeEnum:
+		if s, ok := v.(string); ok {
+			for _, option := range field.Options {
+				if s == option {
+					return &fieldValue{column: "text_value", value: s, key: fieldSortKey(s)}, nil
+				}
+			}
+		}
+	case model.FieldTypeNumber:
+		if f, ok := v.(float64); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return &fieldValue{column: "number_value", value: f, key: fieldSortKey(f)}, nil
+		}
+	case model.FieldTypeDate:
+		if s, ok := v.(string); ok {
+			if _, err := time.Parse(dateLayout, s); err == nil {
+				return &fieldValue{column: "date_value", value: s, key: fieldSortKey(s)}, nil
+			}
+		}
+	case model.FieldTypeBoolean:
+		if b, ok := v.(bool); ok {
+			return &fieldValue{column: "boolean_value", value: b, key: fieldSortKey(b)}, nil
+		}
+	}
+	return nil, invalid
+}
+
+// parseFieldValue checks s, as given in a query string, against the field.
+func parseFieldValue(field *model.CustomField, s string) (*fieldValue, error) {
+	var v interface{} = s
+	switch field.Type {
+	case model.FieldTypeNumber:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			v = f
+		}
+	case model.FieldTypeBoolean:
+		if b, err := strconv.ParseBool(s); err == nil {
+			v = b
+		}
+	}
+	return newFieldValue(field, v)
+}
+
+// fieldSortKey returns the sort key of a value as found in TODO.Fields, or
+// "" for no value. Numbers are keyed by their bits in hex, with the sign
+// bit flipped for positive numbers and every bit for negative ones.
+func fieldSortKey(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		if v == 0 {
+			// -0 sorts with 0
+			v = 0
+		}
+		bits := math.Float64bits(v)
+		if bits>>63 == 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		return fmt.Sprintf("%016x", bits)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return ""
+}
+
+// fieldSortPrefix starts the ListTODO sort keys of custom fields.
+const fieldSortPrefix = "field."
+
+// fieldSort returns the order of ListTODO by a custom field, and the sort
+// key naming the field as defined.
+func fieldSort(ctx context.Context, q queryer, name string) (todoSort, string, error) {
+	const column = `COALESCE((SELECT sort_key FROM todo_field_values WHERE todo_id = todos.id AND field_id = %d), '')`
+
+	field, err := findCustomField(ctx, q, strings.TrimPrefix(name, fieldSortPrefix))
+	if err != nil {
+		return todoSort{}, "", err
+	}
+	return todoSort{column: fmt.Sprintf(column, field.ID)}, fieldSortPrefix + field.Name, nil
+}
+
+// whereFields adds the conditions of ReadTODORequest.Fields to q.
+func whereFields(ctx context.Context, db queryer, q *selectQuery, filters map[string][]string) error {
+	const hasValue = `id IN (SELECT todo_id FROM todo_field_values WHERE field_id = ? AND %s IN (%s))`
+
+	for name, values := range filters {
+		field, err := findCustomField(ctx, db, name)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		args := []interface{}{field.ID}
+		var column string
+		for _, s := range values {
+			value, err := parseFieldValue(field, s)
+			if err != nil {
+				return err
+			}
+			column = value.column
+			args = append(args, value.value)
+		}
+		q.Where(fmt.Sprintf(hasValue, column, placeholders(len(values))), args...)
+	}
+	return nil
+}
+
+// setTODOFields writes the custom field values of the TODO within tx. A nil
+// value clears the field.
+func setTODOFields(ctx context.Context, tx *sql.Tx, id int64, values map[string]interface{}) error {
+	const (
+		touch  = `UPDATE todos SET updated_at = DATETIME('now') WHERE id = ? AND deleted_at IS NULL`
+		clear  = `DELETE FROM todo_field_values WHERE todo_id = ? AND field_id = ?`
+		insert = `INSERT INTO todo_field_values(todo_id, field_id, %s, sort_key) VALUES(?, ?, ?, ?)`
+	)
+
+	ret, err := tx.ExecContext(ctx, touch, id)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+
+	for name, v := range values {
+		field, err := findCustomField(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, clear, id, field.ID); err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+		value, err := newFieldValue(field, v)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(insert, value.column), id, field.ID, value.value, value.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillFields sets the Fields of the TODOs in byID.
+func fillFields(ctx context.Context, q queryer, byID map[int64]*model.TODO, ids []interface{}) error {
+	query := `SELECT todo_field_values.todo_id, custom_fields.name,
+			todo_field_values.text_value, todo_field_values.number_value, todo_field_values.date_value, todo_field_values.boolean_value
+		FROM todo_field_values JOIN custom_fields ON custom_fields.id = todo_field_values.field_id
+		WHERE todo_field_values.todo_id IN (` + placeholders(len(ids)) + `)`
+
+	for _, todo := range byID {
+		todo.Fields = map[string]interface{}{}
+	}
+
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id      int64
+			name    string
+			text    sql.NullString
+			number  sql.NullFloat64
+			date    sql.NullString
+			boolean sql.NullBool
+		)
+		if err := rows.Scan(&id, &name, &text, &number, &date, &boolean); err != nil {
+			return err
+		}
+		var v interface{}
+		switch {
+		case text.Valid:
+			v = text.String
+		case number.Valid:
+			v = number.Float64
+		case date.Valid:
+			v = date.String
+		case boolean.Valid:
+			v = boolean.Bool
+		}
+		byID[id].Fields[name] = v
+	}
+	return rows.Err()
+}
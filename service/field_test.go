@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestCustomFields(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	todoSvc := service.NewTODOService(todoDB)
+	svc := service.NewCustomFieldService(todoDB)
+
+	t.Run("define", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			typ     string
+			options []string
+			wantErr error
+		}{
+			{name: "points", typ: model.FieldTypeNumber},
+			{name: "customer", typ: model.FieldTypeText},
+			{name: "severity", typ: model.FieldTypeEnum, options: []string{"low", "high"}},
+			{name: "reported", typ: model.FieldTypeDate},
+			{name: "urgent", typ: model.FieldTypeBoolean},
+			{name: "Points", typ: model.FieldTypeNumber, wantErr: &model.ErrConflict{}},
+			{name: "size", typ: "float", wantErr: &model.ErrInvalidArgument{}},
+			{name: "size", typ: model.FieldTypeEnum, wantErr: &model.ErrInvalidArgument{}},
+			{name: "size", typ: model.FieldTypeText, options: []string{"s"}, wantErr: &model.ErrInvalidArgument{}},
+		} {
+			tc := tc
+			t.Run(tc.name+" "+tc.typ, func(t *testing.T) {
+				_, err := svc.CreateCustomField(ctx, tc.name, tc.typ, tc.options)
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+			})
+		}
+	})
+
+	for _, fields := range []map[string]interface{}{
+		{"points": 3.0, "severity": "high", "urgent": true},
+		{"points": -1.5, "customer": "ACME", "reported": "2021-06-01"},
+		{"points": 10.0, "severity": "low"},
+		{},
+	} {
+		if _, err := todoSvc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "subject", Fields: fields}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(t *testing.T, req *model.ReadTODORequest) []int64 {
+		t.Helper()
+		ids := []int64{}
+		for {
+			ret, err := todoSvc.ListTODO(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, todo := range ret.TODOs {
+				ids = append(ids, todo.ID)
+			}
+			if ret.NextCursor == "" {
+				return ids
+			}
+			req = &model.ReadTODORequest{Size: req.Size, Cursor: ret.NextCursor, Fields: req.Fields}
+		}
+	}
+
+	t.Run("values", func(t *testing.T) {
+		todo, err := todoSvc.GetTODO(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{"points": -1.5, "customer": "ACME", "reported": "2021-06-01"}
+		if !reflect.DeepEqual(todo.Fields, want) {
+			t.Fatal("expected: ", want, ", actual: ", todo.Fields)
+		}
+
+		for _, fields := range []map[string]interface{}{
+			{"points": "3"},
+			{"severity": "medium"},
+			{"reported": "June 1st"},
+			{"urgent": "yes"},
+			{"unknown": "value"},
+		} {
+			_, err := todoSvc.PatchTODO(ctx, &model.PatchTODORequest{ID: 2, Fields: fields})
+			if reflect.TypeOf(err) != reflect.TypeOf(&model.ErrInvalidArgument{}) {
+				t.Fatal("expected: *model.ErrInvalidArgument for ", fields, ", actual: ", err)
+			}
+		}
+
+		todo, err = todoSvc.PatchTODO(ctx, &model.PatchTODORequest{ID: 2, Fields: map[string]interface{}{"customer": nil, "Urgent": false}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = map[string]interface{}{"points": -1.5, "urgent": false, "reported": "2021-06-01"}
+		if !reflect.DeepEqual(todo.Fields, want) {
+			t.Fatal("expected: ", want, ", actual: ", todo.Fields)
+		}
+
+		// values are part of the history of the TODO
+		revisions, err := todoSvc.ReadTODOHistory(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		todo, err = todoSvc.RevertTODO(ctx, 2, revisions[0].Revision, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = map[string]interface{}{"points": -1.5, "customer": "ACME", "reported": "2021-06-01"}
+		if !reflect.DeepEqual(todo.Fields, want) {
+			t.Fatal("expected: ", want, ", actual: ", todo.Fields)
+		}
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			fields map[string][]string
+			want   []int64
+		}{
+			{name: "number", fields: map[string][]string{"points": {"10", "-1.5"}}, want: []int64{3, 2}},
+			{name: "enum", fields: map[string][]string{"severity": {"high"}}, want: []int64{1}},
+			{name: "text", fields: map[string][]string{"customer": {"ACME"}}, want: []int64{2}},
+			{name: "both", fields: map[string][]string{"points": {"3", "10"}, "severity": {"low"}}, want: []int64{3}},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				if ids := list(t, &model.ReadTODORequest{Fields: tc.fields}); !reflect.DeepEqual(ids, tc.want) {
+					t.Fatal("expected: ", tc.want, ", actual: ", ids)
+				}
+			})
+		}
+		_, err := todoSvc.ListTODO(ctx, &model.ReadTODORequest{Fields: map[string][]string{"severity": {"medium"}}})
+		if reflect.TypeOf(err) != reflect.TypeOf(&model.ErrInvalidArgument{}) {
+			t.Fatal("expected: *model.ErrInvalidArgument, actual: ", err)
+		}
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		for _, tc := range []struct {
+			sort, order string
+			want        []int64
+		}{
+			{sort: "field.points", want: []int64{4, 2, 1, 3}},
+			{sort: "field.Points", order: "desc", want: []int64{3, 1, 2, 4}},
+			{sort: "field.severity", want: []int64{2, 4, 1, 3}},
+		} {
+			tc := tc
+			t.Run(tc.sort+" "+tc.order, func(t *testing.T) {
+				ids := list(t, &model.ReadTODORequest{Sort: tc.sort, Order: tc.order, Size: 1})
+				if !reflect.DeepEqual(ids, tc.want) {
+					t.Fatal("expected: ", tc.want, ", actual: ", ids)
+				}
+			})
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		field, err := svc.UpdateCustomField(ctx, 3, "Severity", []string{"low", "medium", "high"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if field.Name != "Severity" || len(field.Options) != 3 {
+			t.Fatal("expected: Severity with 3 options, actual: ", field)
+		}
+		if _, err := svc.UpdateCustomField(ctx, 3, "Severity", []string{"medium", "high"}); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrConflict{}) {
+			t.Fatal("expected: *model.ErrConflict, actual: ", err)
+		}
+		if _, err := svc.UpdateCustomField(ctx, 3, "points", []string{"low", "high"}); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrConflict{}) {
+			t.Fatal("expected: *model.ErrConflict, actual: ", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := svc.DeleteCustomField(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.DeleteCustomField(ctx, 1); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		todo, err := todoSvc.GetTODO(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{"Severity": "high", "urgent": true}
+		if !reflect.DeepEqual(todo.Fields, want) {
+			t.Fatal("expected: ", want, ", actual: ", todo.Fields)
+		}
+	})
+}
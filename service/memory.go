@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A MemoryTODORepository keeps TODO entities in memory. It is safe for
+// concurrent use.
+//
+// Like PostgresTODORepository it keeps the columns of TODOs only; see
+// patchColumns. Deleted TODOs are gone rather than in the trash.
+type MemoryTODORepository struct {
+	mu sync.RWMutex
+	// todos are replaced on every write, never modified, so that the
+	// copy of a unit of work can share them.
+	todos        map[int64]*model.TODO
+	lastID       int64
+	lastPosition string
+	cursorSecret []byte
+	now          func() time.Time
+}
+
+// NewMemoryTODORepository returns new empty MemoryTODORepository.
+func NewMemoryTODORepository() *MemoryTODORepository {
+	return &MemoryTODORepository{
+		todos:        map[int64]*model.TODO{},
+		cursorSecret: randomCursorSecret(),
+		now:          time.Now,
+	}
+}
+
+// memoryTime returns t the way it reads back from DB: in UTC, to the second.
+func memoryTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// copyTODO returns a copy of todo that can be changed without changing it.
+func copyTODO(todo *model.TODO) *model.TODO {
+	c := *todo
+	c.Tags = []string{}
+	c.Fields = map[string]interface{}{}
+	return &c
+}
+
+// CreateTODOFrom creates a TODO with every field of req.
+func (r *MemoryTODORepository) CreateTODOFrom(ctx context.Context, req *model.CreateTODORequest) (*model.TODO, error) {
+	if req.Subject == "" {
+		return nil, &model.ErrInvalidArgument{What: "subject not found"}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := memoryTime(r.now())
+	todo := &model.TODO{
+		ID:          r.lastID + 1,
+		Subject:     req.Subject,
+		Description: req.Description,
+		Status:      model.TODOStatusOpen,
+		TimeZone:    "UTC",
+		Position:    rankBetween(r.lastPosition, ""),
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	// everything but subject and description is set like a patch would
+	todo, err := r.applyPatch(todo, createPatch(todo.ID, req))
+	if err != nil {
+		return nil, err
+	}
+
+	r.todos[todo.ID] = todo
+	r.lastID = todo.ID
+	r.lastPosition = todo.Position
+	return copyTODO(todo), nil
+}
+
+// GetTODO reads the TODO by id.
+func (r *MemoryTODORepository) GetTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+	return copyTODO(todo), nil
+}
+
+// ListTODO reads a page of TODOs matching the filters of req, sorted the
+// way TODOService.ListTODO does.
+func (r *MemoryTODORepository) ListTODO(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	// deleted TODOs are gone rather than in a trash
+	if req.Trashed {
+		return nil, unsupported("trash")
+	}
+	name, desc, cur, err := readOrder(r.cursorSecret, req)
+	if err != nil {
+		return nil, err
+	}
+	// the page before a cursor is read in reverse
+	reverse := desc != (cur != nil && cur.Prev)
+
+	statuses := make(map[string]bool, len(req.Statuses))
+	for _, status := range req.Statuses {
+		statuses[status] = true
+	}
+
+	// after reports whether todo comes after the cursor in reading order
+	after := func(todo *model.TODO) bool {
+		if key := newCursor(todo, name, desc, false).Key; key != cur.Key {
+			return key > cur.Key != reverse
+		}
+		return todo.ID != cur.ID && todo.ID > cur.ID != reverse
+	}
+
+	r.mu.RLock()
+	todos := make([]*model.TODO, 0)
+	for _, todo := range r.todos {
+		switch {
+		case len(statuses) > 0 && !statuses[todo.Status]:
+		case !req.CreatedAfter.IsZero() && todo.CreatedAt.Before(memoryTime(req.CreatedAfter)):
+		case !req.CreatedBefore.IsZero() && !todo.CreatedAt.Before(memoryTime(req.CreatedBefore)):
+		case !req.UpdatedAfter.IsZero() && todo.UpdatedAt.Before(memoryTime(req.UpdatedAfter)):
+		case !req.UpdatedBefore.IsZero() && !todo.UpdatedAt.Before(memoryTime(req.UpdatedBefore)):
+		case !strings.HasPrefix(asciiLower(todo.Subject), asciiLower(req.SubjectPrefix)):
+		case req.PrevID != 0 && desc && todo.ID >= req.PrevID:
+		case req.PrevID != 0 && !desc && todo.ID <= req.PrevID:
+		case cur != nil && !after(todo):
+		default:
+			todos = append(todos, copyTODO(todo))
+		}
+	}
+	r.mu.RUnlock()
+
+	keys := make(map[int64]string, len(todos))
+	for _, todo := range todos {
+		keys[todo.ID] = newCursor(todo, name, desc, false).Key
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		a, b := todos[i], todos[j]
+		if keys[a.ID] != keys[b.ID] {
+			return keys[a.ID] < keys[b.ID] != reverse
+		}
+		return a.ID < b.ID != reverse
+	})
+	// one extra TODO tells whether there is a further page
+	if req.Size > 0 && int64(len(todos)) > req.Size+1 {
+		todos = todos[:req.Size+1]
+	}
+	return newTODOPage(r.cursorSecret, todos, req.Size, name, desc, cur, req.PrevID), nil
+}
+
+// PatchTODO applies a merge patch to the TODO.
+func (r *MemoryTODORepository) PatchTODO(ctx context.Context, patch *model.PatchTODORequest) (*model.TODO, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[patch.ID]
+	if !ok {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+	todo, err := r.applyPatch(todo, patch)
+	if err != nil {
+		return nil, err
+	}
+	r.todos[todo.ID] = todo
+	return copyTODO(todo), nil
+}
+
+// applyPatch returns a copy of todo with patch applied.
+func (r *MemoryTODORepository) applyPatch(todo *model.TODO, patch *model.PatchTODORequest) (*model.TODO, error) {
+	now := memoryTime(r.now())
+	todo, written, err := patchColumns(todo, patch, now)
+	if err != nil {
+		return nil, err
+	}
+	if written {
+		todo.Version++
+		todo.UpdatedAt = now
+	}
+	return todo, nil
+}
+
+// DeleteTODO deletes the TODOs by ids.
+func (r *MemoryTODORepository) DeleteTODO(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := false
+	for _, id := range ids {
+		if _, ok := r.todos[id]; ok {
+			delete(r.todos, id)
+			deleted = true
+		}
+	}
+	if !deleted {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return nil
+}
+
+// DeleteTODOIfMatch deletes the TODO only if it is still at version. A
+// version of 0 matches any version.
+func (r *MemoryTODORepository) DeleteTODOIfMatch(ctx context.Context, id, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	if version != 0 && version != todo.Version {
+		return &model.ErrVersionMismatch{Expected: version, Actual: todo.Version}
+	}
+	delete(r.todos, id)
+	return nil
+}
+
+// Transaction runs fn on a copy of the repository, which replaces it when
+// fn returns nil. Everybody else waits until fn returns.
+func (r *MemoryTODORepository) Transaction(ctx context.Context, fn func(repo TODORepository) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unit := &MemoryTODORepository{
+		todos:        make(map[int64]*model.TODO, len(r.todos)),
+		lastID:       r.lastID,
+		lastPosition: r.lastPosition,
+		cursorSecret: r.cursorSecret,
+		now:          r.now,
+	}
+	for id, todo := range r.todos {
+		unit.todos[id] = todo
+	}
+	if err := fn(todoUnit{unit}); err != nil {
+		return err
+	}
+
+	r.todos = unit.todos
+	r.lastID = unit.lastID
+	r.lastPosition = unit.lastPosition
+	return nil
+}
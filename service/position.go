@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// lastPosition returns the position that puts a new TODO after every other
+// one in manual order.
+func lastPosition(ctx context.Context, q queryer) (string, error) {
+	const read = `SELECT COALESCE(MAX(position), '') FROM todos`
+
+	var last string
+	if err := q.QueryRowContext(ctx, read).Scan(&last); err != nil {
+		return "", err
+	}
+	return rankBetween(last, ""), nil
+}
+
+// MoveTODO places the TODO right before or after another one in manual
+// order, i.e. by position ascending. Exactly one of before and after must be
+// non-zero. Only the moved TODO is written.
+func (s *TODOService) MoveTODO(ctx context.Context, id, before, after int64) (*model.TODO, error) {
+	const (
+		readPosition = `SELECT position FROM todos WHERE id = ? AND deleted_at IS NULL`
+		readPrev     = `SELECT COALESCE(MAX(position), '') FROM todos WHERE position < ? AND id <> ?`
+		readNext     = `SELECT COALESCE(MIN(position), '') FROM todos WHERE position > ? AND id <> ?`
+		update       = `UPDATE todos SET position = ? WHERE id = ?`
+	)
+
+	if (before == 0) == (after == 0) {
+		return nil, &model.ErrInvalidArgument{What: "either before or after must be given"}
+	}
+	anchor := before + after
+	if anchor == id {
+		return nil, &model.ErrInvalidArgument{What: "cannot move a TODO next to itself"}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := getTODO(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	var position string
+	if err := tx.QueryRowContext(ctx, readPosition, anchor).Scan(&position); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrInvalidArgument{What: "TODO to move next to not found"}
+		}
+		return nil, err
+	}
+
+	// the neighbour on the other side of the anchor bounds the new rank
+	lower, upper := position, ""
+	neighbour := readNext
+	if before != 0 {
+		lower, upper = "", position
+		neighbour = readPrev
+	}
+	var bound string
+	if err := tx.QueryRowContext(ctx, neighbour, position, id).Scan(&bound); err != nil {
+		return nil, err
+	}
+	if before != 0 {
+		lower = bound
+	} else {
+		upper = bound
+	}
+
+	if _, err := tx.ExecContext(ctx, update, rankBetween(lower, upper), id); err != nil {
+		return nil, err
+	}
+	if err := recordRevisions(ctx, tx, model.TODOActionUpdate, id); err != nil {
+		return nil, err
+	}
+	todo, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
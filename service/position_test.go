@@ -0,0 +1,112 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestMoveTODO(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	for _, subject := range []string{"a", "b", "c", "d"} {
+		if _, err := svc.CreateTODO(ctx, subject, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(t *testing.T, req *model.ReadTODORequest) ([]int64, *model.ReadTODOResponse) {
+		t.Helper()
+		ret, err := svc.ListTODO(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range ret.TODOs {
+			ids = append(ids, todo.ID)
+		}
+		return ids, ret
+	}
+
+	testcase := []struct {
+		name    string
+		id      int64
+		before  int64
+		after   int64
+		wantErr interface{}
+		wantIDs []int64
+	}{
+		{name: "created last", wantIDs: []int64{1, 2, 3, 4}},
+		{name: "before first", id: 4, before: 1, wantIDs: []int64{4, 1, 2, 3}},
+		{name: "after last", id: 1, after: 3, wantIDs: []int64{4, 2, 3, 1}},
+		{name: "between", id: 1, before: 2, wantIDs: []int64{4, 1, 2, 3}},
+		{name: "between again", id: 3, after: 4, wantIDs: []int64{4, 3, 1, 2}},
+		{name: "both", id: 1, before: 2, after: 3, wantErr: &model.ErrInvalidArgument{}},
+		{name: "neither", id: 1, wantErr: &model.ErrInvalidArgument{}},
+		{name: "itself", id: 1, before: 1, wantErr: &model.ErrInvalidArgument{}},
+		{name: "unknown anchor", id: 1, before: 9999, wantErr: &model.ErrInvalidArgument{}},
+		{name: "unknown", id: 9999, before: 1, wantErr: &model.ErrNotFound{}},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.id != 0 || tc.wantErr != nil {
+				_, err := svc.MoveTODO(ctx, tc.id, tc.before, tc.after)
+				if tc.wantErr != nil {
+					if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+						t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if ids, _ := list(t, &model.ReadTODORequest{Sort: "position"}); !reflect.DeepEqual(ids, tc.wantIDs) {
+				t.Fatal("expected: ", tc.wantIDs, ", actual: ", ids)
+			}
+		})
+	}
+
+	t.Run("priority then position", func(t *testing.T) {
+		for id, priority := range map[int64]int64{1: model.TODOPriorityHigh, 2: model.TODOPriorityHigh, 4: model.TODOPriorityLow} {
+			_, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: id, Priority: model.OptionalInt64{Set: true, Value: priority}})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ids, first := list(t, &model.ReadTODORequest{Sort: "priority", Size: 3})
+		if !reflect.DeepEqual(ids, []int64{1, 2, 4}) || first.NextCursor == "" {
+			t.Fatal("unexpected first page: ", ids)
+		}
+		ids, _ = list(t, &model.ReadTODORequest{Cursor: first.NextCursor, Size: 3})
+		if !reflect.DeepEqual(ids, []int64{3}) {
+			t.Fatal("unexpected second page: ", ids)
+		}
+		ids, _ = list(t, &model.ReadTODORequest{Sort: "priority", Order: "desc"})
+		if !reflect.DeepEqual(ids, []int64{3, 4, 2, 1}) {
+			t.Fatal("unexpected reversed order: ", ids)
+		}
+	})
+
+	t.Run("invalid priority", func(t *testing.T) {
+		_, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: 1, Priority: model.OptionalInt64{Set: true, Value: 4}})
+		if _, ok := err.(*model.ErrInvalidArgument); !ok {
+			t.Fatal("expected: ErrInvalidArgument, actual: ", err)
+		}
+	})
+}
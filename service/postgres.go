@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A PostgresTODORepository stores TODO entities in the PostgreSQL schema of
+// db.NewPostgresDB.
+//
+// Like MemoryTODORepository it keeps the columns of TODOs only; see
+// patchColumns. Deleted TODOs are kept in the trash, as TODOService does,
+// until RestoreTODO or PurgeTrash.
+type PostgresTODORepository struct {
+	db *sql.DB
+	// tx is set on the PostgresTODORepository handed to a Transaction
+	// function.
+	tx           *sql.Tx
+	cursorSecret []byte
+	now          func() time.Time
+}
+
+// NewPostgresTODORepository returns new PostgresTODORepository.
+func NewPostgresTODORepository(db *sql.DB) *PostgresTODORepository {
+	return &PostgresTODORepository{
+		db:           db,
+		cursorSecret: randomCursorSecret(),
+		now:          time.Now,
+	}
+}
+
+// SetCursorSecret sets the key cursors are signed with, the way
+// TODOService.SetCursorSecret does.
+func (r *PostgresTODORepository) SetCursorSecret(secret []byte) {
+	r.cursorSecret = secret
+}
+
+// postgresTODOColumns selects what scanTODO scans, with the columns
+// PostgresTODORepository does not keep left empty.
+const postgresTODOColumns = `id, subject, description, status, completed_at, NULL::BIGINT, NULL::BIGINT, start_at, due_at, '', time_zone, NULL::BIGINT, priority, position, deleted_at, version, created_at, updated_at`
+
+// postgresSorts maps the sort keys of todoSorts to their PostgreSQL
+// expressions, comparing text byte by byte as SQLite does.
+var postgresSorts = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"position":   "position",
+	"priority":   `(CAST(3 - priority AS TEXT) || position) COLLATE "C"`,
+}
+
+// postgresQuery rewrites the ? placeholders of query, which must not
+// appear anywhere else, to the numbered ones of PostgreSQL.
+func postgresQuery(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// postgresTime returns t formatted for a TIMESTAMP column, or nil when t is
+// nil.
+func postgresTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+// Transaction runs fn in a single DB transaction, committed when fn
+// returns nil.
+func (r *PostgresTODORepository) Transaction(ctx context.Context, fn func(repo TODORepository) error) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		unit := *r
+		unit.tx = tx
+		return fn(todoUnit{&unit})
+	})
+}
+
+// withTx runs fn in a new transaction committed when fn returns nil, or in
+// the transaction of the unit of work r belongs to.
+func (r *PostgresTODORepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// conn returns what r reads with: the transaction of its unit of work, if
+// any, or else the DB.
+func (r *PostgresTODORepository) conn() queryer {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// scanPostgresTODO scans a row selected with postgresTODOColumns. lib/pq
+// reads TIMESTAMP columns in a zone of its own, so they are put in UTC.
+func scanPostgresTODO(row rowScanner) (*model.TODO, error) {
+	todo, err := scanTODO(row)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range []*time.Time{todo.CompletedAt, todo.StartAt, todo.DueAt, todo.DeletedAt, &todo.CreatedAt, &todo.UpdatedAt} {
+		if t != nil {
+			*t = t.UTC()
+		}
+	}
+	return copyTODO(todo), nil
+}
+
+// getPostgresTODO reads the TODO by id unless it is in the trash, locking
+// its row when forUpdate is set.
+func getPostgresTODO(ctx context.Context, q queryer, id int64, forUpdate bool) (*model.TODO, error) {
+	read := `SELECT ` + postgresTODOColumns + ` FROM todos WHERE id = $1 AND deleted_at IS NULL`
+	if forUpdate {
+		read += ` FOR UPDATE`
+	}
+
+	todo, err := scanPostgresTODO(q.QueryRowContext(ctx, read, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	return todo, nil
+}
+
+// writePostgresTODO writes the columns patchColumns changes.
+func writePostgresTODO(ctx context.Context, tx *sql.Tx, todo *model.TODO) error {
+	const update = `UPDATE todos SET subject = $1, description = $2, status = $3, completed_at = $4,
+		start_at = $5, due_at = $6, time_zone = $7, priority = $8 WHERE id = $9`
+
+	_, err := tx.ExecContext(ctx, update, todo.Subject, todo.Description, todo.Status, postgresTime(todo.CompletedAt),
+		postgresTime(todo.StartAt), postgresTime(todo.DueAt), todo.TimeZone, todo.Priority, todo.ID)
+	return err
+}
+
+// CreateTODOFrom creates a TODO with every field of req, in a single
+// transaction.
+func (r *PostgresTODORepository) CreateTODOFrom(ctx context.Context, req *model.CreateTODORequest) (*model.TODO, error) {
+	const (
+		// positions are handed out one creation at a time
+		lock     = `LOCK TABLE todos IN SHARE ROW EXCLUSIVE MODE`
+		readLast = `SELECT COALESCE(MAX(position), '') FROM todos`
+		insert   = `INSERT INTO todos(subject, description, position) VALUES($1, $2, $3) RETURNING id`
+	)
+
+	if req.Subject == "" {
+		return nil, &model.ErrInvalidArgument{What: "subject not found"}
+	}
+	// invalid requests are refused before taking an id, since sequences are
+	// not rolled back
+	blank := &model.TODO{Status: model.TODOStatusOpen}
+	if _, _, err := patchColumns(blank, createPatch(0, req), r.now()); err != nil {
+		return nil, err
+	}
+
+	var todo *model.TODO
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, lock); err != nil {
+			return err
+		}
+		var last string
+		if err := tx.QueryRowContext(ctx, readLast).Scan(&last); err != nil {
+			return err
+		}
+		var id int64
+		if err := tx.QueryRowContext(ctx, insert, req.Subject, req.Description, rankBetween(last, "")).Scan(&id); err != nil {
+			return err
+		}
+
+		// everything but subject and description is set like a patch would
+		var err error
+		todo, err = r.applyPatch(ctx, tx, createPatch(id, req))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// GetTODO reads the TODO by id.
+func (r *PostgresTODORepository) GetTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	return getPostgresTODO(ctx, r.conn(), id, false)
+}
+
+// ListTODO reads a page of TODOs matching the filters of req, sorted the
+// way TODOService.ListTODO does.
+func (r *PostgresTODORepository) ListTODO(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	name, desc, cur, err := readOrder(r.cursorSecret, req)
+	if err != nil {
+		return nil, err
+	}
+	column := postgresSorts[name]
+	// the page before a cursor is read in reverse
+	reverse := desc != (cur != nil && cur.Prev)
+
+	q := newSelectQuery(postgresTODOColumns, "todos")
+	if req.Trashed {
+		q.Where("deleted_at IS NOT NULL")
+	} else {
+		q.Where("deleted_at IS NULL")
+	}
+	if req.PrevID != 0 {
+		if desc {
+			q.Where("id < ?", req.PrevID)
+		} else {
+			q.Where("id > ?", req.PrevID)
+		}
+	}
+	if cur != nil {
+		op := ">"
+		if reverse {
+			op = "<"
+		}
+		if column == "id" {
+			q.Where("id "+op+" ?", cur.ID)
+		} else {
+			q.Where("("+column+", id) "+op+" (?, ?)", cur.Key, cur.ID)
+		}
+	}
+
+	statuses := make([]interface{}, 0, len(req.Statuses))
+	for _, status := range req.Statuses {
+		statuses = append(statuses, status)
+	}
+	q.WhereIn("status", statuses...)
+
+	if !req.CreatedAfter.IsZero() {
+		q.Where("created_at >= ?", formatTime(req.CreatedAfter))
+	}
+	if !req.CreatedBefore.IsZero() {
+		q.Where("created_at < ?", formatTime(req.CreatedBefore))
+	}
+	if !req.UpdatedAfter.IsZero() {
+		q.Where("updated_at >= ?", formatTime(req.UpdatedAfter))
+	}
+	if !req.UpdatedBefore.IsZero() {
+		q.Where("updated_at < ?", formatTime(req.UpdatedBefore))
+	}
+	if req.SubjectPrefix != "" {
+		q.Where(`subject ILIKE ? ESCAPE '\'`, escapeLike(req.SubjectPrefix)+"%")
+	}
+
+	q.OrderBy(column, reverse)
+	if column != "id" {
+		q.OrderBy("id", reverse)
+	}
+	// one extra row tells whether there is a further page
+	if req.Size > 0 {
+		q.Limit(req.Size + 1)
+	}
+
+	query, args := q.Build()
+	if req.Size == 0 {
+		// LIMIT NULL reads every row, while a negative limit is an error
+		args[len(args)-1] = nil
+	}
+	rows, err := r.conn().QueryContext(ctx, postgresQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := make([]*model.TODO, 0)
+	for rows.Next() {
+		todo, err := scanPostgresTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newTODOPage(r.cursorSecret, todos, req.Size, name, desc, cur, req.PrevID), nil
+}
+
+// PatchTODO applies a merge patch to the TODO.
+func (r *PostgresTODORepository) PatchTODO(ctx context.Context, patch *model.PatchTODORequest) (*model.TODO, error) {
+	var todo *model.TODO
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		todo, err = r.applyPatch(ctx, tx, patch)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// applyPatch writes patch to the TODO within tx and returns the TODO
+// written.
+func (r *PostgresTODORepository) applyPatch(ctx context.Context, tx *sql.Tx, patch *model.PatchTODORequest) (*model.TODO, error) {
+	todo, err := getPostgresTODO(ctx, tx, patch.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	todo, written, err := patchColumns(todo, patch, memoryTime(r.now()))
+	if err != nil {
+		return nil, err
+	}
+	if !written {
+		return todo, nil
+	}
+	if err := writePostgresTODO(ctx, tx, todo); err != nil {
+		return nil, err
+	}
+	return getPostgresTODO(ctx, tx, patch.ID, false)
+}
+
+// DeleteTODO moves the TODOs by ids to the trash.
+func (r *PostgresTODORepository) DeleteTODO(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{formatTime(r.now())}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	query := postgresQuery(`UPDATE todos SET deleted_at = ? WHERE id IN (` + placeholders(len(ids)) + `) AND deleted_at IS NULL`)
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		ret, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err := ret.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return &model.ErrNotFound{What: "data not found"}
+		}
+		return nil
+	})
+}
+
+// DeleteTODOIfMatch moves the TODO to the trash only if it is still at
+// version. A version of 0 matches any version.
+func (r *PostgresTODORepository) DeleteTODOIfMatch(ctx context.Context, id, version int64) error {
+	const (
+		trashOne = `UPDATE todos SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)`
+		read     = `SELECT version FROM todos WHERE id = $1 AND deleted_at IS NULL`
+	)
+
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		ret, err := tx.ExecContext(ctx, trashOne, formatTime(r.now()), id, version)
+		if err != nil {
+			return err
+		}
+		affected, err := ret.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected > 0 {
+			return nil
+		}
+
+		var actual int64
+		if err := tx.QueryRowContext(ctx, read, id).Scan(&actual); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &model.ErrNotFound{What: err.Error()}
+			}
+			return err
+		}
+		return &model.ErrVersionMismatch{Expected: version, Actual: actual}
+	})
+}
+
+// RestoreTODO takes the TODO out of the trash.
+func (r *PostgresTODORepository) RestoreTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	const restore = `UPDATE todos SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	var todo *model.TODO
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		ret, err := tx.ExecContext(ctx, restore, id)
+		if err != nil {
+			return err
+		}
+		affected, err := ret.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return &model.ErrNotFound{What: "data not found"}
+		}
+		todo, err = getPostgresTODO(ctx, tx, id, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// PurgeTrash permanently deletes the TODOs moved to the trash before the
+// given time and reports how many there were.
+func (r *PostgresTODORepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	const purge = `DELETE FROM todos WHERE deleted_at < $1`
+
+	ret, err := r.conn().ExecContext(ctx, purge, formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return ret.RowsAffected()
+}
+
+// RunPurger purges the TODOs that have been in the trash longer than
+// retention every interval, until ctx is done.
+func (r *PostgresTODORepository) RunPurger(ctx context.Context, retention, interval time.Duration) {
+	runPurger(ctx, r.PurgeTrash, r.now, retention, interval)
+}
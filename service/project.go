@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A ProjectService implements CRUD of Project entities.
+type ProjectService struct {
+	db *sql.DB
+}
+
+// NewProjectService returns new ProjectService.
+func NewProjectService(db *sql.DB) *ProjectService {
+	return &ProjectService{
+		db: db,
+	}
+}
+
+const projectColumns = `id, name, description, archived_at, created_at, updated_at`
+
+// scanProject reads a Project selected with projectColumns.
+func scanProject(row rowScanner) (*model.Project, error) {
+	var (
+		project    model.Project
+		archivedAt sql.NullTime
+	)
+	err := row.Scan(&project.ID, &project.Name, &project.Description, &archivedAt, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		project.ArchivedAt = &archivedAt.Time
+	}
+	return &project, nil
+}
+
+// getProject reads the Project with q, which may be a transaction.
+func getProject(ctx context.Context, q queryer, id int64) (*model.Project, error) {
+	const read = `SELECT ` + projectColumns + ` FROM projects WHERE id = ?`
+
+	project, err := scanProject(q.QueryRowContext(ctx, read, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	return project, nil
+}
+
+// checkProject reports whether TODOs can be put into the project.
+func checkProject(ctx context.Context, q queryer, id int64) error {
+	const read = `SELECT archived_at IS NOT NULL FROM projects WHERE id = ?`
+
+	var archived bool
+	if err := q.QueryRowContext(ctx, read, id).Scan(&archived); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ErrInvalidArgument{What: "project not found"}
+		}
+		return err
+	}
+	if archived {
+		return &model.ErrInvalidArgument{What: "project is archived"}
+	}
+	return nil
+}
+
+// CreateProject creates a Project on DB.
+func (s *ProjectService) CreateProject(ctx context.Context, name, description string) (*model.Project, error) {
+	const insert = `INSERT INTO projects(name, description) VALUES(?, ?)`
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &model.ErrInvalidArgument{What: "name not found"}
+	}
+
+	ret, err := s.db.ExecContext(ctx, insert, name, description)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return getProject(ctx, s.db, id)
+}
+
+// ReadProject reads Projects on DB ordered by name. Archived ones are left
+// out unless includeArchived.
+func (s *ProjectService) ReadProject(ctx context.Context, includeArchived bool) ([]*model.Project, error) {
+	q := newSelectQuery(projectColumns, "projects")
+	if !includeArchived {
+		q.Where("archived_at IS NULL")
+	}
+	q.OrderBy("name", false).OrderBy("id", false)
+	query, args := q.Build()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := make([]*model.Project, 0)
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// GetProject reads the Project on DB.
+func (s *ProjectService) GetProject(ctx context.Context, id int64) (*model.Project, error) {
+	return getProject(ctx, s.db, id)
+}
+
+// UpdateProject updates the Project on DB. It is archived or unarchived as
+// well unless archived is nil.
+func (s *ProjectService) UpdateProject(ctx context.Context, id int64, name, description string, archived *bool) (*model.Project, error) {
+	const update = `UPDATE projects SET name = ?, description = ?,
+		archived_at = CASE
+			WHEN ? IS NULL THEN archived_at
+			WHEN ? THEN COALESCE(archived_at, DATETIME('now'))
+			ELSE NULL
+		END
+		WHERE id = ?`
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &model.ErrInvalidArgument{What: "name not found"}
+	}
+
+	var archive sql.NullBool
+	if archived != nil {
+		archive = sql.NullBool{Bool: *archived, Valid: true}
+	}
+	ret, err := s.db.ExecContext(ctx, update, name, description, archive, archive, id)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+	return getProject(ctx, s.db, id)
+}
+
+// DeleteProject removes the Project as mode says, which is one of
+// model.ProjectDeleteArchive, model.ProjectDeleteCascade and
+// model.ProjectDeleteDetach. An empty mode archives.
+//
+// The TODOs of a project deleted with model.ProjectDeleteCascade are moved
+// to the trash together with their subtasks, the way DeleteTODO does, and
+// are restored without a project.
+func (s *ProjectService) DeleteProject(ctx context.Context, id int64, mode string) error {
+	const (
+		archive   = `UPDATE projects SET archived_at = COALESCE(archived_at, DATETIME('now')) WHERE id = ?`
+		deleteOne = `DELETE FROM projects WHERE id = ?`
+	)
+
+	switch mode {
+	case "", model.ProjectDeleteArchive, model.ProjectDeleteCascade, model.ProjectDeleteDetach:
+	default:
+		return &model.ErrInvalidArgument{What: "unknown delete mode: " + mode}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := getProject(ctx, tx, id); err != nil {
+		return err
+	}
+	switch mode {
+	case "", model.ProjectDeleteArchive:
+		_, err = tx.ExecContext(ctx, archive, id)
+	case model.ProjectDeleteCascade:
+		if err := trashProjectTODOs(ctx, tx, id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, deleteOne, id)
+	case model.ProjectDeleteDetach:
+		// project_id of the TODOs is cleared by ON DELETE SET NULL
+		_, err = tx.ExecContext(ctx, deleteOne, id)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// trashProjectTODOs moves the TODOs of the project to the trash within tx,
+// each recorded as deleted with the outermost of its ancestors in the
+// project, so that RestoreTODO brings back the subtasks along with it.
+func trashProjectTODOs(ctx context.Context, tx *sql.Tx, projectID int64) error {
+	query := withSubtree(`project_id = ? AND deleted_at IS NULL`, trashSubtree)
+	deleted, err := scanIDs(tx.QueryContext(ctx, query, projectID, formatTime(time.Now())))
+	if err != nil {
+		return err
+	}
+	if err := touchDependents(ctx, tx, deleted...); err != nil {
+		return err
+	}
+	return recordRevisions(ctx, tx, model.TODOActionDelete, deleted...)
+}
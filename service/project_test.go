@@ -0,0 +1,208 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestProjectService(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewProjectService(todoDB)
+	todoSvc := service.NewTODOService(todoDB)
+
+	projects := map[string]*model.Project{}
+	for _, name := range []string{"archive", "delete", "detach"} {
+		project, err := svc.CreateProject(ctx, name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		projects[name] = project
+		for _, subject := range []string{"foo", "bar"} {
+			_, err := todoSvc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: subject, ProjectID: &project.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("list TODOs of project", func(t *testing.T) {
+		ret, err := todoSvc.ListTODO(ctx, &model.ReadTODORequest{ProjectID: projects["delete"].ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range ret.TODOs {
+			ids = append(ids, todo.ID)
+		}
+		if !reflect.DeepEqual(ids, []int64{4, 3}) {
+			t.Fatal("unexpected ids: ", ids)
+		}
+	})
+
+	testcase := []struct {
+		name      string
+		mode      string
+		isError   bool
+		wantTODOs int
+		wantGone  bool
+	}{
+		{name: "archive", mode: model.ProjectDeleteArchive, wantTODOs: 2},
+		{name: "delete", mode: model.ProjectDeleteCascade, wantTODOs: 0, wantGone: true},
+		{name: "detach", mode: model.ProjectDeleteDetach, wantTODOs: 2, wantGone: true},
+		{name: "archive", mode: "unknown", isError: true},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.mode, func(t *testing.T) {
+			project := projects[tc.name]
+			err := svc.DeleteProject(ctx, project.ID, tc.mode)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case tc.isError:
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			_, err = svc.GetProject(ctx, project.ID)
+			if _, ok := err.(*model.ErrNotFound); ok != tc.wantGone {
+				t.Fatal("unexpected err: ", err)
+			}
+
+			var count int
+			if err := todoDB.QueryRow(`SELECT COUNT(*) FROM todos WHERE id IN (?, ?) AND deleted_at IS NULL`,
+				project.ID*2-1, project.ID*2).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count != tc.wantTODOs {
+				t.Fatal("expected: ", tc.wantTODOs, ", actual: ", count)
+			}
+		})
+	}
+
+	t.Run("deleted TODOs are in the trash", func(t *testing.T) {
+		todo, err := todoSvc.RestoreTODO(ctx, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.ProjectID != nil {
+			t.Fatal("expected: nil, actual: ", *todo.ProjectID)
+		}
+		// 4 was deleted on its own root, so it stays in the trash
+		if _, err := todoSvc.GetTODO(ctx, 4); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("deleted subtasks are restored with their root", func(t *testing.T) {
+		project, err := svc.CreateProject(ctx, "tree", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// root ─── child ─── grandchild, which is in no project
+		var ids []int64
+		for i := 0; i < 3; i++ {
+			req := &model.CreateTODORequest{Subject: "subject"}
+			if i < 2 {
+				req.ProjectID = &project.ID
+			}
+			if i > 0 {
+				req.ParentID = &ids[i-1]
+			}
+			todo, err := todoSvc.CreateTODOFrom(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, todo.ID)
+		}
+
+		if err := svc.DeleteProject(ctx, project.ID, model.ProjectDeleteCascade); err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range ids {
+			if _, err := todoSvc.GetTODO(ctx, id); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+				t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+			}
+		}
+		if _, err := todoSvc.RestoreTODO(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range ids {
+			if _, err := todoSvc.GetTODO(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("detached TODOs have no project", func(t *testing.T) {
+		todo, err := todoSvc.GetTODO(ctx, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.ProjectID != nil {
+			t.Fatal("expected: nil, actual: ", *todo.ProjectID)
+		}
+	})
+
+	t.Run("archived project is hidden and rejects TODOs", func(t *testing.T) {
+		list, err := svc.ReadProject(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 0 {
+			t.Fatal("expected: 0, actual: ", len(list))
+		}
+		list, err = svc.ReadProject(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].ArchivedAt == nil {
+			t.Fatal("unexpected projects: ", list)
+		}
+
+		_, err = todoSvc.PatchTODO(ctx, &model.PatchTODORequest{
+			ID:        5,
+			ProjectID: model.OptionalInt64{Set: true, Value: projects["archive"].ID},
+		})
+		if _, ok := err.(*model.ErrInvalidArgument); !ok {
+			t.Fatal("expected: ErrInvalidArgument, actual: ", err)
+		}
+
+		archived := false
+		if _, err := svc.UpdateProject(ctx, projects["archive"].ID, "archive", "", &archived); err != nil {
+			t.Fatal(err)
+		}
+		todo, err := todoSvc.PatchTODO(ctx, &model.PatchTODORequest{
+			ID:        5,
+			ProjectID: model.OptionalInt64{Set: true, Value: projects["archive"].ID},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.ProjectID == nil || *todo.ProjectID != projects["archive"].ID {
+			t.Fatal("unexpected project: ", todo.ProjectID)
+		}
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		missing := int64(9999)
+		_, err := todoSvc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "baz", ProjectID: &missing})
+		if _, ok := err.(*model.ErrInvalidArgument); !ok {
+			t.Fatal("expected: ErrInvalidArgument, actual: ", err)
+		}
+	})
+}
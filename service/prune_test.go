@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// TestPruneBlobsExcludesUploads checks that an upload does not store its
+// content while PruneBlobs is between checking a content is unused and
+// removing it, which would remove the content of the new attachment.
+func TestPruneBlobsExcludesUploads(t *testing.T) {
+	todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := NewAttachmentService(todoDB, t.TempDir(), 16)
+	todoSvc := NewTODOService(todoDB)
+	defer todoSvc.Close()
+	todo, err := todoSvc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as pruneBlob does
+	svc.blobs.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.CreateAttachment(ctx, todo.ID, "a.txt", strings.NewReader("hello"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		svc.blobs.Unlock()
+		t.Fatal("expected: the upload waits for the prune, actual: ", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	svc.blobs.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	as, err := svc.ListAttachment(ctx, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 {
+		t.Fatal("expected: 1 attachment, actual: ", len(as))
+	}
+	_, f, err := svc.OpenAttachment(ctx, todo.ID, as[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
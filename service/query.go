@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A selectQuery builds a SELECT statement. Only fragments written in this
+// package go into the SQL text; every value coming from a caller is passed
+// as a bound argument.
+type selectQuery struct {
+	columns string
+	from    string
+	where   []string
+	args    []interface{}
+	orderBy []string
+	limit   int64
+}
+
+// newSelectQuery returns a selectQuery reading columns from table.
+func newSelectQuery(columns, from string) *selectQuery {
+	return &selectQuery{
+		columns: columns,
+		from:    from,
+		limit:   -1,
+	}
+}
+
+// Where adds a condition. cond must use ? placeholders for args.
+func (q *selectQuery) Where(cond string, args ...interface{}) *selectQuery {
+	if n := strings.Count(cond, "?"); n != len(args) {
+		panic(fmt.Sprintf("selectQuery: %d placeholders for %d args in %q", n, len(args), cond))
+	}
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// WhereIn adds a condition that column is one of values. No condition is
+// added for empty values.
+func (q *selectQuery) WhereIn(column string, values ...interface{}) *selectQuery {
+	if len(values) == 0 {
+		return q
+	}
+	return q.Where(column+" IN ("+placeholders(len(values))+")", values...)
+}
+
+// OrderBy appends a sort key. desc selects descending order.
+func (q *selectQuery) OrderBy(column string, desc bool) *selectQuery {
+	if desc {
+		column += " DESC"
+	} else {
+		column += " ASC"
+	}
+	q.orderBy = append(q.orderBy, column)
+	return q
+}
+
+// Limit sets the maximum number of rows. A negative n means no limit.
+func (q *selectQuery) Limit(n int64) *selectQuery {
+	q.limit = n
+	return q
+}
+
+// Build returns the SQL and its arguments.
+func (q *selectQuery) Build() (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString("SELECT " + q.columns + " FROM " + q.from)
+	if len(q.where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(q.where, " AND "))
+	}
+	if len(q.orderBy) > 0 {
+		b.WriteString(" ORDER BY " + strings.Join(q.orderBy, ", "))
+	}
+	b.WriteString(" LIMIT ?")
+
+	args := make([]interface{}, 0, len(q.args)+1)
+	args = append(args, q.args...)
+	args = append(args, q.limit)
+	return b.String(), args
+}
+
+// maxQueryIDs is the most ids a query binds in an IN list, well under the
+// SQLITE_MAX_VARIABLE_NUMBER of any SQLite, 999 before 3.32.0. Longer
+// lists are split across queries.
+const maxQueryIDs = 500
+
+// placeholders returns n comma separated placeholders for an IN list.
+func placeholders(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return "?" + strings.Repeat(",?", n-1)
+}
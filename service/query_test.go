@@ -0,0 +1,55 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectQuery(t *testing.T) {
+	testcase := []struct {
+		name     string
+		query    *selectQuery
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no conditions",
+			query:    newSelectQuery("id", "todos"),
+			wantSQL:  "SELECT id FROM todos LIMIT ?",
+			wantArgs: []interface{}{int64(-1)},
+		},
+		{
+			name: "conditions and order",
+			query: newSelectQuery("id", "todos").
+				Where("id < ?", int64(10)).
+				WhereIn("status", "open", "done").
+				WhereIn("subject").
+				OrderBy("created_at", true).
+				OrderBy("id", false).
+				Limit(5),
+			wantSQL:  "SELECT id FROM todos WHERE id < ? AND status IN (?,?) ORDER BY created_at DESC, id ASC LIMIT ?",
+			wantArgs: []interface{}{int64(10), "open", "done", int64(5)},
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args := tc.query.Build()
+			if sql != tc.wantSQL {
+				t.Fatal("expected: ", tc.wantSQL, ", actual: ", sql)
+			}
+			if !reflect.DeepEqual(args, tc.wantArgs) {
+				t.Fatal("expected: ", tc.wantArgs, ", actual: ", args)
+			}
+		})
+	}
+
+	t.Run("placeholder mismatch", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+		newSelectQuery("id", "todos").Where("id = ?")
+	})
+}
@@ -0,0 +1,72 @@
+package service
+
+import "strings"
+
+// rankDigits are the digits of position ranks, in ascending byte order.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// rankBetween returns a rank that sorts strictly between a and b, compared
+// byte by byte. An empty a means no lower bound and an empty b no upper
+// bound. Ranks are fractions in base 62 without the leading "0.", so there
+// is always room for another one; they never end in "0" for that reason.
+// a must sort before b.
+func rankBetween(a, b string) string {
+	if b == "" && a != "" {
+		return rankAfter(a)
+	}
+	if b != "" {
+		// keep the common prefix, padding a with zeros
+		n := 0
+		for n < len(b) && rankDigit(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + rankBetween(suffix(a, n), b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := len(rankDigits)
+	if b != "" {
+		digitB = strings.IndexByte(rankDigits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(rankDigits[(digitA+digitB+1)/2])
+	}
+	// the first digits are adjacent: b without the rest sorts before b,
+	// otherwise extend a
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(rankDigits[digitA]) + rankBetween(suffix(a, 1), "")
+}
+
+// rankAfter returns the shortest rank that sorts after a: a up to its first
+// digit below the last one, with that digit incremented. Appending one rank
+// after another this way only lengthens them once the digits run out, rather
+// than bisecting what is left above a every time.
+func rankAfter(a string) string {
+	for i := 0; i < len(a); i++ {
+		if digit := strings.IndexByte(rankDigits, a[i]); digit < len(rankDigits)-1 {
+			return a[:i] + string(rankDigits[digit+1])
+		}
+	}
+	return a + string(rankDigits[1])
+}
+
+func rankDigit(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+func suffix(s string, i int) string {
+	if i < len(s) {
+		return s[i:]
+	}
+	return ""
+}
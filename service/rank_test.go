@@ -0,0 +1,80 @@
+package service
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	testcase := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{name: "first", want: "V"},
+		{name: "after", a: "V", want: "W"},
+		{name: "after longer", a: "Vz5", want: "W"},
+		{name: "before", b: "V", want: "G"},
+		{name: "between", a: "F", b: "V", want: "N"},
+		{name: "adjacent", a: "F", b: "G", want: "FV"},
+		{name: "common prefix", a: "FV", b: "FX", want: "FW"},
+		{name: "shorter upper", a: "F", b: "GV", want: "G"},
+		{name: "lower end", b: "1", want: "0V"},
+		{name: "upper end", a: "z", want: "z1"},
+		{name: "after upper end", a: "zzF", want: "zzG"},
+		{name: "padded lower", a: "F", b: "F1", want: "F0V"},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			got := rankBetween(tc.a, tc.b)
+			if got != tc.want {
+				t.Fatal("expected: ", tc.want, ", actual: ", got)
+			}
+			if got <= tc.a || tc.b != "" && got >= tc.b {
+				t.Fatal("not between: ", tc.a, " ", got, " ", tc.b)
+			}
+		})
+	}
+
+	t.Run("appends stay short", func(t *testing.T) {
+		// a digit is added every len(rankDigits)-1 appends
+		const n = 1000
+		rank := ""
+		for i := 0; i < n; i++ {
+			next := rankBetween(rank, "")
+			if next <= rank {
+				t.Fatal("not after: ", rank, " ", next)
+			}
+			rank = next
+		}
+		if max := n/(len(rankDigits)-1) + 1; len(rank) > max {
+			t.Fatal("expected: at most ", max, " bytes, actual: ", rank)
+		}
+	})
+
+	t.Run("random inserts stay ordered", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		ranks := []string{}
+		for i := 0; i < 1000; i++ {
+			at := r.Intn(len(ranks) + 1)
+			var a, b string
+			if at > 0 {
+				a = ranks[at-1]
+			}
+			if at < len(ranks) {
+				b = ranks[at]
+			}
+			rank := rankBetween(a, b)
+			if rank <= a || b != "" && rank >= b {
+				t.Fatal("not between: ", a, " ", rank, " ", b)
+			}
+			ranks = append(ranks[:at], append([]string{rank}, ranks[at:]...)...)
+		}
+		if !sort.StringsAreSorted(ranks) {
+			t.Fatal("ranks are not sorted")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// scheduleNext creates the next occurrence of the recurring TODO that has
+// just been completed, within the same tx. The occurrence follows due_at,
+// or start_at, or now when the TODO has neither, and inherits everything
+// but the status, its checklist unchecked. Nothing is created once the rule
+// has ended or when the TODO already has a next occurrence, e.g. when it is
+// completed again.
+func (s *TODOService) scheduleNext(ctx context.Context, tx *sql.Tx, id int64) error {
+	const (
+		read = `SELECT subject, description, project_id, parent_id, start_at, due_at, recurrence, time_zone, next_occurrence_id, priority
+			FROM todos WHERE id = ?`
+		insert = `INSERT INTO todos(subject, description, project_id, parent_id, start_at, due_at, recurrence, time_zone, priority, position)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		copyTags   = `INSERT INTO todo_tags(todo_id, tag_id) SELECT ?, tag_id FROM todo_tags WHERE todo_id = ?`
+		copyFields = `INSERT INTO todo_field_values(todo_id, field_id, text_value, number_value, date_value, boolean_value, sort_key)
+			SELECT ?, field_id, text_value, number_value, date_value, boolean_value, sort_key FROM todo_field_values WHERE todo_id = ?`
+		copyChecklist = `INSERT INTO checklist_items(todo_id, text, position)
+			SELECT ?, text, position FROM checklist_items WHERE todo_id = ? ORDER BY position`
+		link = `UPDATE todos SET next_occurrence_id = ? WHERE id = ?`
+	)
+
+	var (
+		subject, description, recurrence, timeZone string
+		projectID, parentID, nextID                sql.NullInt64
+		startAt, dueAt                             sql.NullTime
+		priority                                   int64
+	)
+	err := tx.QueryRowContext(ctx, read, id).Scan(&subject, &description, &projectID, &parentID,
+		&startAt, &dueAt, &recurrence, &timeZone, &nextID, &priority)
+	if err != nil {
+		return err
+	}
+	if recurrence == "" || nextID.Valid {
+		return nil
+	}
+
+	rule, err := parseRRule(recurrence)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return err
+	}
+
+	var anchor time.Time
+	switch {
+	case dueAt.Valid:
+		anchor = dueAt.Time
+	case startAt.Valid:
+		anchor = startAt.Time
+	default:
+		anchor = s.now()
+	}
+	next, ok := rule.next(anchor.In(loc))
+	if !ok {
+		return nil
+	}
+
+	// both dates move by the same amount of wall clock time in the time
+	// zone, so that start_at keeps its time of day across DST like due_at;
+	// without any, the occurrence is due at the next time
+	shift := wallClock(next).Sub(wallClock(anchor.In(loc)))
+	switch {
+	case dueAt.Valid:
+		dueAt.Time = next
+		start := wallClock(startAt.Time.In(loc)).Add(shift)
+		startAt.Time = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+	case startAt.Valid:
+		startAt.Time = next
+	default:
+		dueAt = sql.NullTime{Time: next, Valid: true}
+	}
+
+	position, err := lastPosition(ctx, tx)
+	if err != nil {
+		return err
+	}
+	ret, err := tx.ExecContext(ctx, insert, subject, description, projectID, parentID,
+		nullTime(startAt), nullTime(dueAt), rule.advance().String(), timeZone, priority, position)
+	if err != nil {
+		return err
+	}
+	nextID.Int64, err = ret.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{copyTags, copyFields, copyChecklist} {
+		if _, err := tx.ExecContext(ctx, query, nextID.Int64, id); err != nil {
+			return err
+		}
+	}
+	if err := touchDependents(ctx, tx, nextID.Int64); err != nil {
+		return err
+	}
+	if err := recordRevisions(ctx, tx, model.TODOActionCreate, nextID.Int64); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, link, nextID.Int64, id)
+	return err
+}
+
+// wallClock returns the wall clock time of t as the same time in UTC, so
+// that the durations between wall clock times leave out DST.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
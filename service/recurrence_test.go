@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestRecurrence(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	// 09:00 in New York, the Friday before DST starts
+	dueAt := time.Date(2021, 3, 12, 14, 0, 0, 0, time.UTC)
+	startAt := dueAt.Add(-time.Hour)
+	todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{
+		Subject:    "take out trash",
+		Tags:       []string{"chore"},
+		StartAt:    &startAt,
+		DueAt:      &dueAt,
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=2",
+		TimeZone:   "America/New_York",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.NextOccurrenceID == nil {
+		t.Fatal("expected next occurrence, but it is nil")
+	}
+
+	next, err := svc.GetTODO(ctx, *done.NextOccurrenceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday 09:00 in New York is 13:00 UTC after DST starts
+	wantDue := time.Date(2021, 3, 15, 13, 0, 0, 0, time.UTC)
+	if next.DueAt == nil || !next.DueAt.Equal(wantDue) {
+		t.Fatal("expected: ", wantDue, ", actual: ", next.DueAt)
+	}
+	if next.StartAt == nil || !next.StartAt.Equal(wantDue.Add(-time.Hour)) {
+		t.Fatal("expected: ", wantDue.Add(-time.Hour), ", actual: ", next.StartAt)
+	}
+	if next.Status != model.TODOStatusOpen || next.Subject != todo.Subject || !reflect.DeepEqual(next.Tags, []string{"chore"}) {
+		t.Fatal("unexpected next occurrence: ", next)
+	}
+	if next.Recurrence != "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=1" || next.TimeZone != "America/New_York" {
+		t.Fatal("unexpected recurrence: ", next.Recurrence, " ", next.TimeZone)
+	}
+
+	t.Run("completing again does not repeat", func(t *testing.T) {
+		if _, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusOpen); err != nil {
+			t.Fatal(err)
+		}
+		again, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *again.NextOccurrenceID != next.ID {
+			t.Fatal("expected: ", next.ID, ", actual: ", *again.NextOccurrenceID)
+		}
+	})
+
+	t.Run("last occurrence", func(t *testing.T) {
+		last, err := svc.UpdateTODOStatus(ctx, next.ID, model.TODOStatusDone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.NextOccurrenceID != nil {
+			t.Fatal("expected: nil, actual: ", *last.NextOccurrenceID)
+		}
+	})
+
+	t.Run("without dates", func(t *testing.T) {
+		todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "water plants", Recurrence: "FREQ=DAILY"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done, err := svc.PatchTODO(ctx, &model.PatchTODORequest{
+			ID:     todo.ID,
+			Status: model.OptionalString{Set: true, Value: model.TODOStatusDone},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := svc.GetTODO(ctx, *done.NextOccurrenceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.DueAt == nil || next.DueAt.Before(time.Now().Add(23*time.Hour)) {
+			t.Fatal("unexpected due_at: ", next.DueAt)
+		}
+	})
+
+	t.Run("start across DST", func(t *testing.T) {
+		// Saturday 09:00 before DST starts, due Monday 09:00 after it
+		startAt := time.Date(2021, 3, 13, 14, 0, 0, 0, time.UTC)
+		dueAt := time.Date(2021, 3, 15, 13, 0, 0, 0, time.UTC)
+		todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{
+			Subject:    "weekly review",
+			StartAt:    &startAt,
+			DueAt:      &dueAt,
+			Recurrence: "FREQ=WEEKLY",
+			TimeZone:   "America/New_York",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := svc.GetTODO(ctx, *done.NextOccurrenceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Saturday 09:00 in New York is 13:00 UTC after DST starts
+		wantStart := time.Date(2021, 3, 20, 13, 0, 0, 0, time.UTC)
+		if next.StartAt == nil || !next.StartAt.Equal(wantStart) {
+			t.Fatal("expected: ", wantStart, ", actual: ", next.StartAt)
+		}
+	})
+
+	t.Run("fields and checklist", func(t *testing.T) {
+		if _, err := service.NewCustomFieldService(todoDB).CreateCustomField(ctx, "points", model.FieldTypeNumber, nil); err != nil {
+			t.Fatal(err)
+		}
+		todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{
+			Subject:    "pay rent",
+			Recurrence: "FREQ=MONTHLY",
+			Fields:     map[string]interface{}{"points": 3.0},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checklist := service.NewChecklistService(todoDB)
+		for _, text := range []string{"transfer", "file receipt"} {
+			item, err := checklist.CreateChecklistItem(ctx, todo.ID, text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checked := true
+			if _, err := checklist.UpdateChecklistItem(ctx, todo.ID, item.ID, nil, &checked); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		done, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, err := svc.GetTODO(ctx, *done.NextOccurrenceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(next.Fields, map[string]interface{}{"points": 3.0}) {
+			t.Fatal("expected: points 3, actual: ", next.Fields)
+		}
+		items, err := checklist.ListChecklist(ctx, next.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 2 || items[0].Text != "transfer" || items[1].Text != "file receipt" || items[0].Checked || items[1].Checked {
+			t.Fatal("unexpected checklist: ", items)
+		}
+	})
+
+	testcase := []struct {
+		name  string
+		patch model.PatchTODORequest
+	}{
+		{name: "invalid rule", patch: model.PatchTODORequest{Recurrence: model.OptionalString{Set: true, Value: "FREQ=HOURLY"}}},
+		{name: "invalid time zone", patch: model.PatchTODORequest{TimeZone: model.OptionalString{Set: true, Value: "Mars/Olympus"}}},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.patch.ID = todo.ID
+			_, err := svc.PatchTODO(ctx, &tc.patch)
+			if _, ok := err.(*model.ErrInvalidArgument); !ok {
+				t.Fatal("expected: ErrInvalidArgument, actual: ", err)
+			}
+		})
+	}
+}
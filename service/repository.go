@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A TODORepository stores TODO entities. TODOService is the SQLite
+// implementation and MemoryTODORepository keeps them in memory; both
+// behave the same, down to the errors they return.
+type TODORepository interface {
+	// CreateTODOFrom creates a TODO with the fields of req.
+	CreateTODOFrom(ctx context.Context, req *model.CreateTODORequest) (*model.TODO, error)
+	// GetTODO reads the TODO by id.
+	GetTODO(ctx context.Context, id int64) (*model.TODO, error)
+	// ListTODO reads a page of TODOs matching req.
+	ListTODO(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error)
+	// PatchTODO writes the members present in patch to the TODO.
+	PatchTODO(ctx context.Context, patch *model.PatchTODORequest) (*model.TODO, error)
+	// DeleteTODO deletes the TODOs by ids. Unknown ids are ignored unless
+	// none is known.
+	DeleteTODO(ctx context.Context, ids []int64) error
+	// DeleteTODOIfMatch deletes the TODO only if it is still at version.
+	// A version of 0 matches any version.
+	DeleteTODOIfMatch(ctx context.Context, id, version int64) error
+	// Transaction runs fn as a unit of work: everything done with repo is
+	// kept only if fn returns nil, and nobody else sees it before then.
+	// Transactions started with repo join the unit of work.
+	Transaction(ctx context.Context, fn func(repo TODORepository) error) error
+}
+
+// A TrashRepository is a TODORepository keeping the TODOs it deletes in a
+// trash, which ListTODO reads with req.Trashed set.
+type TrashRepository interface {
+	TODORepository
+	// RestoreTODO takes the TODO by id out of the trash.
+	RestoreTODO(ctx context.Context, id int64) (*model.TODO, error)
+	// PurgeTrash permanently deletes the TODOs moved to the trash before
+	// the given time and reports how many there were.
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+}
+
+var (
+	_ TODORepository  = (*TODOService)(nil)
+	_ TODORepository  = (*MemoryTODORepository)(nil)
+	_ TrashRepository = (*TODOService)(nil)
+	_ TrashRepository = (*PostgresTODORepository)(nil)
+)
+
+// A todoUnit hides every method of the TODORepository it holds but those
+// of the interface, so that a unit of work is not left through them.
+type todoUnit struct {
+	TODORepository
+}
+
+// Transaction runs fn in a single DB transaction, committed when fn
+// returns nil.
+func (s *TODOService) Transaction(ctx context.Context, fn func(repo TODORepository) error) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		unit := *s
+		unit.tx = tx
+		return fn(todoUnit{&unit})
+	})
+}
+
+// withTx runs fn in a new transaction committed when fn returns nil, or in
+// the transaction of the unit of work s belongs to.
+func (s *TODOService) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the statements tx prepared for itself are cached once it is over
+	defer s.stmts.flush(ctx)
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// conn returns what s reads with: the transaction of its unit of work, if
+// any, or else the DB.
+func (s *TODOService) conn() queryer {
+	return s.inTx(s.tx)
+}
+
+// inTx returns the queryer running queries in tx with the statements of s,
+// or on the DB when tx is nil.
+func (s *TODOService) inTx(tx *sql.Tx) queryer {
+	return stmtQueryer{c: s.stmts, db: s.db, tx: tx}
+}
+
+// unsupported is the error returned for what the repositories keeping the
+// columns of TODOs only do not store.
+func unsupported(what string) error {
+	return &model.ErrNotImplemented{What: what + " not supported by this repository"}
+}
+
+// patchColumns returns a copy of todo with patch applied, validated the way
+// TODOService.applyPatch does, for the repositories keeping the columns of
+// TODOs only: projects, subtasks, recurrences, tags and custom fields are
+// refused, though clearing them is not. written reports whether the TODO
+// is written at all, which bumps its version even if nothing changes; the
+// version and updated_at are left to the caller.
+func patchColumns(todo *model.TODO, patch *model.PatchTODORequest, now time.Time) (ret *model.TODO, written bool, err error) {
+	switch {
+	case patch.ProjectID.Set && !patch.ProjectID.Null:
+		return nil, false, unsupported("project_id")
+	case patch.ParentID.Set && !patch.ParentID.Null:
+		return nil, false, unsupported("parent_id")
+	case patch.Recurrence.Set && strings.TrimSpace(patch.Recurrence.Value) != "":
+		return nil, false, unsupported("recurrence")
+	case len(patch.Tags.Value) > 0:
+		return nil, false, unsupported("tags")
+	case len(patch.Fields) > 0:
+		return nil, false, unsupported("custom fields")
+	}
+	if patch.Version != 0 && patch.Version != todo.Version {
+		return nil, false, &model.ErrVersionMismatch{Expected: patch.Version, Actual: todo.Version}
+	}
+
+	todo = copyTODO(todo)
+	if patch.Subject.Set {
+		if patch.Subject.Null || patch.Subject.Value == "" {
+			return nil, false, &model.ErrInvalidArgument{What: "subject not found"}
+		}
+		todo.Subject = patch.Subject.Value
+		written = true
+	}
+	if patch.Description.Set {
+		todo.Description = patch.Description.Value
+		written = true
+	}
+	if patch.Status.Set {
+		if patch.Status.Null {
+			return nil, false, &model.ErrInvalidArgument{What: "status must not be null"}
+		}
+		if todo.Status != patch.Status.Value {
+			if err := checkTransition(todo.Status, patch.Status.Value); err != nil {
+				return nil, false, err
+			}
+			todo.Status = patch.Status.Value
+			todo.CompletedAt = nil
+			if todo.Status == model.TODOStatusDone {
+				todo.CompletedAt = &now
+			}
+			written = true
+		}
+	}
+	if patch.StartAt.Set || patch.DueAt.Set {
+		startAt, dueAt := todo.StartAt, todo.DueAt
+		if patch.StartAt.Set {
+			startAt = nil
+			if !patch.StartAt.Null {
+				t := memoryTime(patch.StartAt.Value)
+				startAt = &t
+			}
+		}
+		if patch.DueAt.Set {
+			dueAt = nil
+			if !patch.DueAt.Null {
+				t := memoryTime(patch.DueAt.Value)
+				dueAt = &t
+			}
+		}
+		if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+			return nil, false, &model.ErrInvalidArgument{What: "start_at is after due_at"}
+		}
+		todo.StartAt, todo.DueAt = startAt, dueAt
+		written = true
+	}
+	if patch.TimeZone.Set {
+		timeZone := "UTC"
+		if !patch.TimeZone.Null && patch.TimeZone.Value != "" {
+			if _, err := time.LoadLocation(patch.TimeZone.Value); err != nil {
+				return nil, false, &model.ErrInvalidArgument{What: "invalid time_zone: " + err.Error()}
+			}
+			timeZone = patch.TimeZone.Value
+		}
+		todo.TimeZone = timeZone
+		written = true
+	}
+	if patch.Priority.Set {
+		if patch.Priority.Value < model.TODOPriorityNone || patch.Priority.Value > model.TODOPriorityHigh {
+			return nil, false, &model.ErrInvalidArgument{What: "priority must be between 0 and 3"}
+		}
+		todo.Priority = patch.Priority.Value
+		written = true
+	}
+	// only clearing these gets this far
+	if patch.ProjectID.Set || patch.ParentID.Set || patch.Recurrence.Set || patch.Tags.Set {
+		written = true
+	}
+	return todo, written, nil
+}
+
+// readOrder checks req for the repositories keeping the columns of TODOs
+// only, and returns the sort order of the page it reads along with the
+// cursor it reads from, if any, the way TODOService.ListTODO does.
+func readOrder(secret []byte, req *model.ReadTODORequest) (name string, desc bool, cur *cursor, err error) {
+	if req.PrevID < 0 || req.Size < 0 {
+		return "", false, nil, &model.ErrInvalidArgument{What: "invalid argument"}
+	}
+	switch {
+	case req.ProjectID != 0:
+		return "", false, nil, unsupported("project_id")
+	case req.ParentID != 0:
+		return "", false, nil, unsupported("parent_id")
+	case req.Due != "":
+		return "", false, nil, unsupported("due")
+	case len(req.Tags) > 0:
+		return "", false, nil, unsupported("tags")
+	case len(req.Fields) > 0, strings.HasPrefix(req.Sort, fieldSortPrefix):
+		return "", false, nil, unsupported("custom fields")
+	}
+
+	name = req.Sort
+	if name == "" {
+		name = "id"
+	}
+	by, ok := todoSorts[name]
+	if !ok {
+		return "", false, nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid sort: %q", req.Sort)}
+	}
+	desc = by.desc
+	switch req.Order {
+	case "":
+	case "desc":
+		desc = true
+	case "asc":
+		desc = false
+	default:
+		return "", false, nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid order: %q", req.Order)}
+	}
+
+	if req.Cursor != "" {
+		if cur, err = decodeCursor(secret, req.Cursor); err != nil {
+			return "", false, nil, err
+		}
+		if req.PrevID != 0 || req.Sort != "" && cur.Sort != name || req.Order != "" && cur.Desc != desc {
+			return "", false, nil, &model.ErrInvalidCursor{}
+		}
+		if _, ok := todoSorts[cur.Sort]; !ok {
+			return "", false, nil, &model.ErrInvalidCursor{}
+		}
+		name, desc = cur.Sort, cur.Desc
+	}
+	if req.PrevID != 0 && name != "id" {
+		return "", false, nil, &model.ErrInvalidArgument{What: "prev_id can only be used when sorting by id"}
+	}
+	for _, status := range req.Statuses {
+		if _, ok := todoStatusTransitions[status]; !ok {
+			return "", false, nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid status: %q", status)}
+		}
+	}
+	return name, desc, cur, nil
+}
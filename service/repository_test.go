@@ -0,0 +1,454 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// TestTODORepository runs the conformance suite every TODORepository must
+// pass on each implementation.
+func TestTODORepository(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		dbpath := filepath.Join(t.TempDir(), "todo.db")
+		todoDB, err := db.NewDB(dbpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer todoDB.Close()
+
+		testTODORepository(t, service.NewTODOService(todoDB))
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		testTODORepository(t, service.NewMemoryTODORepository())
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		// every TODO of the database is deleted
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("TEST_POSTGRES_DSN is not set")
+		}
+		todoDB, err := db.NewPostgresDB(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer todoDB.Close()
+		if _, err := todoDB.Exec(`TRUNCATE todos RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+
+		testTODORepository(t, service.NewPostgresTODORepository(todoDB))
+	})
+}
+
+// testTODORepository checks the behaviour shared by every TODORepository
+// on repo, which must be empty.
+func testTODORepository(t *testing.T, repo service.TODORepository) {
+	ctx := context.Background()
+
+	list := func(t *testing.T, req *model.ReadTODORequest) []int64 {
+		t.Helper()
+		ret, err := repo.ListTODO(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range ret.TODOs {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+
+	t.Run("create", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			req     *model.CreateTODORequest
+			wantErr error
+		}{
+			{name: "subject only", req: &model.CreateTODORequest{Subject: "subject"}},
+			{name: "description", req: &model.CreateTODORequest{Subject: "subject", Description: "description"}},
+			{name: "priority", req: &model.CreateTODORequest{Subject: "subject", Priority: model.TODOPriorityHigh}},
+			{name: "empty subject", req: &model.CreateTODORequest{}, wantErr: &model.ErrInvalidArgument{}},
+			{name: "invalid priority", req: &model.CreateTODORequest{Subject: "subject", Priority: 4}, wantErr: &model.ErrInvalidArgument{}},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				todo, err := repo.CreateTODOFrom(ctx, tc.req)
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+				if err != nil {
+					return
+				}
+				if todo.Subject != tc.req.Subject || todo.Description != tc.req.Description || todo.Priority != tc.req.Priority {
+					t.Fatal("expected: ", tc.req, ", actual: ", todo)
+				}
+				if todo.Status != model.TODOStatusOpen || todo.Tags == nil || todo.CreatedAt.IsZero() {
+					t.Fatal("expected: an open TODO without tags, actual: ", todo)
+				}
+			})
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		todo, err := repo.GetTODO(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.ID != 2 || todo.Description != "description" || todo.Version != 1 {
+			t.Fatal("expected: TODO 2 at version 1, actual: ", todo)
+		}
+		if _, err := repo.GetTODO(ctx, 99); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		if _, err := repo.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "other"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range []struct {
+			name string
+			req  *model.ReadTODORequest
+			want []int64
+		}{
+			{name: "newest first", req: &model.ReadTODORequest{}, want: []int64{4, 3, 2, 1}},
+			{name: "size", req: &model.ReadTODORequest{Size: 2}, want: []int64{4, 3}},
+			{name: "prev_id", req: &model.ReadTODORequest{PrevID: 3}, want: []int64{2, 1}},
+			{name: "asc", req: &model.ReadTODORequest{Order: "asc"}, want: []int64{1, 2, 3, 4}},
+			{name: "priority", req: &model.ReadTODORequest{Sort: "priority"}, want: []int64{3, 1, 2, 4}},
+			{name: "subject prefix", req: &model.ReadTODORequest{SubjectPrefix: "OTH"}, want: []int64{4}},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				if ids := list(t, tc.req); !reflect.DeepEqual(ids, tc.want) {
+					t.Fatal("expected: ", tc.want, ", actual: ", ids)
+				}
+			})
+		}
+
+		// cursors lead to the next page and back
+		ret, err := repo.ListTODO(ctx, &model.ReadTODORequest{Sort: "position", Size: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ret.HasMore || ret.NextCursor == "" {
+			t.Fatal("expected: more TODOs, actual: ", ret)
+		}
+		next, err := repo.ListTODO(ctx, &model.ReadTODORequest{Cursor: ret.NextCursor, Size: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(next.TODOs) != 1 || next.TODOs[0].ID != 4 || next.HasMore {
+			t.Fatal("expected: [4] and no more, actual: ", next)
+		}
+		if ids := list(t, &model.ReadTODORequest{Cursor: next.PrevCursor, Size: 3}); !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+			t.Fatal("expected: [1 2 3], actual: ", ids)
+		}
+		if _, err := repo.ListTODO(ctx, &model.ReadTODORequest{Cursor: "invalid"}); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrInvalidCursor{}) {
+			t.Fatal("expected: *model.ErrInvalidCursor, actual: ", err)
+		}
+		if _, err := repo.ListTODO(ctx, &model.ReadTODORequest{Sort: "subject"}); err == nil {
+			t.Fatal("expected: error, actual: nil")
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		done := model.OptionalString{Set: true, Value: model.TODOStatusDone}
+		for _, tc := range []struct {
+			name        string
+			patch       *model.PatchTODORequest
+			wantVersion int64
+			wantErr     error
+		}{
+			{name: "subject", patch: &model.PatchTODORequest{ID: 1, Subject: model.OptionalString{Set: true, Value: "changed"}}, wantVersion: 2},
+			{name: "done", patch: &model.PatchTODORequest{ID: 1, Version: 2, Status: done}, wantVersion: 3},
+			{name: "stale version", patch: &model.PatchTODORequest{ID: 1, Version: 2, Status: done}, wantErr: &model.ErrVersionMismatch{}},
+			{name: "invalid transition", patch: &model.PatchTODORequest{ID: 1, Status: model.OptionalString{Set: true, Value: model.TODOStatusInProgress}}, wantErr: &model.ErrInvalidTransition{}},
+			{name: "null subject", patch: &model.PatchTODORequest{ID: 1, Subject: model.OptionalString{Set: true, Null: true}}, wantErr: &model.ErrInvalidArgument{}},
+			{name: "unknown", patch: &model.PatchTODORequest{ID: 99, Status: done}, wantErr: &model.ErrNotFound{}},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				todo, err := repo.PatchTODO(ctx, tc.patch)
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+				if err == nil && todo.Version != tc.wantVersion {
+					t.Fatal("expected: ", tc.wantVersion, ", actual: ", todo.Version)
+				}
+			})
+		}
+
+		todo, err := repo.GetTODO(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Subject != "changed" || todo.Status != model.TODOStatusDone || todo.CompletedAt == nil {
+			t.Fatal("expected: changed and done, actual: ", todo)
+		}
+		if ids := list(t, &model.ReadTODORequest{Statuses: []string{model.TODOStatusDone}}); !reflect.DeepEqual(ids, []int64{1}) {
+			t.Fatal("expected: [1], actual: ", ids)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := repo.DeleteTODO(ctx, []int64{4, 99}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteTODO(ctx, []int64{4}); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if err := repo.DeleteTODOIfMatch(ctx, 3, 1); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrVersionMismatch{}) {
+			t.Fatal("expected: *model.ErrVersionMismatch, actual: ", err)
+		}
+		if err := repo.DeleteTODOIfMatch(ctx, 3, 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetTODO(ctx, 3); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if ids := list(t, &model.ReadTODORequest{}); !reflect.DeepEqual(ids, []int64{2, 1}) {
+			t.Fatal("expected: [2 1], actual: ", ids)
+		}
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		err := repo.Transaction(ctx, func(repo service.TODORepository) error {
+			todo, err := repo.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "aborted"})
+			if err != nil {
+				return err
+			}
+			// the unit of work sees its own writes
+			if _, err := repo.GetTODO(ctx, todo.ID); err != nil {
+				return err
+			}
+			if err := repo.DeleteTODO(ctx, []int64{2}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if err != errAbort {
+			t.Fatal("expected: ", errAbort, ", actual: ", err)
+		}
+		if ids := list(t, &model.ReadTODORequest{}); !reflect.DeepEqual(ids, []int64{2, 1}) {
+			t.Fatal("expected: [2 1], actual: ", ids)
+		}
+
+		var created int64
+		err = repo.Transaction(ctx, func(repo service.TODORepository) error {
+			todo, err := repo.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "committed"})
+			if err != nil {
+				return err
+			}
+			created = todo.ID
+			_, err = repo.PatchTODO(ctx, &model.PatchTODORequest{ID: 2, Description: model.OptionalString{Set: true, Value: "committed"}})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := list(t, &model.ReadTODORequest{}); !reflect.DeepEqual(ids, []int64{created, 2, 1}) {
+			t.Fatal("expected: ", []int64{created, 2, 1}, ", actual: ", ids)
+		}
+		todo, err := repo.GetTODO(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Description != "committed" {
+			t.Fatal("expected: committed, actual: ", todo.Description)
+		}
+	})
+}
+
+// TestTrashRepository runs the conformance suite of the trash on each
+// TrashRepository.
+func TestTrashRepository(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		dbpath := filepath.Join(t.TempDir(), "todo.db")
+		todoDB, err := db.NewDB(dbpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer todoDB.Close()
+
+		testTrashRepository(t, service.NewTODOService(todoDB))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		// every TODO of the database is deleted
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("TEST_POSTGRES_DSN is not set")
+		}
+		todoDB, err := db.NewPostgresDB(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer todoDB.Close()
+		if _, err := todoDB.Exec(`TRUNCATE todos RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+
+		testTrashRepository(t, service.NewPostgresTODORepository(todoDB))
+	})
+}
+
+// testTrashRepository checks the trash of repo, which must be empty.
+func testTrashRepository(t *testing.T, repo service.TrashRepository) {
+	ctx := context.Background()
+
+	list := func(t *testing.T, trashed bool) []int64 {
+		t.Helper()
+		ret, err := repo.ListTODO(ctx, &model.ReadTODORequest{Trashed: trashed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range ret.TODOs {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+	isNotFound := func(err error) bool {
+		return reflect.TypeOf(err) == reflect.TypeOf(&model.ErrNotFound{})
+	}
+
+	for _, subject := range []string{"foo", "bar", "baz"} {
+		if _, err := repo.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		if err := repo.DeleteTODO(ctx, []int64{1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteTODOIfMatch(ctx, 2, 1); err != nil {
+			t.Fatal(err)
+		}
+		if ids := list(t, true); !reflect.DeepEqual(ids, []int64{2, 1}) {
+			t.Fatal("expected: [2 1] in the trash, actual: ", ids)
+		}
+		if ids := list(t, false); !reflect.DeepEqual(ids, []int64{3}) {
+			t.Fatal("expected: [3], actual: ", ids)
+		}
+
+		// the TODOs in the trash are out of reach but for RestoreTODO
+		if _, err := repo.GetTODO(ctx, 1); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if _, err := repo.PatchTODO(ctx, &model.PatchTODORequest{ID: 1, Description: model.OptionalString{Set: true, Value: "changed"}}); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if err := repo.DeleteTODO(ctx, []int64{1}); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if err := repo.DeleteTODOIfMatch(ctx, 1, 0); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		todo, err := repo.RestoreTODO(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.ID != 1 || todo.DeletedAt != nil {
+			t.Fatal("expected: TODO 1 out of the trash, actual: ", todo)
+		}
+		if _, err := repo.RestoreTODO(ctx, 1); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if _, err := repo.RestoreTODO(ctx, 99); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if ids := list(t, false); !reflect.DeepEqual(ids, []int64{3, 1}) {
+			t.Fatal("expected: [3 1], actual: ", ids)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		n, err := repo.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Fatal("expected: 0, actual: ", n)
+		}
+		if n, err = repo.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatal("expected: 1, actual: ", n)
+		}
+		if ids := list(t, true); len(ids) != 0 {
+			t.Fatal("expected: an empty trash, actual: ", ids)
+		}
+		if _, err := repo.RestoreTODO(ctx, 2); !isNotFound(err) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+}
+
+func TestMemoryTODORepositoryConcurrency(t *testing.T) {
+	ctx := context.Background()
+	repo := service.NewMemoryTODORepository()
+
+	const workers, todos = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < todos; j++ {
+				err := repo.Transaction(ctx, func(repo service.TODORepository) error {
+					todo, err := repo.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "subject"})
+					if err != nil {
+						return err
+					}
+					_, err = repo.PatchTODO(ctx, &model.PatchTODORequest{ID: todo.ID, Priority: model.OptionalInt64{Set: true, Value: model.TODOPriorityLow}})
+					return err
+				})
+				if err == nil {
+					_, err = repo.ListTODO(ctx, &model.ReadTODORequest{Size: 5})
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	ret, err := repo.ListTODO(ctx, &model.ReadTODORequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret.TODOs) != workers*todos {
+		t.Fatal("expected: ", workers*todos, ", actual: ", len(ret.TODOs))
+	}
+	for i, todo := range ret.TODOs {
+		if todo.ID != int64(workers*todos-i) || todo.Version != 2 {
+			t.Fatal("expected: distinct TODOs at version 2, actual: ", todo)
+		}
+	}
+}
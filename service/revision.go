@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx whose changes to TODOs are recorded as
+// made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFrom returns the actor set by WithActor, or "" when there is none.
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// unrevisedFields are the members of a TODO that every change touches or
+// that are derived from other TODOs, so they are left out of diffs.
+var unrevisedFields = map[string]bool{
+	"version":            true,
+	"updated_at":         true,
+	"comment_count":      true,
+	"progress":           true,
+	"checklist_progress": true,
+	"blocked":            true,
+}
+
+// recordRevisions stores the current state of the TODOs as revisions made
+// by action. A TODO whose version has been recorded already is skipped, so
+// a call after a change that wrote nothing records nothing.
+func recordRevisions(ctx context.Context, q queryer, action string, ids ...int64) error {
+	const insert = `INSERT OR IGNORE INTO todo_revisions(todo_id, revision, action, actor, data) VALUES(?, ?, ?, ?, ?)`
+
+	for len(ids) > maxQueryIDs {
+		if err := recordRevisions(ctx, q, action, ids[:maxQueryIDs]...); err != nil {
+			return err
+		}
+		ids = ids[maxQueryIDs:]
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	read := `SELECT ` + todoColumns + ` FROM todos WHERE id IN (` + placeholders(len(ids)) + `)`
+	rows, err := q.QueryContext(ctx, read, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*model.TODO, len(ids))
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return err
+		}
+		byID[todo.ID] = todo
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := fillTags(ctx, q, byID, args); err != nil {
+		return err
+	}
+	if err := fillFields(ctx, q, byID, args); err != nil {
+		return err
+	}
+
+	actor := actorFrom(ctx)
+	for _, id := range ids {
+		todo, ok := byID[id]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(todo)
+		if err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, insert, todo.ID, todo.Version, action, actor, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTODOHistory reads every recorded revision of the TODO, oldest first,
+// each with its changes to the one before. The history of a TODO in the
+// trash can be read as well.
+func (s *TODOService) ReadTODOHistory(ctx context.Context, id int64) ([]*model.TODORevision, error) {
+	const (
+		exists = `SELECT COUNT(*) > 0 FROM todos WHERE id = ?`
+		read   = `SELECT revision, action, actor, data, created_at FROM todo_revisions WHERE todo_id = ? ORDER BY revision`
+	)
+
+	var found bool
+	if err := s.db.QueryRowContext(ctx, exists, id).Scan(&found); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*model.TODORevision{}
+	var prev []byte
+	for rows.Next() {
+		var (
+			rev  model.TODORevision
+			data []byte
+		)
+		if err := rows.Scan(&rev.Revision, &rev.Action, &rev.Actor, &data, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &rev.TODO); err != nil {
+			return nil, err
+		}
+		rev.Changes = []*model.TODOChange{}
+		if prev != nil {
+			if rev.Changes, err = diffSnapshots(prev, data); err != nil {
+				return nil, err
+			}
+		}
+		revisions = append(revisions, &rev)
+		prev = data
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// DiffTODO compares two revisions of the TODO field by field. Old values
+// are those of revision from, new ones those of revision to.
+func (s *TODOService) DiffTODO(ctx context.Context, id, from, to int64) ([]*model.TODOChange, error) {
+	prev, err := readSnapshot(ctx, s.db, id, from)
+	if err != nil {
+		return nil, err
+	}
+	next, err := readSnapshot(ctx, s.db, id, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffSnapshots(prev, next)
+}
+
+// RevertTODO brings the fields of the TODO back to what they were at the
+// given revision, as a new revision. Only the fields that differ are
+// written, with the checks of PatchTODO, so e.g. a status is only reverted
+// if the transition is allowed. A version of 0 matches any version.
+func (s *TODOService) RevertTODO(ctx context.Context, id, revision, version int64) (*model.TODO, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readSnapshot(ctx, tx, id, revision)
+	if err != nil {
+		return nil, err
+	}
+	var target model.TODO
+	if err := json.Unmarshal(data, &target); err != nil {
+		return nil, err
+	}
+
+	patch := &model.PatchTODORequest{ID: id, Version: version}
+	if target.Subject != current.Subject {
+		patch.Subject = model.OptionalString{Set: true, Value: target.Subject}
+	}
+	if target.Description != current.Description {
+		patch.Description = model.OptionalString{Set: true, Value: target.Description}
+	}
+	if target.Status != current.Status {
+		patch.Status = model.OptionalString{Set: true, Value: target.Status}
+	}
+	if !reflect.DeepEqual(target.Tags, current.Tags) {
+		patch.Tags = model.OptionalStrings{Set: true, Value: target.Tags}
+	}
+	if !reflect.DeepEqual(target.ProjectID, current.ProjectID) {
+		patch.ProjectID = optionalInt64(target.ProjectID)
+	}
+	if !reflect.DeepEqual(target.ParentID, current.ParentID) {
+		patch.ParentID = optionalInt64(target.ParentID)
+	}
+	if !equalTime(target.StartAt, current.StartAt) {
+		patch.StartAt = optionalTime(target.StartAt)
+	}
+	if !equalTime(target.DueAt, current.DueAt) {
+		patch.DueAt = optionalTime(target.DueAt)
+	}
+	if target.Recurrence != current.Recurrence {
+		patch.Recurrence = model.OptionalString{Set: true, Value: target.Recurrence}
+	}
+	if target.TimeZone != current.TimeZone {
+		patch.TimeZone = model.OptionalString{Set: true, Value: target.TimeZone}
+	}
+	if target.Priority != current.Priority {
+		patch.Priority = model.OptionalInt64{Set: true, Value: target.Priority}
+	}
+	if !reflect.DeepEqual(target.Fields, current.Fields) {
+		patch.Fields = map[string]interface{}{}
+		for name, v := range target.Fields {
+			patch.Fields[name] = v
+		}
+		for name := range current.Fields {
+			if _, ok := target.Fields[name]; !ok {
+				patch.Fields[name] = nil
+			}
+		}
+	}
+
+	if err := s.applyPatch(ctx, tx, patch); err != nil {
+		return nil, err
+	}
+	if err := recordRevisions(ctx, tx, model.TODOActionRevert, id); err != nil {
+		return nil, err
+	}
+
+	todo, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// readSnapshot reads the TODO as recorded at revision.
+func readSnapshot(ctx context.Context, q queryer, id, revision int64) ([]byte, error) {
+	const read = `SELECT data FROM todo_revisions WHERE todo_id = ? AND revision = ?`
+
+	var data []byte
+	if err := q.QueryRowContext(ctx, read, id, revision).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: "revision not found"}
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// diffSnapshots lists the fields that differ between two recorded TODOs,
+// sorted by name.
+func diffSnapshots(prev, next []byte) ([]*model.TODOChange, error) {
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(prev, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(next, &after); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(after))
+	for field := range after {
+		fields = append(fields, field)
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []*model.TODOChange{}
+	for _, field := range fields {
+		if unrevisedFields[field] || reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+		changes = append(changes, &model.TODOChange{Field: field, Old: before[field], New: after[field]})
+	}
+	return changes, nil
+}
+
+// optionalInt64 returns the patch member setting a nullable ID to v.
+func optionalInt64(v *int64) model.OptionalInt64 {
+	if v == nil {
+		return model.OptionalInt64{Set: true, Null: true}
+	}
+	return model.OptionalInt64{Set: true, Value: *v}
+}
+
+// optionalTime returns the patch member setting a nullable time to t.
+func optionalTime(t *time.Time) model.OptionalTime {
+	if t == nil {
+		return model.OptionalTime{Set: true, Null: true}
+	}
+	return model.OptionalTime{Set: true, Value: *t}
+}
+
+// equalTime reports whether two nullable times are the same instant.
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
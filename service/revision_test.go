@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTODOHistory(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := service.WithActor(context.Background(), "alice")
+	svc := service.NewTODOService(todoDB)
+
+	todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "draft", Tags: []string{"work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := todo.Version
+	if _, err := svc.PatchTODO(service.WithActor(context.Background(), "bob"), &model.PatchTODORequest{
+		ID:       todo.ID,
+		Subject:  model.OptionalString{Set: true, Value: "final"},
+		Priority: model.OptionalInt64{Set: true, Value: model.TODOPriorityHigh},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SetTODOTags(ctx, todo.ID, []string{"home"}); err != nil {
+		t.Fatal(err)
+	}
+	// a patch writing nothing is not a revision
+	if _, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: todo.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{todo.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.RestoreTODO(ctx, todo.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := svc.ReadTODOHistory(ctx, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("history", func(t *testing.T) {
+		type summary struct {
+			action, actor string
+			fields        []string
+		}
+		want := []summary{
+			{model.TODOActionCreate, "alice", []string{}},
+			{model.TODOActionUpdate, "bob", []string{"priority", "subject"}},
+			{model.TODOActionUpdate, "alice", []string{"tags"}},
+			{model.TODOActionDelete, "alice", []string{"deleted_at"}},
+			{model.TODOActionRestore, "alice", []string{"deleted_at"}},
+		}
+		got := []summary{}
+		for _, rev := range revisions {
+			fields := []string{}
+			for _, change := range rev.Changes {
+				fields = append(fields, change.Field)
+			}
+			got = append(got, summary{rev.Action, rev.Actor, fields})
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatal("expected: ", want, ", actual: ", got)
+		}
+		if change := revisions[1].Changes[1]; change.Old != "draft" || change.New != "final" {
+			t.Fatal("expected: draft -> final, actual: ", change.Old, " -> ", change.New)
+		}
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		changes, err := svc.DiffTODO(ctx, todo.ID, first, revisions[2].Revision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := []string{}
+		for _, change := range changes {
+			fields = append(fields, change.Field)
+		}
+		if want := []string{"priority", "subject", "tags"}; !reflect.DeepEqual(fields, want) {
+			t.Fatal("expected: ", want, ", actual: ", fields)
+		}
+		if _, err := svc.DiffTODO(ctx, todo.ID, first, 9999); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	t.Run("revert", func(t *testing.T) {
+		reverted, err := svc.RevertTODO(ctx, todo.ID, first, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reverted.Subject != "draft" || reverted.Priority != model.TODOPriorityNone || !reflect.DeepEqual(reverted.Tags, []string{"work"}) {
+			t.Fatal("expected: the first revision, actual: ", reverted)
+		}
+		revisions, err := svc.ReadTODOHistory(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := revisions[len(revisions)-1]; last.Action != model.TODOActionRevert || last.Revision != reverted.Version {
+			t.Fatal("expected: revert at ", reverted.Version, ", actual: ", last.Action, " at ", last.Revision)
+		}
+		if _, err := svc.RevertTODO(ctx, todo.ID, first, 1); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrVersionMismatch{}) {
+			t.Fatal("expected: *model.ErrVersionMismatch, actual: ", err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := svc.ReadTODOHistory(ctx, 9999); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+}
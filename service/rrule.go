@@ -0,0 +1,286 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An rrule is a recurrence rule of RFC 5545. Only FREQ of DAILY, WEEKLY,
+// MONTHLY and YEARLY with INTERVAL, BYDAY, COUNT and UNTIL are supported.
+type rrule struct {
+	Freq     string
+	Interval int
+	ByDay    []weekdayNum
+	// Count is the number of occurrences left, including the current one.
+	// 0 means unlimited.
+	Count int
+	Until time.Time
+	// UntilDate is true when UNTIL is a DATE, kept in Until at midnight UTC.
+	// It ends at the end of that day in the time zone of the TODO.
+	UntilDate bool
+}
+
+// A weekdayNum is an element of BYDAY, e.g. MO, 1MO or -1FR. N is 0 when
+// there is no ordinal.
+type weekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// rruleMaxPeriods bounds the search for the next occurrence, e.g. for the
+// 29th of February every 4 years.
+const rruleMaxPeriods = 1000
+
+// parseRRule parses the value of an RRULE property, optionally prefixed with
+// "RRULE:".
+func parseRRule(s string) (*rrule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r := &rrule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rule part: %q", part)
+		}
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch name {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported FREQ: %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL: %q", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT: %q", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			t, isDate, err := parseRRuleTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL: %q", value)
+			}
+			r.Until, r.UntilDate = t, isDate
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(day)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part: %q", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ not found")
+	}
+	if r.Count != 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL must not be used together")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != "MONTHLY" {
+			return nil, fmt.Errorf("BYDAY with an ordinal needs FREQ=MONTHLY")
+		}
+	}
+	if len(r.ByDay) > 0 && r.Freq == "YEARLY" {
+		return nil, fmt.Errorf("BYDAY is not supported with FREQ=YEARLY")
+	}
+	return r, nil
+}
+
+// parseRRuleTime parses a DATE or a UTC DATE-TIME of RFC 5545. isDate
+// reports a DATE, which is returned at midnight UTC.
+func parseRRuleTime(s string) (t time.Time, isDate bool, err error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse("20060102", s)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	if len(s) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid BYDAY: %q", s)
+	}
+	wd, ok := rruleWeekdays[s[len(s)-2:]]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid BYDAY: %q", s)
+	}
+	var n int
+	if ordinal := s[:len(s)-2]; ordinal != "" {
+		var err error
+		n, err = strconv.Atoi(ordinal)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return weekdayNum{}, fmt.Errorf("invalid BYDAY: %q", s)
+		}
+	}
+	return weekdayNum{N: n, Weekday: wd}, nil
+}
+
+// String returns the rule in the normalised form stored on DB.
+func (r *rrule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			day := strings.ToUpper(wd.Weekday.String()[:2])
+			if wd.N != 0 {
+				day = strconv.Itoa(wd.N) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count != 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	switch {
+	case r.UntilDate:
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	case !r.Until.IsZero():
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// next returns the first occurrence after prev, keeping the wall clock time
+// of prev in its location so that occurrences do not drift over DST. Days
+// that do not exist in a month or year, e.g. the 31st or the 29th of
+// February, are skipped as RFC 5545 says. ok is false when the rule has
+// ended, an UNTIL of a DATE ending with that day in the location of prev.
+func (r *rrule) next(prev time.Time) (t time.Time, ok bool) {
+	if r.Count == 1 {
+		return time.Time{}, false
+	}
+	until := r.Until
+	if r.UntilDate {
+		y, m, d := r.Until.Date()
+		until = time.Date(y, m, d+1, 0, 0, 0, 0, prev.Location()).Add(-time.Nanosecond)
+	}
+	for i := 0; i < rruleMaxPeriods; i++ {
+		for _, t := range r.candidates(prev, i*r.Interval) {
+			if !t.After(prev) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return time.Time{}, false
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates returns the occurrences in the period n periods after the one
+// of prev, in order.
+func (r *rrule) candidates(prev time.Time, n int) []time.Time {
+	y, m, d := prev.Date()
+	hh, mm, ss := prev.Clock()
+	loc := prev.Location()
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, loc)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := date(y, m, d+n)
+		if len(r.ByDay) == 0 || r.hasWeekday(day.Weekday()) {
+			days = append(days, day)
+		}
+	case "WEEKLY":
+		// weeks start on Monday
+		monday := d - (int(prev.Weekday())+6)%7 + 7*n
+		if len(r.ByDay) == 0 {
+			days = append(days, date(y, m, d+7*n))
+		}
+		for i := 0; i < 7; i++ {
+			day := date(y, m, monday+i)
+			if r.hasWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	case "MONTHLY":
+		first := date(y, m+time.Month(n), 1)
+		if len(r.ByDay) == 0 {
+			if day := date(first.Year(), first.Month(), d); day.Month() == first.Month() {
+				days = append(days, day)
+			}
+		}
+		for _, wd := range r.ByDay {
+			days = append(days, monthWeekdays(first, wd)...)
+		}
+	case "YEARLY":
+		if day := date(y+n, m, d); day.Month() == m {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+func (r *rrule) hasWeekday(weekday time.Weekday) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// monthWeekdays returns the days of the month starting at first that match
+// wd, e.g. every Monday for MO or the last Friday for -1FR.
+func monthWeekdays(first time.Time, wd weekdayNum) []time.Time {
+	var days []time.Time
+	for day := first.AddDate(0, 0, (int(wd.Weekday)-int(first.Weekday())+7)%7); day.Month() == first.Month(); day = day.AddDate(0, 0, 7) {
+		days = append(days, day)
+	}
+	switch {
+	case wd.N == 0:
+		return days
+	case wd.N > 0 && wd.N <= len(days):
+		return days[wd.N-1 : wd.N]
+	case wd.N < 0 && -wd.N <= len(days):
+		return days[len(days)+wd.N : len(days)+wd.N+1]
+	default:
+		return nil
+	}
+}
+
+// advance returns the rule for the occurrence after the current one.
+func (r *rrule) advance() *rrule {
+	next := *r
+	if next.Count > 0 {
+		next.Count--
+	}
+	return &next
+}
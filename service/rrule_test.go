@@ -0,0 +1,183 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	testcase := []struct {
+		name    string
+		rule    string
+		want    string
+		isError bool
+	}{
+		{name: "daily", rule: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{name: "prefixed lower case", rule: "RRULE:freq=weekly;byday=mo,fr;interval=2", want: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{name: "ordinal", rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", want: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3"},
+		{name: "until date", rule: "FREQ=YEARLY;UNTIL=20211231", want: "FREQ=YEARLY;UNTIL=20211231"},
+		{name: "until date-time", rule: "FREQ=YEARLY;UNTIL=20211231T150000Z", want: "FREQ=YEARLY;UNTIL=20211231T150000Z"},
+		{name: "no freq", rule: "INTERVAL=2", isError: true},
+		{name: "hourly", rule: "FREQ=HOURLY", isError: true},
+		{name: "count and until", rule: "FREQ=DAILY;COUNT=2;UNTIL=20211231", isError: true},
+		{name: "ordinal weekly", rule: "FREQ=WEEKLY;BYDAY=1MO", isError: true},
+		{name: "unsupported part", rule: "FREQ=DAILY;BYHOUR=9", isError: true},
+		{name: "zero interval", rule: "FREQ=DAILY;INTERVAL=0", isError: true},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := parseRRule(tc.rule)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case tc.isError:
+				return
+			case err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+			if r.String() != tc.want {
+				t.Fatal("expected: ", tc.want, ", actual: ", r.String())
+			}
+		})
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcase := []struct {
+		name   string
+		rule   string
+		prev   time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "daily across DST keeps wall clock",
+			rule:   "FREQ=DAILY",
+			prev:   time.Date(2021, 3, 13, 9, 0, 0, 0, newYork),
+			want:   time.Date(2021, 3, 14, 9, 0, 0, 0, newYork),
+			wantOK: true,
+		},
+		{
+			name:   "weekly across end of DST",
+			rule:   "FREQ=WEEKLY",
+			prev:   time.Date(2021, 11, 1, 9, 0, 0, 0, newYork),
+			want:   time.Date(2021, 11, 8, 9, 0, 0, 0, newYork),
+			wantOK: true,
+		},
+		{
+			name:   "weekly by day within week",
+			rule:   "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			prev:   time.Date(2021, 6, 2, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 6, 4, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "weekly by day to next interval",
+			rule:   "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			prev:   time.Date(2021, 6, 4, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 6, 14, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "daily by day skips weekend",
+			rule:   "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			prev:   time.Date(2021, 6, 4, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 6, 7, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "monthly on the 31st skips short months",
+			rule:   "FREQ=MONTHLY",
+			prev:   time.Date(2021, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 3, 31, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "monthly on the 30th skips February",
+			rule:   "FREQ=MONTHLY",
+			prev:   time.Date(2021, 1, 30, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 3, 30, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "monthly last friday",
+			rule:   "FREQ=MONTHLY;BYDAY=-1FR",
+			prev:   time.Date(2021, 1, 29, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 2, 26, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "monthly fifth monday skips months without one",
+			rule:   "FREQ=MONTHLY;BYDAY=5MO",
+			prev:   time.Date(2021, 3, 29, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 5, 31, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "monthly across DST",
+			rule:   "FREQ=MONTHLY",
+			prev:   time.Date(2021, 2, 15, 9, 0, 0, 0, newYork),
+			want:   time.Date(2021, 3, 15, 9, 0, 0, 0, newYork),
+			wantOK: true,
+		},
+		{
+			name:   "yearly on leap day",
+			rule:   "FREQ=YEARLY",
+			prev:   time.Date(2020, 2, 29, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name: "until reached",
+			rule: "FREQ=DAILY;UNTIL=20210601T000000Z",
+			prev: time.Date(2021, 5, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "until date is inclusive",
+			rule:   "FREQ=DAILY;UNTIL=20210601",
+			prev:   time.Date(2021, 5, 31, 9, 0, 0, 0, time.UTC),
+			want:   time.Date(2021, 6, 1, 9, 0, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			// the end of the day in UTC would have ended it
+			name:   "until date in the time zone",
+			rule:   "FREQ=DAILY;UNTIL=20210601",
+			prev:   time.Date(2021, 5, 31, 21, 0, 0, 0, newYork),
+			want:   time.Date(2021, 6, 1, 21, 0, 0, 0, newYork),
+			wantOK: true,
+		},
+		{
+			name: "until date ended in the time zone",
+			rule: "FREQ=DAILY;UNTIL=20210601",
+			prev: time.Date(2021, 6, 1, 21, 0, 0, 0, newYork),
+		},
+		{
+			name: "last of count",
+			rule: "FREQ=DAILY;COUNT=1",
+			prev: time.Date(2021, 5, 31, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := parseRRule(tc.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := r.next(tc.prev)
+			if ok != tc.wantOK {
+				t.Fatal("expected: ", tc.wantOK, ", actual: ", ok)
+			}
+			if !got.Equal(tc.want) {
+				t.Fatal("expected: ", tc.want, ", actual: ", got)
+			}
+		})
+	}
+}
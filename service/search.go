@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// Markers put around matched terms in search snippets, which are HTML.
+const (
+	snippetOpen  = "<mark>"
+	snippetClose = "</mark>"
+)
+
+// Markers put around matched terms by FTS5, replaced by snippetOpen and
+// snippetClose once the snippet is HTML escaped. The text itself may hold
+// them too, but then the worst it gets is a stray <mark>.
+const (
+	ftsSnippetOpen  = "\x02"
+	ftsSnippetClose = "\x03"
+)
+
+// ftsSnippetReplacer turns the markers of FTS5 into those of snippets.
+var ftsSnippetReplacer = strings.NewReplacer(ftsSnippetOpen, snippetOpen, ftsSnippetClose, snippetClose)
+
+// SearchTODO finds TODOs whose subject or description contain all terms of
+// query. Results are ranked by relevance when the FTS5 index is available
+// and by recency otherwise. size of 0 means no limit.
+func (s *TODOService) SearchTODO(ctx context.Context, query string, size int64) ([]*model.SearchTODOResult, error) {
+	if size < 0 {
+		return nil, &model.ErrInvalidArgument{What: "invalid argument"}
+	}
+	if size == 0 {
+		size = -1
+	}
+
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, errors.New("empty query")
+	}
+
+	fts, err := s.hasFTS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if fts {
+		return s.searchFTS(ctx, terms, size)
+	}
+	return s.searchLike(ctx, terms, size)
+}
+
+// hasFTS reports whether db.NewDB was able to set up the FTS5 index. The
+// index may be left by a build with FTS5, so it is told by its triggers,
+// which db.NewDB drops without FTS5.
+func (s *TODOService) hasFTS(ctx context.Context) (bool, error) {
+	const exists = `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'trigger' AND name = 'trigger_todos_fts_insert'`
+
+	var ok bool
+	if err := s.db.QueryRowContext(ctx, exists).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func (s *TODOService) searchFTS(ctx context.Context, terms []string, size int64) ([]*model.SearchTODOResult, error) {
+	search := `SELECT ` + prefixColumns("t", todoColumns) + `, snippet(todos_fts, -1, ?, ?, '…', 16), bm25(todos_fts)
+FROM todos_fts JOIN todos AS t ON t.id = todos_fts.rowid
+WHERE todos_fts MATCH ? AND t.deleted_at IS NULL
+ORDER BY bm25(todos_fts), t.id DESC LIMIT ?`
+
+	// quote every term so that user input is never parsed as FTS5 syntax
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+
+	rows, err := s.db.QueryContext(ctx, search, ftsSnippetOpen, ftsSnippetClose, strings.Join(quoted, " "), size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*model.SearchTODOResult, 0)
+	for rows.Next() {
+		var result model.SearchTODOResult
+		todo, err := scanTODO(rows, &result.Snippet, &result.Rank)
+		if err != nil {
+			return nil, err
+		}
+		result.Snippet = ftsSnippetReplacer.Replace(html.EscapeString(result.Snippet))
+		// bm25 is negative, smaller being better
+		result.Rank = -result.Rank
+		result.TODO = todo
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := fillResults(ctx, s.db, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *TODOService) searchLike(ctx context.Context, terms []string, size int64) ([]*model.SearchTODOResult, error) {
+	q := newSelectQuery(todoColumns, "todos").Where("deleted_at IS NULL")
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		q.Where(`(subject LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	q.OrderBy("id", true).Limit(size)
+
+	query, args := q.Build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]*model.SearchTODOResult, 0)
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		text := todo.Description
+		if containsFold(todo.Subject, terms[0]) {
+			text = todo.Subject
+		}
+		results = append(results, &model.SearchTODOResult{
+			TODO:    todo,
+			Snippet: highlight(text, terms),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := fillResults(ctx, s.db, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// fillResults loads the relations of the TODOs of results.
+func fillResults(ctx context.Context, q queryer, results []*model.SearchTODOResult) error {
+	todos := make([]*model.TODO, 0, len(results))
+	for _, result := range results {
+		todos = append(todos, result.TODO)
+	}
+	return fillTODOs(ctx, q, todos...)
+}
+
+// escapeLike escapes the wildcards of LIKE with a backslash.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// containsFold is strings.Contains ignoring ASCII case, like LIKE does.
+func containsFold(s, substr string) bool {
+	return strings.Contains(asciiLower(s), asciiLower(substr))
+}
+
+// asciiLower lowers ASCII letters only, so that byte offsets are kept.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// highlight marks every occurrence of terms in text, ignoring ASCII case,
+// and HTML escapes the rest.
+func highlight(text string, terms []string) string {
+	lower := asciiLower(text)
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		term = asciiLower(term)
+		for i := 0; term != "" && i <= len(lower)-len(term); {
+			j := strings.Index(lower[i:], term)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(term); k++ {
+				marked[k] = true
+			}
+			i += j + len(term)
+		}
+	}
+
+	var b strings.Builder
+	in := false
+	for i := 0; i < len(text); {
+		_, n := utf8.DecodeRuneInString(text[i:])
+		if marked[i] != in {
+			in = marked[i]
+			if in {
+				b.WriteString(snippetOpen)
+			} else {
+				b.WriteString(snippetClose)
+			}
+		}
+		b.WriteString(html.EscapeString(text[i : i+n]))
+		i += n
+	}
+	if in {
+		b.WriteString(snippetClose)
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestSearchTODO(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	for _, data := range []struct {
+		subject     string
+		description string
+	}{
+		{subject: "buy milk", description: "from the corner shop"},
+		{subject: "write report", description: "quarterly sales report"},
+		{subject: "call shop", description: "ask about the milk delivery"},
+		{subject: "100% done", description: "it_is a wildcard test"},
+		{subject: "<b>bold</b> & loud", description: "markup"},
+	} {
+		if _, err := svc.CreateTODO(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.UpdateTODO(ctx, 2, "write summary", "quarterly sales summary"); err != nil {
+		t.Fatal(err)
+	}
+
+	testcase := []struct {
+		name    string
+		query   string
+		size    int64
+		isError bool
+		wantIDs []int64
+	}{
+		{
+			name:    "single term",
+			query:   "milk",
+			wantIDs: []int64{1, 3},
+		},
+		{
+			name:    "all terms must match",
+			query:   "milk shop",
+			wantIDs: []int64{1, 3},
+		},
+		{
+			name:    "case insensitive",
+			query:   "QUARTERLY",
+			wantIDs: []int64{2},
+		},
+		{
+			name:    "wildcards are literal",
+			query:   "100%",
+			wantIDs: []int64{4},
+		},
+		{
+			name:    "updated text",
+			query:   "report",
+			wantIDs: []int64{},
+		},
+		{
+			name:    "size",
+			query:   "milk",
+			size:    1,
+			wantIDs: nil,
+		},
+		{
+			name:    "syntax is not interpreted",
+			query:   `"milk AND`,
+			wantIDs: []int64{},
+		},
+		{
+			name:    "empty query",
+			query:   " ",
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := svc.SearchTODO(ctx, tc.query, tc.size)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case !tc.isError && err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+			if tc.isError {
+				return
+			}
+
+			if tc.size > 0 {
+				if int64(len(results)) != tc.size {
+					t.Fatal("count error: ", len(results))
+				}
+				return
+			}
+
+			got := map[int64]bool{}
+			for _, result := range results {
+				got[result.TODO.ID] = true
+				if !strings.Contains(result.Snippet, "<mark>") {
+					t.Fatal("snippet not highlighted: ", result.Snippet)
+				}
+			}
+			if len(got) != len(tc.wantIDs) {
+				t.Fatal("expected: ", tc.wantIDs, ", actual: ", results)
+			}
+			for _, id := range tc.wantIDs {
+				if !got[id] {
+					t.Fatal("expected: ", tc.wantIDs, ", actual: ", results)
+				}
+			}
+		})
+	}
+
+	t.Run("snippet is escaped", func(t *testing.T) {
+		results, err := svc.SearchTODO(ctx, "bold", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "&lt;b&gt;<mark>bold</mark>&lt;/b&gt; &amp; loud"
+		if len(results) != 1 || results[0].Snippet != want {
+			t.Fatal("expected: ", want, ", actual: ", results)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxCachedArity is the longest list of values whose statement is cached.
+// Longer ones are prepared for their transaction alone.
+const maxCachedArity = 1024
+
+// maxCachedStmts is the most statements a stmtCache keeps. Queries past it
+// are prepared on every call, so that the queries built from filters, of
+// which there are many, cannot grow it for ever.
+const maxCachedStmts = 256
+
+// errStmtCacheClosed is returned for the statements of a closed stmtCache.
+var errStmtCacheClosed = errors.New("service: statements are closed")
+
+// A stmtCache keeps the statements prepared on a DB until it is closed, so
+// that each query is prepared once rather than on every call. A nil
+// stmtCache prepares every statement for its transaction alone.
+//
+// A statement is never prepared on the DB while a transaction is open on
+// the same goroutine: that takes a connection other than the one of the
+// transaction, which never comes when the DB is allowed only one. A query
+// first run in a transaction is prepared for it alone, and on the DB by
+// flush once the transaction is over.
+type stmtCache struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[stmtKey]*sql.Stmt
+	// pending are the queries run in transactions but not prepared on db
+	// yet.
+	pending map[stmtKey]string
+	closed  bool
+}
+
+// A stmtKey identifies a cached statement: its query, and for a query of a
+// list of values the number of placeholders in the list.
+type stmtKey struct {
+	query string
+	arity int
+}
+
+// newStmtCache returns new stmtCache with queries prepared up front. A query
+// that fails to prepare now is prepared again on first use.
+func newStmtCache(db *sql.DB, queries ...string) *stmtCache {
+	c := &stmtCache{db: db, stmts: map[stmtKey]*sql.Stmt{}, pending: map[stmtKey]string{}}
+	for _, query := range queries {
+		c.prepare(context.Background(), stmtKey{query: query}, query)
+	}
+	return c
+}
+
+// stmt returns the statement of query for use in tx.
+func (c *stmtCache) stmt(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+	return c.stmtFor(ctx, tx, stmtKey{query: query}, query)
+}
+
+// stmtIn returns the statement of format for use in tx, with its %s
+// replaced by a list of placeholders for values, and values padded to fit
+// the list.
+//
+// Lists are rounded up to a power of two, so that a few statements serve
+// lists of any length, by repeating the last value. This does not change
+// what an IN (...) list matches.
+func (c *stmtCache) stmtIn(ctx context.Context, tx *sql.Tx, format string, values []interface{}) (*sql.Stmt, []interface{}, error) {
+	arity := 1
+	for arity < len(values) {
+		arity *= 2
+	}
+	padded := make([]interface{}, arity)
+	copy(padded, values)
+	for i := len(values); i < arity; i++ {
+		padded[i] = values[len(values)-1]
+	}
+
+	query := fmt.Sprintf(format, "?"+strings.Repeat(",?", arity-1))
+	if arity > maxCachedArity {
+		stmt, err := tx.PrepareContext(ctx, query)
+		return stmt, padded, err
+	}
+	stmt, err := c.stmtFor(ctx, tx, stmtKey{query: format, arity: arity}, query)
+	return stmt, padded, err
+}
+
+// stmtFor returns the statement of key, whose query is query, for use in tx.
+// The statement is closed with tx, but a cached one it is made from is
+// kept. A statement not cached yet is prepared for tx alone, and left to
+// flush.
+func (c *stmtCache) stmtFor(ctx context.Context, tx *sql.Tx, key stmtKey, query string) (*sql.Stmt, error) {
+	if c == nil {
+		return tx.PrepareContext(ctx, query)
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errStmtCacheClosed
+	}
+	stmt, ok := c.stmts[key]
+	if !ok && len(c.stmts)+len(c.pending) < maxCachedStmts {
+		c.pending[key] = query
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return tx.PrepareContext(ctx, query)
+	}
+	// database/sql keeps stmt prepared on each connection it was used on, so
+	// it is prepared again on the connection of tx only the first time it is
+	// used there
+	return tx.StmtContext(ctx, stmt), nil
+}
+
+// flush prepares on the DB the statements run in transactions since the
+// last flush. It must not be called while a transaction is open on the
+// same goroutine. A query that fails to prepare is left to its next use.
+func (c *stmtCache) flush(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[stmtKey]string{}
+	c.mu.Unlock()
+
+	for key, query := range pending {
+		c.prepare(ctx, key, query)
+	}
+}
+
+// prepare returns the cached statement of key, preparing query on the DB
+// for it if there is none yet. It returns nil for a query past
+// maxCachedStmts. Like flush, it must not be called while a transaction is
+// open on the same goroutine.
+func (c *stmtCache) prepare(ctx context.Context, key stmtKey, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errStmtCacheClosed
+	}
+	stmt, ok := c.stmts[key]
+	full := len(c.stmts) >= maxCachedStmts
+	c.mu.Unlock()
+	if ok || full {
+		return stmt, nil
+	}
+
+	// the lock is not held while waiting for a connection, as the ones
+	// holding connections may be waiting for the lock
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		stmt.Close()
+		return nil, errStmtCacheClosed
+	}
+	if cached, ok := c.stmts[key]; ok {
+		stmt.Close()
+		return cached, nil
+	}
+	c.stmts[key] = stmt
+	return stmt, nil
+}
+
+// close closes every cached statement. Any use of c afterwards fails.
+func (c *stmtCache) close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ret error
+	for key, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil && ret == nil {
+			ret = err
+		}
+		delete(c.stmts, key)
+	}
+	c.pending = map[stmtKey]string{}
+	c.closed = true
+	return ret
+}
+
+// A stmtQueryer is a queryer running its queries with the statements of a
+// stmtCache, in tx, or on the DB when tx is nil.
+type stmtQueryer struct {
+	c  *stmtCache
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// stmt returns the statement of query, or nil when query is to be run as it
+// is: past maxCachedStmts, or with a nil stmtCache outside a transaction.
+func (q stmtQueryer) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	if q.tx != nil {
+		return q.c.stmt(ctx, q.tx, query)
+	}
+	if q.c == nil {
+		return nil, nil
+	}
+	return q.c.prepare(ctx, stmtKey{query: query}, query)
+}
+
+// conn returns what q runs the queries with no statement on.
+func (q stmtQueryer) conn() queryer {
+	if q.tx != nil {
+		return q.tx
+	}
+	return q.db
+}
+
+func (q stmtQueryer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := q.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return q.conn().ExecContext(ctx, query, args...)
+	}
+	if q.tx != nil {
+		// a statement of tx is closed with it, but an Exec is done with
+		// its statement at once
+		defer stmt.Close()
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+func (q stmtQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := q.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return q.conn().QueryContext(ctx, query, args...)
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowContext runs query as it is when its statement cannot be had, so
+// that the error, if any, is reported by the row.
+func (q stmtQueryer) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	stmt, err := q.stmt(ctx, query)
+	if err != nil || stmt == nil {
+		return q.conn().QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/mattn/go-sqlite3"
+)
+
+// statementCases are the ways of preparing statements compared by the
+// benchmarks: cached, or on every call, which a nil stmtCache does.
+var statementCases = []struct {
+	name   string
+	cached bool
+}{
+	{name: "cached", cached: true},
+	{name: "prepared per call"},
+}
+
+// openBenchDB returns an empty DB with every migration applied. It is not
+// synced to disk, which would take most of the time of a write.
+func openBenchDB(b *testing.B) *sql.DB {
+	b.Helper()
+
+	dbpath := filepath.Join(b.TempDir(), "todo.db")
+	todoDB, err := sql.Open("sqlite3", dbpath+"?_foreign_keys=on&_txlock=immediate&_sync=OFF&_journal=WAL")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { todoDB.Close() })
+
+	migrations, err := db.Migrations("sqlite3")
+	if err != nil {
+		b.Fatal(err)
+	}
+	m, err := db.NewMigrator(todoDB, "sqlite3", migrations)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := m.Up(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	return todoDB
+}
+
+// countingDriver is go-sqlite3 counting the statements prepared of each
+// query.
+type countingDriver struct {
+	sqlite3.SQLiteDriver
+	mu       sync.Mutex
+	prepares map[string]int
+}
+
+var (
+	registerCountingDriver sync.Once
+	countingSQLite         = &countingDriver{prepares: map[string]int{}}
+)
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), d: d}, nil
+}
+
+// count returns the number of statements prepared of query.
+func (d *countingDriver) count(query string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.prepares[query]
+}
+
+// countingConn is the connection of countingDriver.
+type countingConn struct {
+	*sqlite3.SQLiteConn
+	d *countingDriver
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.prepares[query]++
+	c.d.mu.Unlock()
+	return c.SQLiteConn.PrepareContext(ctx, query)
+}
+
+// TestStmtCachePrepares checks that a cached statement is prepared once on
+// each connection, rather than again in every transaction.
+func TestStmtCachePrepares(t *testing.T) {
+	registerCountingDriver.Do(func() {
+		sql.Register("sqlite3_counting", countingSQLite)
+	})
+
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	migrated, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated.Close()
+
+	todoDB, err := sql.Open("sqlite3_counting", dbpath+"?_foreign_keys=on&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+	const maxConns = 2
+	todoDB.SetMaxOpenConns(maxConns)
+
+	ctx := context.Background()
+	svc := NewTODOService(todoDB)
+	defer svc.Close()
+	const n = 20
+	for i := 0; i < n; i++ {
+		todo, err := svc.CreateTODO(ctx, "subject", "description")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.UpdateTODO(ctx, todo.ID, "subject", "changed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, query := range []string{insertTODOQuery, confirmTODOQuery, updateTODOQuery} {
+		if got := countingSQLite.count(query); got > maxConns {
+			t.Fatal("expected: at most ", maxConns, ", actual: ", got, " for ", query)
+		}
+	}
+}
+
+// total returns the number of statements prepared of every query.
+func (d *countingDriver) total() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, count := range d.prepares {
+		n += count
+	}
+	return n
+}
+
+// TestStmtCacheOneConn checks that statements are cached on a DB allowed a
+// single connection, which the transactions preparing them hold: once every
+// query has been run, nothing is prepared again.
+func TestStmtCacheOneConn(t *testing.T) {
+	registerCountingDriver.Do(func() {
+		sql.Register("sqlite3_counting", countingSQLite)
+	})
+
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	migrated, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated.Close()
+
+	todoDB, err := sql.Open("sqlite3_counting", dbpath+"?_foreign_keys=on&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+	todoDB.SetMaxOpenConns(1)
+
+	// a deadlock fails the test rather than hanging it
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	svc := NewTODOService(todoDB)
+	defer svc.Close()
+
+	run := func(t *testing.T) {
+		t.Helper()
+		todo, err := svc.CreateTODO(ctx, "subject", "description")
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch := &model.PatchTODORequest{ID: todo.ID, Status: model.OptionalString{Set: true, Value: model.TODOStatusDone}}
+		if _, err := svc.PatchTODO(ctx, patch); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.ReadTODO(ctx, 0, 10); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.DeleteTODO(ctx, []int64{todo.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(t)
+	// the statements prepared by the transactions are cached after them
+	run(t)
+	prepared := countingSQLite.total()
+	for i := 0; i < 5; i++ {
+		run(t)
+	}
+	if got := countingSQLite.total() - prepared; got != 0 {
+		t.Fatal("expected: nothing prepared, actual: ", got)
+	}
+}
+
+// BenchmarkStmtCache compares the statements alone: reading a TODO in a
+// transaction, with the IN (...) list of three ids.
+func BenchmarkStmtCache(b *testing.B) {
+	ctx := context.Background()
+	for _, bc := range statementCases {
+		b.Run(bc.name, func(b *testing.B) {
+			todoDB := openBenchDB(b)
+			if _, err := todoDB.Exec(`INSERT INTO todos(subject) VALUES('subject')`); err != nil {
+				b.Fatal(err)
+			}
+			var c *stmtCache
+			if bc.cached {
+				c = newStmtCache(todoDB)
+				defer c.close()
+			}
+			const query = `SELECT ` + todoColumns + ` FROM todos WHERE id IN (%s)`
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tx, err := todoDB.BeginTx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				stmt, args, err := c.stmtIn(ctx, tx, query, []interface{}{1, 2, 3})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := scanTODO(stmt.QueryRowContext(ctx, args...)); err != nil {
+					b.Fatal(err)
+				}
+				if err := tx.Commit(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTODOServiceStatements compares the statements as part of
+// CreateTODO, UpdateTODO and DeleteTODO, which run other queries as well.
+func BenchmarkTODOServiceStatements(b *testing.B) {
+	ctx := context.Background()
+	for _, bc := range statementCases {
+		b.Run(bc.name, func(b *testing.B) {
+			svc := NewTODOService(openBenchDB(b))
+			defer svc.Close()
+			if !bc.cached {
+				svc.Close()
+				svc.stmts = nil
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				todo, err := svc.CreateTODO(ctx, "subject", "description")
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := svc.UpdateTODO(ctx, todo.ID, "subject", "changed"); err != nil {
+					b.Fatal(err)
+				}
+				if err := svc.DeleteTODO(ctx, []int64{todo.ID, todo.ID + 1, todo.ID + 2}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
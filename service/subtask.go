@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// withSubtree prefixes query with the CTE subtree(id, depth, root), which
+// holds the TODOs matching the condition roots and all of their subtasks
+// that are not in the trash, along with the root each was reached from.
+// Roots are at depth 0.
+func withSubtree(roots, query string) string {
+	return `WITH RECURSIVE subtree(id, depth, root) AS (
+		SELECT id, 0, id FROM todos WHERE ` + roots + `
+		UNION ALL
+		SELECT todos.id, subtree.depth + 1, subtree.root FROM todos JOIN subtree ON todos.parent_id = subtree.id
+		WHERE todos.deleted_at IS NULL
+	) ` + query
+}
+
+// GetTODOTree reads the TODO with all of its subtasks, however deep.
+// Children are ordered by id.
+func (s *TODOService) GetTODOTree(ctx context.Context, id int64) (*model.TODONode, error) {
+	const read = `SELECT %s, subtree.depth FROM subtree JOIN todos ON todos.id = subtree.id
+		ORDER BY subtree.depth, todos.id`
+
+	query := withSubtree(`id = ? AND deleted_at IS NULL`, fmt.Sprintf(read, prefixColumns("todos", todoColumns)))
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		root  *model.TODONode
+		todos []*model.TODO
+		nodes = map[int64]*model.TODONode{}
+	)
+	for rows.Next() {
+		var depth int64
+		todo, err := scanTODO(rows, &depth)
+		if err != nil {
+			return nil, err
+		}
+		node := &model.TODONode{TODO: todo, Children: []*model.TODONode{}}
+		nodes[todo.ID] = node
+		todos = append(todos, todo)
+		// parents come first as rows are ordered by depth
+		if depth == 0 {
+			root = node
+		} else if parent, ok := nodes[*todo.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, &model.ErrNotFound{What: "data not found"}
+	}
+
+	if err := fillTODOs(ctx, s.db, todos...); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// checkParent reports whether the TODO can become a subtask of parentID,
+// i.e. the parent exists and is not the TODO itself or one of its subtasks.
+func checkParent(ctx context.Context, q queryer, id, parentID int64) error {
+	const read = `WITH RECURSIVE ancestors(id) AS (
+			SELECT id FROM todos WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT todos.parent_id FROM todos JOIN ancestors ON todos.id = ancestors.id
+			WHERE todos.parent_id IS NOT NULL
+		)
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN id = ? THEN 1 ELSE 0 END), 0) FROM ancestors`
+
+	var found, cycles int64
+	if err := q.QueryRowContext(ctx, read, parentID, id).Scan(&found, &cycles); err != nil {
+		return err
+	}
+	if found == 0 {
+		return &model.ErrInvalidArgument{What: "parent not found"}
+	}
+	if cycles > 0 {
+		return &model.ErrInvalidArgument{What: "parent would make a cycle"}
+	}
+	return nil
+}
+
+// fillProgress sets Progress of the TODOs in byID that have subtasks.
+func fillProgress(ctx context.Context, q queryer, byID map[int64]*model.TODO, ids []interface{}) error {
+	// the subtasks are looked up by parent: the + keeps SQLite from scanning
+	// index_todos_deleted_at for them instead, which nearly every TODO is in
+	const readFmt = `WITH RECURSIVE descendants(root, id, status) AS (
+			SELECT parent_id, id, status FROM todos WHERE parent_id IN (%s) AND +deleted_at IS NULL
+			UNION ALL
+			SELECT descendants.root, todos.id, todos.status FROM todos JOIN descendants ON todos.parent_id = descendants.id
+			WHERE +todos.deleted_at IS NULL
+		)
+		SELECT root, COUNT(*), COALESCE(SUM(CASE WHEN status = 'done' THEN 1 ELSE 0 END), 0)
+		FROM descendants WHERE status <> 'cancelled' GROUP BY root`
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(readFmt, placeholders(len(ids))), ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id       int64
+			progress model.TODOProgress
+		)
+		if err := rows.Scan(&id, &progress.Total, &progress.Done); err != nil {
+			return err
+		}
+		if todo, ok := byID[id]; ok {
+			todo.Progress = &progress
+		}
+	}
+	return rows.Err()
+}
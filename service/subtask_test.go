@@ -0,0 +1,210 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestSubtasks(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	// 1 ─┬─ 2 ─── 4
+	//    └─ 3
+	// 5
+	for _, data := range []struct {
+		parent int64
+		status string
+	}{
+		{},
+		{parent: 1},
+		{parent: 1, status: model.TODOStatusDone},
+		{parent: 2, status: model.TODOStatusCancelled},
+		{},
+	} {
+		req := &model.CreateTODORequest{Subject: "subject"}
+		if data.parent != 0 {
+			parent := data.parent
+			req.ParentID = &parent
+		}
+		todo, err := svc.CreateTODOFrom(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.status != "" {
+			if _, err := svc.UpdateTODOStatus(ctx, todo.ID, data.status); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("progress", func(t *testing.T) {
+		for id, want := range map[int64]*model.TODOProgress{
+			1: {Total: 2, Done: 1},
+			2: nil,
+			3: nil,
+		} {
+			todo, err := svc.GetTODO(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(todo.Progress, want) {
+				t.Fatal("expected: ", want, ", actual: ", todo.Progress)
+			}
+		}
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		tree, err := svc.GetTODOTree(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var walk func(node *model.TODONode) []int64
+		walk = func(node *model.TODONode) []int64 {
+			ids := []int64{node.ID}
+			for _, child := range node.Children {
+				ids = append(ids, walk(child)...)
+			}
+			return ids
+		}
+		if ids := walk(tree); !reflect.DeepEqual(ids, []int64{1, 2, 4, 3}) {
+			t.Fatal("unexpected tree: ", ids)
+		}
+		if _, err := svc.GetTODOTree(ctx, 9999); err == nil {
+			t.Fatal("expected err, but err is nil")
+		}
+	})
+
+	t.Run("children", func(t *testing.T) {
+		ret, err := svc.ListTODO(ctx, &model.ReadTODORequest{ParentID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range ret.TODOs {
+			ids = append(ids, todo.ID)
+		}
+		if !reflect.DeepEqual(ids, []int64{3, 2}) {
+			t.Fatal("unexpected children: ", ids)
+		}
+	})
+
+	testcase := []struct {
+		name    string
+		id      int64
+		parent  int64
+		isError bool
+	}{
+		{name: "self", id: 1, parent: 1, isError: true},
+		{name: "child", id: 1, parent: 2, isError: true},
+		{name: "grandchild", id: 1, parent: 4, isError: true},
+		{name: "unknown", id: 1, parent: 9999, isError: true},
+		{name: "move subtree", id: 2, parent: 5},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.PatchTODO(ctx, &model.PatchTODORequest{
+				ID:       tc.id,
+				ParentID: model.OptionalInt64{Set: true, Value: tc.parent},
+			})
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case tc.isError:
+				if _, ok := err.(*model.ErrInvalidArgument); !ok {
+					t.Fatal("expected: ErrInvalidArgument, actual: ", err)
+				}
+			case err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+		})
+	}
+
+	t.Run("delete subtree", func(t *testing.T) {
+		if err := svc.DeleteTODO(ctx, []int64{5}); err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err := todoDB.QueryRow(`SELECT COUNT(*) FROM todos WHERE deleted_at IS NULL`).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		// 5 took 2 and 4 with it
+		if count != 2 {
+			t.Fatal("expected: 2, actual: ", count)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		versions := func(t *testing.T) map[int64]int64 {
+			t.Helper()
+			versions := map[int64]int64{}
+			for _, id := range []int64{1, 3} {
+				todo, err := svc.GetTODO(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				versions[id] = todo.Version
+			}
+			return versions
+		}
+
+		// 1 ─── 3 ─── 6
+		var id int64
+		parent := int64(3)
+		for _, tc := range []struct {
+			name  string
+			write func() error
+		}{
+			{name: "create", write: func() error {
+				todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "subject", ParentID: &parent})
+				if err == nil {
+					id = todo.ID
+				}
+				return err
+			}},
+			{name: "status", write: func() error {
+				_, err := svc.UpdateTODOStatus(ctx, id, model.TODOStatusDone)
+				return err
+			}},
+			{name: "detach", write: func() error {
+				_, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: id, ParentID: model.OptionalInt64{Set: true, Null: true}})
+				return err
+			}},
+			{name: "attach", write: func() error {
+				_, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: id, ParentID: model.OptionalInt64{Set: true, Value: parent}})
+				return err
+			}},
+			{name: "delete", write: func() error {
+				return svc.DeleteTODO(ctx, []int64{id})
+			}},
+			{name: "restore", write: func() error {
+				_, err := svc.RestoreTODO(ctx, id)
+				return err
+			}},
+		} {
+			before := versions(t)
+			if err := tc.write(); err != nil {
+				t.Fatal(tc.name, ": ", err)
+			}
+			for ancestor, version := range versions(t) {
+				if version == before[ancestor] {
+					t.Fatal(tc.name, ": expected: a new version of ", ancestor, ", actual: ", version)
+				}
+			}
+		}
+	})
+}
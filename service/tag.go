@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A TagService implements CRUD of Tag entities.
+type TagService struct {
+	db *sql.DB
+}
+
+// NewTagService returns new TagService.
+func NewTagService(db *sql.DB) *TagService {
+	return &TagService{
+		db: db,
+	}
+}
+
+// CreateTag creates a Tag on DB. Names are unique regardless of case.
+func (s *TagService) CreateTag(ctx context.Context, name string) (*model.Tag, error) {
+	const (
+		insert  = `INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO NOTHING`
+		confirm = `SELECT id, name, created_at FROM tags WHERE id = ?`
+	)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name not found")
+	}
+
+	ret, err := s.db.ExecContext(ctx, insert, name)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, &model.ErrConflict{What: "tag already exists"}
+	}
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	var tag model.Tag
+	if err := s.db.QueryRowContext(ctx, confirm, id).Scan(&tag.ID, &tag.Name, &tag.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ReadTag reads all Tags on DB ordered by name.
+func (s *TagService) ReadTag(ctx context.Context) ([]*model.Tag, error) {
+	const read = `SELECT id, name, created_at FROM tags ORDER BY name`
+
+	rows, err := s.db.QueryContext(ctx, read)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]*model.Tag, 0)
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// UpdateTag renames the Tag on DB.
+func (s *TagService) UpdateTag(ctx context.Context, id int64, name string) (*model.Tag, error) {
+	const (
+		update  = `UPDATE OR IGNORE tags SET name = ? WHERE id = ?`
+		confirm = `SELECT id, name, created_at FROM tags WHERE id = ?`
+	)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name not found")
+	}
+
+	ret, err := s.db.ExecContext(ctx, update, name, id)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	var tag model.Tag
+	if err := s.db.QueryRowContext(ctx, confirm, id).Scan(&tag.ID, &tag.Name, &tag.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	// the row exists, so nothing was updated because of the unique name
+	if affected == 0 {
+		return nil, &model.ErrConflict{What: "tag already exists"}
+	}
+	return &tag, nil
+}
+
+// DeleteTag deletes the Tag on DB. It is removed from every TODO as well.
+func (s *TagService) DeleteTag(ctx context.Context, id int64) error {
+	const deleteTag = `DELETE FROM tags WHERE id = ?`
+
+	ret, err := s.db.ExecContext(ctx, deleteTag, id)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+	return nil
+}
+
+// SetTODOTags replaces the tags of the TODO. Tags that do not exist yet are
+// created.
+func (s *TODOService) SetTODOTags(ctx context.Context, id int64, names []string) (*model.TODO, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := setTODOTags(ctx, tx, id, names); err != nil {
+		return nil, err
+	}
+	if err := recordRevisions(ctx, tx, model.TODOActionUpdate, id); err != nil {
+		return nil, err
+	}
+	todo, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// setTODOTags replaces the tags of the TODO within tx. The TODO is touched
+// so that its version changes along with its tags.
+func setTODOTags(ctx context.Context, tx *sql.Tx, id int64, names []string) error {
+	const (
+		touch  = `UPDATE todos SET updated_at = DATETIME('now') WHERE id = ? AND deleted_at IS NULL`
+		clear  = `DELETE FROM todo_tags WHERE todo_id = ?`
+		create = `INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO NOTHING`
+		assign = `INSERT OR IGNORE INTO todo_tags(todo_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+	)
+
+	ret, err := tx.ExecContext(ctx, touch, id)
+	if err != nil {
+		return err
+	}
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return &model.ErrNotFound{What: "data not found"}
+	}
+
+	if _, err := tx.ExecContext(ctx, clear, id); err != nil {
+		return err
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return &model.ErrInvalidArgument{What: "tag name not found"}
+		}
+		if _, err := tx.ExecContext(ctx, create, name); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, assign, id, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillTags sets the Tags of todos, sorted by name.
+func fillTags(ctx context.Context, q queryer, byID map[int64]*model.TODO, ids []interface{}) error {
+	query := `SELECT todo_tags.todo_id, tags.name FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id
+WHERE todo_tags.todo_id IN (` + placeholders(len(ids)) + `) ORDER BY tags.name`
+
+	for _, todo := range byID {
+		todo.Tags = []string{}
+	}
+
+	rows, err := q.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,269 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTagService(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTagService(todoDB)
+
+	work, err := svc.CreateTag(ctx, " work ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Name != "work" {
+		t.Fatal("expected: work, actual: ", work.Name)
+	}
+	home, err := svc.CreateTag(ctx, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcase := []struct {
+		name    string
+		fn      func() error
+		wantErr interface{}
+	}{
+		{
+			name:    "create duplicate",
+			fn:      func() error { _, err := svc.CreateTag(ctx, "WORK"); return err },
+			wantErr: &model.ErrConflict{},
+		},
+		{
+			name: "create empty",
+			fn:   func() error { _, err := svc.CreateTag(ctx, " "); return err },
+		},
+		{
+			name:    "rename to existing",
+			fn:      func() error { _, err := svc.UpdateTag(ctx, home.ID, "Work"); return err },
+			wantErr: &model.ErrConflict{},
+		},
+		{
+			name:    "rename not found",
+			fn:      func() error { _, err := svc.UpdateTag(ctx, 9999, "other"); return err },
+			wantErr: &model.ErrNotFound{},
+		},
+		{
+			name:    "delete not found",
+			fn:      func() error { return svc.DeleteTag(ctx, 9999) },
+			wantErr: &model.ErrNotFound{},
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.fn()
+			if err == nil {
+				t.Fatal("expected err, but err is nil")
+			}
+			if tc.wantErr != nil && reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+				t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+			}
+		})
+	}
+
+	t.Run("rename and list", func(t *testing.T) {
+		if _, err := svc.UpdateTag(ctx, home.ID, "house"); err != nil {
+			t.Fatal(err)
+		}
+		tags, err := svc.ReadTag(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		if !reflect.DeepEqual(names, []string{"house", "work"}) {
+			t.Fatal("unexpected tags: ", names)
+		}
+	})
+}
+
+func TestTODOTags(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	for _, data := range []struct {
+		subject string
+		tags    []string
+	}{
+		{subject: "foo", tags: []string{"work", "urgent"}},
+		{subject: "bar", tags: []string{"work"}},
+		{subject: "baz", tags: []string{"home", "Urgent"}},
+		{subject: "qux"},
+	} {
+		todo, err := svc.CreateTODO(ctx, data.subject, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.tags) > 0 {
+			if _, err := svc.SetTODOTags(ctx, todo.ID, data.tags); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("tags are read sorted", func(t *testing.T) {
+		todo, err := svc.GetTODO(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(todo.Tags, []string{"urgent", "work"}) {
+			t.Fatal("unexpected tags: ", todo.Tags)
+		}
+		if todo.Version != 2 {
+			t.Fatal("expected version 2, actual: ", todo.Version)
+		}
+		todo, err = svc.GetTODO(ctx, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Tags == nil || len(todo.Tags) != 0 {
+			t.Fatal("unexpected tags: ", todo.Tags)
+		}
+	})
+
+	testcase := []struct {
+		name    string
+		tags    []string
+		mode    string
+		isError bool
+		wantIDs []int64
+	}{
+		{
+			name:    "any",
+			tags:    []string{"urgent", "home"},
+			wantIDs: []int64{3, 1},
+		},
+		{
+			name:    "all",
+			tags:    []string{"work", "URGENT"},
+			mode:    "all",
+			wantIDs: []int64{1},
+		},
+		{
+			name:    "all with duplicates",
+			tags:    []string{"work", "Work"},
+			mode:    "all",
+			wantIDs: []int64{2, 1},
+		},
+		{
+			name:    "unknown tag",
+			tags:    []string{"nothing"},
+			wantIDs: []int64{},
+		},
+		{
+			name:    "invalid mode",
+			tags:    []string{"work"},
+			mode:    "some",
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			ret, err := svc.ListTODO(ctx, &model.ReadTODORequest{Tags: tc.tags, TagMode: tc.mode})
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case !tc.isError && err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+			if !tc.isError {
+				ids := []int64{}
+				for _, todo := range ret.TODOs {
+					ids = append(ids, todo.ID)
+				}
+				if !reflect.DeepEqual(ids, tc.wantIDs) {
+					t.Fatal("expected: ", tc.wantIDs, ", actual: ", ids)
+				}
+			}
+		})
+	}
+
+	t.Run("patch replaces tags", func(t *testing.T) {
+		todo, err := svc.PatchTODO(ctx, &model.PatchTODORequest{ID: 2, Tags: model.OptionalStrings{Set: true, Value: []string{"home"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(todo.Tags, []string{"home"}) {
+			t.Fatal("unexpected tags: ", todo.Tags)
+		}
+		todo, err = svc.PatchTODO(ctx, &model.PatchTODORequest{ID: 2, Tags: model.OptionalStrings{Set: true, Null: true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(todo.Tags) != 0 {
+			t.Fatal("unexpected tags: ", todo.Tags)
+		}
+	})
+
+	t.Run("deleting cascades", func(t *testing.T) {
+		if _, err := service.NewTagService(todoDB).CreateTag(ctx, "unused"); err != nil {
+			t.Fatal(err)
+		}
+		tags, err := service.NewTagService(todoDB).ReadTag(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range tags {
+			if tag.Name == "urgent" {
+				if err := service.NewTagService(todoDB).DeleteTag(ctx, tag.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		todo, err := svc.GetTODO(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(todo.Tags, []string{"work"}) {
+			t.Fatal("unexpected tags: ", todo.Tags)
+		}
+
+		if err := svc.DeleteTODO(ctx, []int64{1}); err != nil {
+			t.Fatal(err)
+		}
+		// tags are kept in the trash and go when the TODO is purged
+		if _, err := svc.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		if err := todoDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM todo_tags WHERE todo_id = 1").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Fatal("todo_tags left behind: ", n)
+		}
+	})
+
+	t.Run("set tags of missing TODO", func(t *testing.T) {
+		if _, err := svc.SetTODOTags(ctx, 9999, []string{"work"}); err == nil {
+			t.Fatal("expected err, but err is nil")
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/TechBowl-japan/go-stations/model"
 )
@@ -13,163 +14,896 @@ import (
 // A TODOService implements CRUD of TODO entities.
 type TODOService struct {
 	db *sql.DB
+	// tx is set on the TODOService handed to a Transaction function.
+	tx *sql.Tx
+	// stmts are shared with the TODOServices of units of work.
+	stmts        *stmtCache
+	cursorSecret []byte
+	now          func() time.Time
 }
 
-// NewTODOService returns new TODOService.
+// NewTODOService returns new TODOService. The statements it runs most are
+// prepared up front and kept until Close.
 func NewTODOService(db *sql.DB) *TODOService {
 	return &TODOService{
-		db: db,
+		db:           db,
+		stmts:        newStmtCache(db, insertTODOQuery, confirmTODOQuery, updateTODOQuery),
+		cursorSecret: randomCursorSecret(),
+		now:          time.Now,
 	}
 }
 
-// CreateTODO creates a TODO on DB.
-func (s *TODOService) CreateTODO(ctx context.Context, subject, description string) (*model.TODO, error) {
-	const (
-		insert  = `INSERT INTO todos(subject, description) VALUES(?, ?)`
-		confirm = `SELECT subject, description, created_at, updated_at FROM todos WHERE id = ?`
+// Close closes the statements prepared by s, which must not be used
+// afterwards. The DB is left open.
+func (s *TODOService) Close() error {
+	return s.stmts.close()
+}
+
+// todoColumns is the list of columns scanned by scanTODO.
+const todoColumns = `id, subject, description, status, completed_at, project_id, parent_id, start_at, due_at, recurrence, time_zone, next_occurrence_id, priority, position, deleted_at, version, created_at, updated_at`
+
+// The queries of CreateTODO and UpdateTODOIfMatch, whose statements are
+// prepared by NewTODOService.
+//
+// The TODO written is read back by confirmTODOQuery in the same transaction
+// rather than by RETURNING, as go-sqlite3 does not know the types of the
+// columns RETURNING reads and scans DATETIME as text.
+const (
+	insertTODOQuery  = `INSERT INTO todos(subject, description, position) VALUES(?, ?, ?)`
+	confirmTODOQuery = `SELECT ` + todoColumns + ` FROM todos WHERE id = ?`
+	updateTODOQuery  = `UPDATE todos SET subject = ?, description = ? WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`
+)
+
+// trashSubtree moves the TODOs of subtree to the trash, recording the root
+// each was deleted with, so that RestoreTODO brings back the subtasks
+// deleted along with a TODO. A root given along with one of its ancestors
+// is recorded as deleted with the ancestor.
+const trashSubtree = `UPDATE todos SET deleted_at = ?,
+	deleted_root_id = (SELECT root FROM subtree WHERE subtree.id = todos.id ORDER BY depth DESC LIMIT 1)
+	WHERE id IN (SELECT id FROM subtree) RETURNING id`
+
+// deleteTODOsFormat is the query of DeleteTODO, which takes the list of ids
+// for its %s.
+var deleteTODOsFormat = withSubtree(`id IN (%s) AND deleted_at IS NULL`, trashSubtree)
+
+// todoStatusTransitions lists the statuses each status may move to.
+var todoStatusTransitions = map[string][]string{
+	model.TODOStatusOpen:       {model.TODOStatusInProgress, model.TODOStatusDone, model.TODOStatusCancelled},
+	model.TODOStatusInProgress: {model.TODOStatusOpen, model.TODOStatusDone, model.TODOStatusCancelled},
+	model.TODOStatusDone:       {model.TODOStatusOpen},
+	model.TODOStatusCancelled:  {model.TODOStatusOpen},
+}
+
+// A rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// A queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// prefixColumns qualifies each column of a list such as todoColumns with a
+// table alias.
+func prefixColumns(alias, columns string) string {
+	list := strings.Split(columns, ", ")
+	for i, column := range list {
+		list[i] = alias + "." + column
+	}
+	return strings.Join(list, ", ")
+}
+
+// scanTODO scans a row selected with todoColumns. Any columns selected after
+// them are scanned into extra.
+func scanTODO(row rowScanner, extra ...interface{}) (*model.TODO, error) {
+	var (
+		todo        model.TODO
+		completedAt sql.NullTime
+		projectID   sql.NullInt64
+		parentID    sql.NullInt64
+		startAt     sql.NullTime
+		dueAt       sql.NullTime
+		nextID      sql.NullInt64
+		deletedAt   sql.NullTime
 	)
-	stmtInsert, err := s.db.PrepareContext(ctx, insert)
+	dest := []interface{}{&todo.ID, &todo.Subject, &todo.Description, &todo.Status, &completedAt, &projectID, &parentID, &startAt, &dueAt, &todo.Recurrence, &todo.TimeZone, &nextID, &todo.Priority, &todo.Position, &deletedAt, &todo.Version, &todo.CreatedAt, &todo.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
 		return nil, err
 	}
-	stmtConfirm, err := s.db.PrepareContext(ctx, confirm)
+	if completedAt.Valid {
+		todo.CompletedAt = &completedAt.Time
+	}
+	if projectID.Valid {
+		todo.ProjectID = &projectID.Int64
+	}
+	if parentID.Valid {
+		todo.ParentID = &parentID.Int64
+	}
+	if startAt.Valid {
+		todo.StartAt = &startAt.Time
+	}
+	if dueAt.Valid {
+		todo.DueAt = &dueAt.Time
+	}
+	if nextID.Valid {
+		todo.NextOccurrenceID = &nextID.Int64
+	}
+	if deletedAt.Valid {
+		todo.DeletedAt = &deletedAt.Time
+	}
+	return &todo, nil
+}
+
+// getTODO reads the TODO by id along with its relations.
+func getTODO(ctx context.Context, q queryer, id int64) (*model.TODO, error) {
+	const read = `SELECT ` + todoColumns + ` FROM todos WHERE id = ? AND deleted_at IS NULL`
+
+	todo, err := scanTODO(q.QueryRowContext(ctx, read, id))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+	if err := fillTODOs(ctx, q, todo); err != nil {
 		return nil, err
 	}
+	return todo, nil
+}
 
+// fillTODOs loads the relations of todos which are not columns of the todos
+// table, with one query per relation for every maxQueryIDs todos.
+func fillTODOs(ctx context.Context, q queryer, todos ...*model.TODO) error {
+	for len(todos) > maxQueryIDs {
+		if err := fillTODOs(ctx, q, todos[:maxQueryIDs]...); err != nil {
+			return err
+		}
+		todos = todos[maxQueryIDs:]
+	}
+	if len(todos) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*model.TODO, len(todos))
+	ids := make([]interface{}, 0, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+		ids = append(ids, todo.ID)
+	}
+
+	if err := fillTags(ctx, q, byID, ids); err != nil {
+		return err
+	}
+	if err := fillCommentCounts(ctx, q, byID, ids); err != nil {
+		return err
+	}
+	if err := fillFields(ctx, q, byID, ids); err != nil {
+		return err
+	}
+	if err := fillBlocked(ctx, q, byID, ids); err != nil {
+		return err
+	}
+	if err := fillChecklistProgress(ctx, q, byID, ids); err != nil {
+		return err
+	}
+	return fillProgress(ctx, q, byID, ids)
+}
+
+// CreateTODO creates a TODO on DB. It is inserted, recorded and read back
+// in a single transaction.
+func (s *TODOService) CreateTODO(ctx context.Context, subject, description string) (*model.TODO, error) {
 	// validate arguments
 	if subject == "" {
 		return nil, errors.New("subject not found")
 	}
 
-	// insert operation
-	ret, err := stmtInsert.ExecContext(ctx, subject, description)
-	if err != nil {
-		return nil, err
-	}
+	var todo *model.TODO
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		stmtInsert, err := s.stmts.stmt(ctx, tx, insertTODOQuery)
+		if err != nil {
+			return err
+		}
+		stmtConfirm, err := s.stmts.stmt(ctx, tx, confirmTODOQuery)
+		if err != nil {
+			return err
+		}
 
-	// confirm operatrion
-	id, err := ret.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-	var todo model.TODO
-	err = stmtConfirm.QueryRowContext(ctx, id).Scan(&todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt)
+		// insert operation
+		position, err := lastPosition(ctx, tx)
+		if err != nil {
+			return err
+		}
+		ret, err := stmtInsert.ExecContext(ctx, subject, description, position)
+		if err != nil {
+			return err
+		}
+
+		// confirm operation
+		id, err := ret.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := recordRevisions(ctx, s.inTx(tx), model.TODOActionCreate, id); err != nil {
+			return err
+		}
+		todo, err = scanTODO(stmtConfirm.QueryRowContext(ctx, id))
+		if err != nil {
+			return err
+		}
+		return fillTODOs(ctx, s.inTx(tx), todo)
+	})
 	if err != nil {
 		return nil, err
 	}
-	todo.ID = id
-	return &todo, nil
+	return todo, nil
 }
 
-// ReadTODO reads TODOs on DB.
-func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
-	const (
-		read       = `SELECT id, subject, description, created_at, updated_at FROM todos ORDER BY id DESC LIMIT ?`
-		readWithID = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id < ? ORDER BY id DESC LIMIT ?`
-	)
-	stmtRead, err := s.db.PrepareContext(ctx, read)
+// A todoSort is a sort order accepted by ListTODO.
+type todoSort struct {
+	// column is the expression sorted by, with id as tie-breaker.
+	column string
+	// desc is the order used when none is requested.
+	desc bool
+}
+
+// todoSorts maps the sort keys accepted by ListTODO to their orders.
+var todoSorts = map[string]todoSort{
+	"id":         {column: "id", desc: true},
+	"created_at": {column: "created_at", desc: true},
+	"updated_at": {column: "updated_at", desc: true},
+	"position":   {column: "position"},
+	// priorities are a single digit, so the key compares as the pair
+	"priority": {column: "CAST(3 - priority AS TEXT) || position"},
+}
+
+// ReadTODO reads TODOs on DB. When statuses are given, only TODOs in one of
+// those statuses are returned.
+func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64, statuses ...string) ([]*model.TODO, error) {
+	ret, err := s.ListTODO(ctx, &model.ReadTODORequest{
+		PrevID:   prevID,
+		Size:     size,
+		Statuses: statuses,
+	})
 	if err != nil {
 		return nil, err
 	}
-	stmtReadID, err := s.db.PrepareContext(ctx, readWithID)
-	if err != nil {
-		return nil, err
+	return ret.TODOs, nil
+}
+
+// ListTODO reads a page of TODOs on DB matching the filters of req, sorted
+// by req.Sort in req.Order. By default the newest TODOs come first.
+//
+// The following pages are read by passing back the returned cursors, which
+// carry the sort order with them. PrevID is still accepted for the default
+// order but cannot be combined with a cursor.
+func (s *TODOService) ListTODO(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	if req.PrevID < 0 || req.Size < 0 {
+		return nil, &model.ErrInvalidArgument{What: "invalid argument"}
 	}
 
-	var rows *sql.Rows
-	if prevID < 0 || size < 0 {
-		return nil, errors.New("invalid argument")
+	name := req.Sort
+	if name == "" {
+		name = "id"
 	}
-	if size == 0 {
-		size = -1
+	by, ok := todoSorts[name]
+	if !ok && strings.HasPrefix(name, fieldSortPrefix) {
+		var err error
+		if by, name, err = fieldSort(ctx, s.conn(), name); err != nil {
+			return nil, err
+		}
+		ok = true
 	}
-	if prevID == 0 {
-		rows, err = stmtRead.QueryContext(ctx, size)
-		if err != nil {
+	if !ok {
+		return nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid sort: %q", req.Sort)}
+	}
+	desc := by.desc
+	switch req.Order {
+	case "":
+	case "desc":
+		desc = true
+	case "asc":
+		desc = false
+	default:
+		return nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid order: %q", req.Order)}
+	}
+
+	var cur *cursor
+	if req.Cursor != "" {
+		var err error
+		if cur, err = decodeCursor(s.cursorSecret, req.Cursor); err != nil {
 			return nil, err
 		}
+		if req.PrevID != 0 || req.Sort != "" && cur.Sort != name || req.Order != "" && cur.Desc != desc {
+			return nil, &model.ErrInvalidCursor{}
+		}
+		name, desc = cur.Sort, cur.Desc
+		if strings.HasPrefix(name, fieldSortPrefix) {
+			if by, name, err = fieldSort(ctx, s.conn(), name); err != nil {
+				return nil, err
+			}
+		} else {
+			by = todoSorts[name]
+		}
+	}
+	column := by.column
+	// backward is set when reading the page before a cursor; the rows are
+	// then read in reverse and flipped afterwards.
+	backward := cur != nil && cur.Prev
+
+	q := newSelectQuery(todoColumns, "todos")
+	if req.Trashed {
+		q.Where("deleted_at IS NOT NULL")
 	} else {
-		rows, err = stmtReadID.QueryContext(ctx, prevID, size)
+		q.Where("deleted_at IS NULL")
+	}
+	if req.PrevID != 0 {
+		// prev_id is an ID cursor and only meaningful when sorting by ID
+		switch {
+		case column != "id":
+			return nil, &model.ErrInvalidArgument{What: "prev_id can only be used when sorting by id"}
+		case desc:
+			q.Where("id < ?", req.PrevID)
+		default:
+			q.Where("id > ?", req.PrevID)
+		}
+	}
+	if cur != nil {
+		op := ">"
+		if desc != backward {
+			op = "<"
+		}
+		if column == "id" {
+			q.Where("id "+op+" ?", cur.ID)
+		} else {
+			q.Where("("+column+", id) "+op+" (?, ?)", cur.Key, cur.ID)
+		}
+	}
+
+	statuses := make([]interface{}, 0, len(req.Statuses))
+	for _, status := range req.Statuses {
+		if _, ok := todoStatusTransitions[status]; !ok {
+			return nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid status: %q", status)}
+		}
+		statuses = append(statuses, status)
+	}
+	q.WhereIn("status", statuses...)
+
+	if !req.CreatedAfter.IsZero() {
+		q.Where("created_at >= ?", formatTime(req.CreatedAfter))
+	}
+	if !req.CreatedBefore.IsZero() {
+		q.Where("created_at < ?", formatTime(req.CreatedBefore))
+	}
+	if !req.UpdatedAfter.IsZero() {
+		q.Where("updated_at >= ?", formatTime(req.UpdatedAfter))
+	}
+	if !req.UpdatedBefore.IsZero() {
+		q.Where("updated_at < ?", formatTime(req.UpdatedBefore))
+	}
+	if req.ProjectID != 0 {
+		q.Where("project_id = ?", req.ProjectID)
+	}
+	if req.ParentID != 0 {
+		q.Where("parent_id = ?", req.ParentID)
+	}
+	if req.Due != "" {
+		now := s.now()
+		if req.Location != nil {
+			now = now.In(req.Location)
+		}
+		from, to, err := dueRange(req.Due, now)
 		if err != nil {
 			return nil, err
 		}
+		if !from.IsZero() {
+			q.Where("due_at >= ?", formatTime(from))
+		}
+		q.Where("due_at < ?", formatTime(to))
+		if req.Due == model.TODODueOverdue {
+			q.Where("status NOT IN (?, ?)", model.TODOStatusDone, model.TODOStatusCancelled)
+		}
 	}
+	if req.SubjectPrefix != "" {
+		q.Where(`subject LIKE ? ESCAPE '\'`, escapeLike(req.SubjectPrefix)+"%")
+	}
+	if len(req.Tags) > 0 {
+		const hasTags = `id IN (SELECT todo_tags.todo_id FROM todo_tags JOIN tags ON tags.id = todo_tags.tag_id WHERE tags.name IN (%s) GROUP BY todo_tags.todo_id HAVING COUNT(*) >= ?)`
+
+		names := make([]interface{}, 0, len(req.Tags))
+		for _, name := range req.Tags {
+			names = append(names, name)
+		}
+		switch req.TagMode {
+		case "", "any":
+			q.Where(fmt.Sprintf(hasTags, placeholders(len(names))), append(names, 1)...)
+		case "all":
+			q.Where(fmt.Sprintf(hasTags, placeholders(len(names))), append(names, len(distinctFold(req.Tags)))...)
+		default:
+			return nil, &model.ErrInvalidArgument{What: fmt.Sprintf("invalid tag mode: %q", req.TagMode)}
+		}
+	}
+
+	if err := whereFields(ctx, s.conn(), q, req.Fields); err != nil {
+		return nil, err
+	}
+
+	q.OrderBy(column, desc != backward)
+	if column != "id" {
+		q.OrderBy("id", desc != backward)
+	}
+	// one extra row tells whether there is a further page
+	if req.Size > 0 {
+		q.Limit(req.Size + 1)
+	}
+
+	query, args := q.Build()
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	todos := make([]*model.TODO, 0)
 	for rows.Next() {
-		var todo model.TODO
-		if err := rows.Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
+		todo, err := scanTODO(rows)
+		if err != nil {
 			return nil, err
 		}
-		todos = append(todos, &todo)
+		todos = append(todos, todo)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	return todos, nil
+
+	if err := fillTODOs(ctx, s.conn(), todos...); err != nil {
+		return nil, err
+	}
+	return newTODOPage(s.cursorSecret, todos, req.Size, name, desc, cur, req.PrevID), nil
+}
+
+// distinctFold returns names without duplicates ignoring ASCII case, the
+// way tag names are compared.
+func distinctFold(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	ret := make([]string, 0, len(names))
+	for _, name := range names {
+		if key := asciiLower(name); !seen[key] {
+			seen[key] = true
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// formatTime formats t the way DATETIME('now') stores timestamps, so that
+// it compares correctly with the created_at and updated_at columns.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// nullTime returns t formatted for DB, or nil when it is not valid.
+func nullTime(t sql.NullTime) interface{} {
+	if !t.Valid {
+		return nil
+	}
+	return formatTime(t.Time)
+}
+
+// GetTODO reads the TODO on DB by id.
+func (s *TODOService) GetTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	return getTODO(ctx, s.conn(), id)
 }
 
 // UpdateTODO updates the TODO on DB.
 func (s *TODOService) UpdateTODO(ctx context.Context, id int64, subject, description string) (*model.TODO, error) {
-	const (
-		update  = `UPDATE todos SET subject = ?, description = ? WHERE id = ?`
-		confirm = `SELECT subject, description, created_at, updated_at FROM todos WHERE id = ?`
-	)
-	stmtUpdate, err := s.db.PrepareContext(ctx, update)
+	return s.UpdateTODOIfMatch(ctx, id, 0, subject, description)
+}
+
+// UpdateTODOIfMatch updates the TODO on DB only if it is still at version.
+// A version of 0 matches any version. It is updated, recorded and read
+// back in a single transaction, so what is read back is what was written.
+func (s *TODOService) UpdateTODOIfMatch(ctx context.Context, id, version int64, subject, description string) (*model.TODO, error) {
+	if subject == "" {
+		return nil, errors.New("subject not found")
+	}
+
+	var todo *model.TODO
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		stmtUpdate, err := s.stmts.stmt(ctx, tx, updateTODOQuery)
+		if err != nil {
+			return err
+		}
+		stmtConfirm, err := s.stmts.stmt(ctx, tx, confirmTODOQuery)
+		if err != nil {
+			return err
+		}
+
+		ret, err := stmtUpdate.ExecContext(ctx, subject, description, id, version, version)
+		if err != nil {
+			return err
+		}
+		// whether the TODO is there is told by the update itself, as the
+		// read below cannot miss it
+		affected, err := ret.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return checkVersion(ctx, tx, id, version)
+		}
+		if err := recordRevisions(ctx, s.inTx(tx), model.TODOActionUpdate, id); err != nil {
+			return err
+		}
+
+		todo, err = scanTODO(stmtConfirm.QueryRowContext(ctx, id))
+		if err != nil {
+			return err
+		}
+		return fillTODOs(ctx, s.inTx(tx), todo)
+	})
 	if err != nil {
 		return nil, err
 	}
-	stmtConfirm, err := s.db.PrepareContext(ctx, confirm)
+	return todo, nil
+}
+
+// UpdateTODOStatus moves the TODO to the given status. Only the transitions
+// listed in todoStatusTransitions are allowed. completed_at is set when the
+// TODO becomes done and cleared when it leaves done. Completing a recurring
+// TODO creates its next occurrence.
+func (s *TODOService) UpdateTODOStatus(ctx context.Context, id int64, status string) (*model.TODO, error) {
+	return s.PatchTODO(ctx, &model.PatchTODORequest{
+		ID:     id,
+		Status: model.OptionalString{Set: true, Value: status},
+	})
+}
+
+// CreateTODOFrom creates a TODO on DB with every field of req, in a single
+// transaction.
+func (s *TODOService) CreateTODOFrom(ctx context.Context, req *model.CreateTODORequest) (*model.TODO, error) {
+	const insert = `INSERT INTO todos(subject, description, position) VALUES(?, ?, ?)`
+
+	if req.Subject == "" {
+		return nil, &model.ErrInvalidArgument{What: "subject not found"}
+	}
+
+	var todo *model.TODO
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		position, err := lastPosition(ctx, tx)
+		if err != nil {
+			return err
+		}
+		q := s.inTx(tx)
+		ret, err := q.ExecContext(ctx, insert, req.Subject, req.Description, position)
+		if err != nil {
+			return err
+		}
+		id, err := ret.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// everything but subject and description is set like a patch would
+		patch := createPatch(id, req)
+		if err := s.applyPatch(ctx, tx, patch); err != nil {
+			return err
+		}
+		if err := recordRevisions(ctx, q, model.TODOActionCreate, id); err != nil {
+			return err
+		}
+
+		todo, err = getTODO(ctx, q, id)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return todo, nil
+}
 
-	if subject == "" {
-		return nil, errors.New("subject not found")
+// createPatch returns the patch that sets every field of req but subject
+// and description on the TODO created from it.
+func createPatch(id int64, req *model.CreateTODORequest) *model.PatchTODORequest {
+	patch := &model.PatchTODORequest{ID: id}
+	if req.Tags != nil {
+		patch.Tags = model.OptionalStrings{Set: true, Value: req.Tags}
+	}
+	if req.ProjectID != nil {
+		patch.ProjectID = model.OptionalInt64{Set: true, Value: *req.ProjectID}
+	}
+	if req.ParentID != nil {
+		patch.ParentID = model.OptionalInt64{Set: true, Value: *req.ParentID}
+	}
+	if req.StartAt != nil {
+		patch.StartAt = model.OptionalTime{Set: true, Value: *req.StartAt}
+	}
+	if req.DueAt != nil {
+		patch.DueAt = model.OptionalTime{Set: true, Value: *req.DueAt}
+	}
+	if req.Recurrence != "" {
+		patch.Recurrence = model.OptionalString{Set: true, Value: req.Recurrence}
+	}
+	if req.TimeZone != "" {
+		patch.TimeZone = model.OptionalString{Set: true, Value: req.TimeZone}
 	}
+	if req.Priority != 0 {
+		patch.Priority = model.OptionalInt64{Set: true, Value: req.Priority}
+	}
+	patch.Fields = req.Fields
+	return patch
+}
+
+// PatchTODO applies a merge patch to the TODO. Only the columns of members
+// present in patch are written; a null description is stored as empty.
+func (s *TODOService) PatchTODO(ctx context.Context, patch *model.PatchTODORequest) (*model.TODO, error) {
+	var todo *model.TODO
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := s.applyPatch(ctx, tx, patch); err != nil {
+			return err
+		}
+		q := s.inTx(tx)
+		if err := recordRevisions(ctx, q, model.TODOActionUpdate, patch.ID); err != nil {
+			return err
+		}
 
-	_, err = stmtUpdate.ExecContext(ctx, subject, description, id)
+		var err error
+		todo, err = getTODO(ctx, q, patch.ID)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return todo, nil
+}
 
-	var todo model.TODO
-	err = stmtConfirm.QueryRowContext(ctx, id).Scan(&todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt)
-	if err != nil {
-		return nil, &model.ErrNotFound{What:err.Error()}
+// applyPatch writes patch to the TODO within tx.
+func (s *TODOService) applyPatch(ctx context.Context, tx *sql.Tx, patch *model.PatchTODORequest) error {
+	const read = `SELECT status, version, start_at, due_at FROM todos WHERE id = ? AND deleted_at IS NULL`
+
+	q := s.inTx(tx)
+	var (
+		current        string
+		version        int64
+		startAt, dueAt sql.NullTime
+	)
+	if err := q.QueryRowContext(ctx, read, patch.ID).Scan(&current, &version, &startAt, &dueAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ErrNotFound{What: err.Error()}
+		}
+		return err
 	}
-	todo.ID = id
-	return &todo, nil
+	if patch.Version != 0 && patch.Version != version {
+		return &model.ErrVersionMismatch{Expected: patch.Version, Actual: version}
+	}
+
+	var (
+		sets []string
+		args []interface{}
+	)
+	if patch.Subject.Set {
+		if patch.Subject.Null || patch.Subject.Value == "" {
+			return &model.ErrInvalidArgument{What: "subject not found"}
+		}
+		sets = append(sets, "subject = ?")
+		args = append(args, patch.Subject.Value)
+	}
+	if patch.Description.Set {
+		sets = append(sets, "description = ?")
+		args = append(args, patch.Description.Value)
+	}
+	if patch.Status.Set {
+		if patch.Status.Null {
+			return &model.ErrInvalidArgument{What: "status must not be null"}
+		}
+		if current != patch.Status.Value {
+			if err := checkTransition(current, patch.Status.Value); err != nil {
+				return err
+			}
+			sets = append(sets, "status = ?", "completed_at = CASE WHEN ? = 'done' THEN DATETIME('now') ELSE NULL END")
+			args = append(args, patch.Status.Value, patch.Status.Value)
+		}
+	}
+	if patch.ProjectID.Set {
+		if patch.ProjectID.Null {
+			sets = append(sets, "project_id = NULL")
+		} else {
+			if err := checkProject(ctx, q, patch.ProjectID.Value); err != nil {
+				return err
+			}
+			sets = append(sets, "project_id = ?")
+			args = append(args, patch.ProjectID.Value)
+		}
+	}
+	if patch.StartAt.Set || patch.DueAt.Set {
+		if patch.StartAt.Set {
+			startAt = sql.NullTime{Time: patch.StartAt.Value, Valid: !patch.StartAt.Null}
+		}
+		if patch.DueAt.Set {
+			dueAt = sql.NullTime{Time: patch.DueAt.Value, Valid: !patch.DueAt.Null}
+		}
+		if startAt.Valid && dueAt.Valid && startAt.Time.After(dueAt.Time) {
+			return &model.ErrInvalidArgument{What: "start_at is after due_at"}
+		}
+		sets = append(sets, "start_at = ?", "due_at = ?")
+		args = append(args, nullTime(startAt), nullTime(dueAt))
+	}
+	if patch.Recurrence.Set {
+		var recurrence string
+		if value := strings.TrimSpace(patch.Recurrence.Value); !patch.Recurrence.Null && value != "" {
+			rule, err := parseRRule(value)
+			if err != nil {
+				return &model.ErrInvalidArgument{What: "invalid recurrence: " + err.Error()}
+			}
+			recurrence = rule.String()
+		}
+		sets = append(sets, "recurrence = ?")
+		args = append(args, recurrence)
+	}
+	if patch.TimeZone.Set {
+		timeZone := "UTC"
+		if !patch.TimeZone.Null && patch.TimeZone.Value != "" {
+			if _, err := time.LoadLocation(patch.TimeZone.Value); err != nil {
+				return &model.ErrInvalidArgument{What: "invalid time_zone: " + err.Error()}
+			}
+			timeZone = patch.TimeZone.Value
+		}
+		sets = append(sets, "time_zone = ?")
+		args = append(args, timeZone)
+	}
+	if patch.Priority.Set {
+		if patch.Priority.Value < model.TODOPriorityNone || patch.Priority.Value > model.TODOPriorityHigh {
+			return &model.ErrInvalidArgument{What: "priority must be between 0 and 3"}
+		}
+		// null resets to no priority
+		sets = append(sets, "priority = ?")
+		args = append(args, patch.Priority.Value)
+	}
+	if patch.ParentID.Set {
+		if patch.ParentID.Null {
+			sets = append(sets, "parent_id = NULL")
+		} else {
+			if err := checkParent(ctx, q, patch.ID, patch.ParentID.Value); err != nil {
+				return err
+			}
+			sets = append(sets, "parent_id = ?")
+			args = append(args, patch.ParentID.Value)
+		}
+	}
+
+	// blocked and progress of other TODOs follow the status and the parent
+	// of this one: the ancestors it leaves are touched before it moves, the
+	// ones it joins after
+	touch := patch.Status.Set && current != patch.Status.Value || patch.ParentID.Set
+	if patch.ParentID.Set {
+		if err := touchDependents(ctx, tx, patch.ID); err != nil {
+			return err
+		}
+	}
+	if len(sets) > 0 {
+		query := `UPDATE todos SET ` + strings.Join(sets, ", ") + ` WHERE id = ?`
+		if _, err := q.ExecContext(ctx, query, append(args, patch.ID)...); err != nil {
+			return err
+		}
+	}
+	if touch {
+		if err := touchDependents(ctx, tx, patch.ID); err != nil {
+			return err
+		}
+	}
+
+	if patch.Tags.Set {
+		if err := setTODOTags(ctx, tx, patch.ID, patch.Tags.Value); err != nil {
+			return err
+		}
+	}
+	if len(patch.Fields) > 0 {
+		if err := setTODOFields(ctx, tx, patch.ID, patch.Fields); err != nil {
+			return err
+		}
+	}
+	if patch.Status.Set && current != patch.Status.Value && patch.Status.Value == model.TODOStatusDone {
+		if err := s.scheduleNext(ctx, tx, patch.ID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// DeleteTODO deletes TODOs on DB by ids.
+// checkTransition reports whether a TODO may move from one status to another.
+func checkTransition(from, to string) error {
+	for _, next := range todoStatusTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &model.ErrInvalidTransition{From: from, To: to}
+}
+
+// DeleteTODO moves TODOs on DB by ids to the trash, together with their
+// subtasks. They are kept until RestoreTODO or PurgeTrash.
 func (s *TODOService) DeleteTODO(ctx context.Context, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
 
-	const deleteFmt = `DELETE FROM todos WHERE id IN (?%s)`
-	stmt, err := s.db.PrepareContext(ctx, fmt.Sprintf(deleteFmt, strings.Repeat(",?", len(ids)-1)))
-	if err != nil {
-		return fmt.Errorf("PrepareContext: %w", err)
-	}
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var values []interface{}
+		for _, id := range ids {
+			values = append(values, id)
+		}
+		stmt, args, err := s.stmts.stmtIn(ctx, tx, deleteTODOsFormat, values)
+		if err != nil {
+			return fmt.Errorf("PrepareContext: %w", err)
+		}
 
-	var args []interface{}
-	for _, id := range ids {
-		args = append(args, id)
-	}
-	ret, err := stmt.ExecContext(ctx, args...)
+		args = append(args, formatTime(s.now()))
+		deleted, err := scanIDs(stmt.QueryContext(ctx, args...))
+		if err != nil {
+			return fmt.Errorf("QueryContext: %v: %w", args, err)
+		}
+
+		if len(deleted) == 0 {
+			return &model.ErrNotFound{What: "data not found"}
+		}
+		if err := touchDependents(ctx, tx, deleted...); err != nil {
+			return err
+		}
+		return recordRevisions(ctx, s.inTx(tx), model.TODOActionDelete, deleted...)
+	})
+}
+
+// DeleteTODOIfMatch moves the TODO on DB to the trash only if it is still
+// at version. A version of 0 matches any version. Its subtasks are moved as
+// well.
+func (s *TODOService) DeleteTODOIfMatch(ctx context.Context, id, version int64) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		query := withSubtree(`id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`, trashSubtree)
+		deleted, err := scanIDs(tx.QueryContext(ctx, query, id, version, version, formatTime(s.now())))
+		if err != nil {
+			return fmt.Errorf("QueryContext: %w", err)
+		}
+		if len(deleted) == 0 {
+			return checkVersion(ctx, tx, id, version)
+		}
+		if err := touchDependents(ctx, tx, deleted...); err != nil {
+			return err
+		}
+		return recordRevisions(ctx, s.inTx(tx), model.TODOActionDelete, deleted...)
+	})
+}
+
+// scanIDs reads the ids returned by a query, e.g. by a RETURNING clause.
+func scanIDs(rows *sql.Rows, err error) ([]int64, error) {
 	if err != nil {
-		return fmt.Errorf("ExecContext: %v: %w", args, err)
+		return nil, err
 	}
-	affected, err := ret.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("RowsAffected: %v: %w", args, err)
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
+	return ids, rows.Err()
+}
+
+// checkVersion explains why a conditional write of the TODO affected no
+// rows: either it does not exist or it is not at the expected version.
+func checkVersion(ctx context.Context, q queryer, id, expected int64) error {
+	const read = `SELECT version FROM todos WHERE id = ? AND deleted_at IS NULL`
 
-	if affected == 0 {
-		return &model.ErrNotFound{What:"data not found"}
+	var actual int64
+	if err := q.QueryRowContext(ctx, read, id).Scan(&actual); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.ErrNotFound{What: err.Error()}
+		}
+		return err
 	}
-	return nil
+	return &model.ErrVersionMismatch{Expected: expected, Actual: actual}
 }
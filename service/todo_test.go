@@ -2,10 +2,15 @@ package service_test
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 )
 
@@ -28,7 +33,7 @@ var init_data = []struct {
 }
 
 func TestCreateTODO(t *testing.T) {
-	dbpath := "./todo_temp.db"
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
 	todoDB, err := db.NewDB(dbpath)
 	if err != nil {
 		t.Fatal(err)
@@ -83,14 +88,10 @@ func TestCreateTODO(t *testing.T) {
 			}
 		})
 	}
-
-	if err := os.Remove(dbpath); err != nil {
-		t.Log(err)
-	}
 }
 
 func TestReadTODO(t *testing.T) {
-	dbpath := "./todo_temp.db"
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
 	todoDB, err := db.NewDB(dbpath)
 	if err != nil {
 		t.Fatal(err)
@@ -184,14 +185,46 @@ func TestReadTODO(t *testing.T) {
 			}
 		})
 	}
+}
+
+func TestReadTODOUnpaged(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	// more TODOs than a query can bind variables for, the last one tagged
+	const count = 40000
+	const insert = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO todos(subject, position) SELECT 'subject', printf('%08d1', i) FROM n`
+	if _, err := todoDB.ExecContext(ctx, insert, count); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.NewTODOService(todoDB)
+	defer svc.Close()
+	if _, err := svc.SetTODOTags(ctx, 1, []string{"first"}); err != nil {
+		t.Fatal(err)
+	}
 
-	if err := os.Remove(dbpath); err != nil {
-		t.Log(err)
+	todos, err := svc.ReadTODO(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != count {
+		t.Fatal("expected: ", count, ", actual: ", len(todos))
+	}
+	// ReadTODO reads the newest first
+	if last := todos[len(todos)-1]; last.ID != 1 || !reflect.DeepEqual(last.Tags, []string{"first"}) {
+		t.Fatal("expected: 1 tagged first, actual: ", last.ID, last.Tags)
 	}
 }
 
 func TestUpdateTODO(t *testing.T) {
-	dbpath := "./todo_temp.db"
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
 	todoDB, err := db.NewDB(dbpath)
 	if err != nil {
 		t.Fatal(err)
@@ -275,14 +308,10 @@ func TestUpdateTODO(t *testing.T) {
 			}
 		})
 	}
-
-	if err := os.Remove(dbpath); err != nil {
-		t.Log(err)
-	}
 }
 
 func TestDeleteTODO(t *testing.T) {
-	dbpath := "./todo_temp.db"
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
 	todoDB, err := db.NewDB(dbpath)
 	if err != nil {
 		t.Fatal(err)
@@ -317,6 +346,12 @@ func TestDeleteTODO(t *testing.T) {
 			ids: []int64{},
 			err: nil,
 		},
+		{
+			// three ids share the statement of four
+			name: "ids padded",
+			ids: []int64{2, 3, 9},
+			err: nil,
+		},
 	}
 
 	for _, tc := range testcase {
@@ -328,7 +363,760 @@ func TestDeleteTODO(t *testing.T) {
 		})
 	}
 
-	if err := os.Remove(dbpath); err != nil {
-		t.Log(err)
+	todos, err := svc.ReadTODO(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 0 {
+		t.Fatal("expected: no TODOs, actual: ", todos)
+	}
+}
+
+func TestTODOServiceClose(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	if _, err := svc.CreateTODO(ctx, "subject", "description"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the statements are gone but the DB is not
+	if _, err := svc.CreateTODO(ctx, "subject", "description"); err == nil {
+		t.Fatal("expected: error, actual: nil")
+	}
+	if err := todoDB.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other := service.NewTODOService(todoDB)
+	defer other.Close()
+	if _, err := other.UpdateTODO(ctx, 1, "subject", "description"); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+		t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+	}
+}
+
+func TestUpdateTODOStatus(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, description) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range init_data {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := service.NewTODOService(todoDB)
+
+	// the cases run in order against the same TODO
+	testcase := []struct {
+		name          string
+		id            int64
+		status        string
+		isError       bool
+		wantCompleted bool
+	}{
+		{
+			name:   "open to in_progress",
+			id:     1,
+			status: model.TODOStatusInProgress,
+		},
+		{
+			name:          "in_progress to done",
+			id:            1,
+			status:        model.TODOStatusDone,
+			wantCompleted: true,
+		},
+		{
+			name:    "done to cancelled",
+			id:      1,
+			status:  model.TODOStatusCancelled,
+			isError: true,
+		},
+		{
+			name:   "reopen",
+			id:     1,
+			status: model.TODOStatusOpen,
+		},
+		{
+			name:    "unknown status",
+			id:      1,
+			status:  "archived",
+			isError: true,
+		},
+		{
+			name:    "not found",
+			id:      9999,
+			status:  model.TODOStatusDone,
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			todo, err := svc.UpdateTODOStatus(ctx, tc.id, tc.status)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case !tc.isError && err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+
+			if !tc.isError {
+				if tc.status != todo.Status {
+					t.Fatal("expected: ", tc.status, ", actual: ", todo.Status)
+				}
+				if tc.wantCompleted != (todo.CompletedAt != nil) {
+					t.Fatal("unexpected completed_at: ", todo.CompletedAt)
+				}
+			}
+		})
+	}
+
+	t.Run("filter by status", func(t *testing.T) {
+		if _, err := svc.UpdateTODOStatus(ctx, 2, model.TODOStatusDone); err != nil {
+			t.Fatal(err)
+		}
+		todos, err := svc.ReadTODO(ctx, 0, 0, model.TODOStatusDone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(todos) != 1 || todos[0].ID != 2 {
+			t.Fatal("unexpected todos: ", todos)
+		}
+		if _, err := svc.ReadTODO(ctx, 0, 0, "archived"); err == nil {
+			t.Fatal("expected err, but err is nil")
+		}
+	})
+}
+
+func TestPatchTODO(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, description) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range init_data {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := service.NewTODOService(todoDB)
+
+	str := func(s string) model.OptionalString { return model.OptionalString{Set: true, Value: s} }
+
+	testcase := []struct {
+		name            string
+		patch           model.PatchTODORequest
+		isError         bool
+		wantSubject     string
+		wantDescription string
+		wantStatus      string
+	}{
+		{
+			name:            "subject only",
+			patch:           model.PatchTODORequest{ID: 1, Subject: str("patched")},
+			wantSubject:     "patched",
+			wantDescription: "this is foo",
+			wantStatus:      model.TODOStatusOpen,
+		},
+		{
+			name:            "null description",
+			patch:           model.PatchTODORequest{ID: 1, Description: model.OptionalString{Set: true, Null: true}},
+			wantSubject:     "patched",
+			wantDescription: "",
+			wantStatus:      model.TODOStatusOpen,
+		},
+		{
+			name:            "empty patch",
+			patch:           model.PatchTODORequest{ID: 3},
+			wantSubject:     "baz",
+			wantDescription: "this is baz",
+			wantStatus:      model.TODOStatusOpen,
+		},
+		{
+			name:            "description and status",
+			patch:           model.PatchTODORequest{ID: 2, Description: str(""), Status: str(model.TODOStatusDone)},
+			wantSubject:     "bar",
+			wantDescription: "",
+			wantStatus:      model.TODOStatusDone,
+		},
+		{
+			name:    "empty subject",
+			patch:   model.PatchTODORequest{ID: 3, Subject: str("")},
+			isError: true,
+		},
+		{
+			name:    "null subject",
+			patch:   model.PatchTODORequest{ID: 3, Subject: model.OptionalString{Set: true, Null: true}},
+			isError: true,
+		},
+		{
+			name:    "invalid transition",
+			patch:   model.PatchTODORequest{ID: 2, Status: str(model.TODOStatusCancelled)},
+			isError: true,
+		},
+		{
+			name:    "empty patch not found",
+			patch:   model.PatchTODORequest{ID: 9999},
+			isError: true,
+		},
+		{
+			name:    "not found",
+			patch:   model.PatchTODORequest{ID: 9999, Subject: str("patched")},
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			todo, err := svc.PatchTODO(ctx, &tc.patch)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case !tc.isError && err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+
+			if !tc.isError {
+				got, err := svc.GetTODO(ctx, tc.patch.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, todo := range []*model.TODO{todo, got} {
+					if tc.wantSubject != todo.Subject {
+						t.Fatal("expected: ", tc.wantSubject, ", actual: ", todo.Subject)
+					}
+					if tc.wantDescription != todo.Description {
+						t.Fatal("expected: ", tc.wantDescription, ", actual: ", todo.Description)
+					}
+					if tc.wantStatus != todo.Status {
+						t.Fatal("expected: ", tc.wantStatus, ", actual: ", todo.Status)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("get not found", func(t *testing.T) {
+		_, err := svc.GetTODO(ctx, 9999)
+		if _, ok := err.(*model.ErrNotFound); !ok {
+			t.Fatal("expected ErrNotFound, actual: ", err)
+		}
+	})
+}
+
+func TestUpdateTODOIfMatch(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, description) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range init_data {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.description); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := service.NewTODOService(todoDB)
+
+	todo, err := svc.UpdateTODOIfMatch(ctx, 1, 1, "update", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todo.Version != 2 {
+		t.Fatal("expected: 2, actual: ", todo.Version)
 	}
+
+	_, err = svc.UpdateTODOIfMatch(ctx, 1, 1, "stale", "")
+	if e, ok := err.(*model.ErrVersionMismatch); !ok || e.Actual != 2 {
+		t.Fatal("expected ErrVersionMismatch, actual: ", err)
+	}
+
+	_, err = svc.UpdateTODOIfMatch(ctx, 9999, 1, "missing", "")
+	if _, ok := err.(*model.ErrNotFound); !ok {
+		t.Fatal("expected ErrNotFound, actual: ", err)
+	}
+
+	_, err = svc.PatchTODO(ctx, &model.PatchTODORequest{ID: 1, Version: 1, Subject: model.OptionalString{Set: true, Value: "stale"}})
+	if _, ok := err.(*model.ErrVersionMismatch); !ok {
+		t.Fatal("expected ErrVersionMismatch, actual: ", err)
+	}
+
+	if err := svc.DeleteTODOIfMatch(ctx, 1, 1); err == nil {
+		t.Fatal("expected err, but err is nil")
+	}
+	if err := svc.DeleteTODOIfMatch(ctx, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTODOIfMatch(ctx, 1, 0); err == nil {
+		t.Fatal("expected err, but err is nil")
+	}
+}
+
+func TestUpdateTODOConcurrentDelete(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+	defer svc.Close()
+
+	const n = 10
+	for i := 0; i < n; i++ {
+		if _, err := svc.CreateTODO(ctx, "subject", "description"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an update racing a delete either returns what it wrote or finds
+	// nothing, never a TODO it did not write or any other error
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for id := int64(1); id <= n; id++ {
+		id := id
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			todo, err := svc.UpdateTODO(ctx, id, "changed", "description")
+			switch err.(type) {
+			case nil:
+				if todo.ID != id || todo.Subject != "changed" {
+					err = fmt.Errorf("expected: TODO %d changed, actual: %+v", id, todo)
+				}
+			case *model.ErrNotFound:
+				err = nil
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- svc.DeleteTODO(ctx, []int64{id})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListTODO(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, status, created_at, updated_at) VALUES(?, ?, ?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []struct {
+		subject   string
+		status    string
+		createdAt string
+		updatedAt string
+	}{
+		{subject: "buy milk", status: "open", createdAt: "2021-01-03 00:00:00", updatedAt: "2021-01-05 00:00:00"},
+		{subject: "buy eggs", status: "done", createdAt: "2021-01-01 00:00:00", updatedAt: "2021-01-06 00:00:00"},
+		{subject: "call mom", status: "open", createdAt: "2021-01-02 00:00:00", updatedAt: "2021-01-04 00:00:00"},
+		{subject: "buy_ham", status: "open", createdAt: "2021-01-02 00:00:00", updatedAt: "2021-01-02 00:00:00"},
+	} {
+		if _, err := stmt.ExecContext(ctx, data.subject, data.status, data.createdAt, data.updatedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := service.NewTODOService(todoDB)
+
+	date := func(day int) time.Time { return time.Date(2021, 1, day, 0, 0, 0, 0, time.UTC) }
+
+	testcase := []struct {
+		name    string
+		req     model.ReadTODORequest
+		isError bool
+		wantIDs []int64
+	}{
+		{
+			name:    "default order",
+			req:     model.ReadTODORequest{},
+			wantIDs: []int64{4, 3, 2, 1},
+		},
+		{
+			name:    "prev_id",
+			req:     model.ReadTODORequest{PrevID: 3, Size: 1},
+			wantIDs: []int64{2},
+		},
+		{
+			name:    "prev_id ascending",
+			req:     model.ReadTODORequest{PrevID: 2, Sort: "id", Order: "asc"},
+			wantIDs: []int64{3, 4},
+		},
+		{
+			name:    "created_at ascending with tie-breaker",
+			req:     model.ReadTODORequest{Sort: "created_at", Order: "asc"},
+			wantIDs: []int64{2, 3, 4, 1},
+		},
+		{
+			name:    "updated_at descending",
+			req:     model.ReadTODORequest{Sort: "updated_at"},
+			wantIDs: []int64{2, 1, 3, 4},
+		},
+		{
+			name:    "created range",
+			req:     model.ReadTODORequest{CreatedAfter: date(2), CreatedBefore: date(3)},
+			wantIDs: []int64{4, 3},
+		},
+		{
+			name:    "updated range in another time zone",
+			req:     model.ReadTODORequest{UpdatedAfter: date(5).In(time.FixedZone("JST", 9*60*60))},
+			wantIDs: []int64{2, 1},
+		},
+		{
+			name:    "subject prefix",
+			req:     model.ReadTODORequest{SubjectPrefix: "buy "},
+			wantIDs: []int64{2, 1},
+		},
+		{
+			name:    "subject prefix is not a pattern",
+			req:     model.ReadTODORequest{SubjectPrefix: "buy_"},
+			wantIDs: []int64{4},
+		},
+		{
+			name:    "status and prefix",
+			req:     model.ReadTODORequest{Statuses: []string{"open"}, SubjectPrefix: "buy"},
+			wantIDs: []int64{4, 1},
+		},
+		{
+			name:    "invalid sort",
+			req:     model.ReadTODORequest{Sort: "subject; DROP TABLE todos"},
+			isError: true,
+		},
+		{
+			name:    "invalid order",
+			req:     model.ReadTODORequest{Order: "sideways"},
+			isError: true,
+		},
+		{
+			name:    "prev_id with other sort",
+			req:     model.ReadTODORequest{PrevID: 3, Sort: "created_at"},
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			ret, err := svc.ListTODO(ctx, &tc.req)
+			switch {
+			case tc.isError && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case !tc.isError && err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+
+			if !tc.isError {
+				ids := make([]int64, 0, len(ret.TODOs))
+				for _, todo := range ret.TODOs {
+					ids = append(ids, todo.ID)
+				}
+				if !reflect.DeepEqual(ids, tc.wantIDs) {
+					t.Fatal("expected: ", tc.wantIDs, ", actual: ", ids)
+				}
+			}
+		})
+	}
+}
+
+func TestListTODOCursor(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+
+	stmt, err := todoDB.PrepareContext(ctx, "INSERT INTO todos(subject, created_at) VALUES(?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// several TODOs share created_at so that the tie-breaker matters
+	for _, createdAt := range []string{
+		"2021-01-02 00:00:00",
+		"2021-01-01 00:00:00",
+		"2021-01-02 00:00:00",
+		"2021-01-03 00:00:00",
+		"2021-01-02 00:00:00",
+	} {
+		if _, err := stmt.ExecContext(ctx, "subject", createdAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc := service.NewTODOService(todoDB)
+
+	ids := func(ret *model.ReadTODOResponse) []int64 {
+		ids := make([]int64, 0, len(ret.TODOs))
+		for _, todo := range ret.TODOs {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+
+	testcase := []struct {
+		name      string
+		sort      string
+		order     string
+		wantPages [][]int64
+	}{
+		{
+			name:      "default",
+			wantPages: [][]int64{{5, 4}, {3, 2}, {1}},
+		},
+		{
+			name:      "id ascending",
+			sort:      "id",
+			order:     "asc",
+			wantPages: [][]int64{{1, 2}, {3, 4}, {5}},
+		},
+		{
+			name:      "created_at descending",
+			sort:      "created_at",
+			wantPages: [][]int64{{4, 5}, {3, 1}, {2}},
+		},
+		{
+			name:      "created_at ascending",
+			sort:      "created_at",
+			order:     "asc",
+			wantPages: [][]int64{{2, 1}, {3, 5}, {4}},
+		},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			ret, err := svc.ListTODO(ctx, &model.ReadTODORequest{Size: 2, Sort: tc.sort, Order: tc.order})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ret.PrevCursor != "" {
+				t.Fatal("unexpected prev_cursor on the first page")
+			}
+
+			// forward to the last page
+			var cursors []string
+			for i, want := range tc.wantPages {
+				if i > 0 {
+					ret, err = svc.ListTODO(ctx, &model.ReadTODORequest{Size: 2, Cursor: cursors[i-1]})
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				if !reflect.DeepEqual(ids(ret), want) {
+					t.Fatal("page ", i, " expected: ", want, ", actual: ", ids(ret))
+				}
+				if last := i == len(tc.wantPages)-1; ret.HasMore == last || (ret.NextCursor == "") != last {
+					t.Fatal("page ", i, " unexpected has_more: ", ret.HasMore)
+				}
+				cursors = append(cursors, ret.NextCursor)
+			}
+
+			// and back to the first one
+			for i := len(tc.wantPages) - 2; i >= 0; i-- {
+				ret, err = svc.ListTODO(ctx, &model.ReadTODORequest{Size: 2, Cursor: ret.PrevCursor})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(ids(ret), tc.wantPages[i]) {
+					t.Fatal("page ", i, " expected: ", tc.wantPages[i], ", actual: ", ids(ret))
+				}
+				if !ret.HasMore {
+					t.Fatal("page ", i, " expected has_more")
+				}
+			}
+			if ret.PrevCursor != "" {
+				t.Fatal("unexpected prev_cursor on the first page")
+			}
+		})
+	}
+
+	t.Run("invalid cursors", func(t *testing.T) {
+		ret, err := svc.ListTODO(ctx, &model.ReadTODORequest{Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		other := service.NewTODOService(todoDB)
+
+		for name, req := range map[string]*model.ReadTODORequest{
+			"garbage":        {Cursor: "garbage"},
+			"tampered":       {Cursor: "x" + ret.NextCursor},
+			"other secret":   {Cursor: ret.NextCursor},
+			"different sort": {Cursor: ret.NextCursor, Sort: "created_at"},
+			"with prev_id":   {Cursor: ret.NextCursor, PrevID: 3},
+		} {
+			target := svc
+			if name == "other secret" {
+				target = other
+			}
+			if _, err := target.ListTODO(ctx, req); err == nil {
+				t.Fatal(name, ": expected err, but err is nil")
+			} else if _, ok := err.(*model.ErrInvalidCursor); !ok {
+				t.Fatal(name, ": expected ErrInvalidCursor, actual: ", err)
+			}
+		}
+	})
+}
+
+func TestListTODODue(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().In(tokyo)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 1, 0, tokyo)
+
+	for _, data := range []struct {
+		dueAt  time.Time
+		status string
+	}{
+		{dueAt: now.Add(-48 * time.Hour)},
+		{dueAt: now.Add(-48 * time.Hour), status: model.TODOStatusDone},
+		{dueAt: now.AddDate(1, 0, 0)},
+		{},
+		{dueAt: today},
+	} {
+		req := &model.CreateTODORequest{Subject: "subject"}
+		if !data.dueAt.IsZero() {
+			dueAt := data.dueAt
+			req.DueAt = &dueAt
+		}
+		todo, err := svc.CreateTODOFrom(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.status != "" {
+			if _, err := svc.UpdateTODOStatus(ctx, todo.ID, data.status); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	testcase := []struct {
+		name     string
+		due      string
+		wantIn   []int64
+		wantOut  []int64
+		wantErr  bool
+		location *time.Location
+	}{
+		{name: "overdue", due: model.TODODueOverdue, wantIn: []int64{1}, wantOut: []int64{2, 3, 4}},
+		{name: "today", due: model.TODODueToday, location: tokyo, wantIn: []int64{5}, wantOut: []int64{1, 2, 3, 4}},
+		{name: "week", due: model.TODODueWeek, location: tokyo, wantIn: []int64{5}, wantOut: []int64{3, 4}},
+		{name: "unknown", due: "month", wantErr: true},
+	}
+
+	for _, tc := range testcase {
+		t.Run(tc.name, func(t *testing.T) {
+			ret, err := svc.ListTODO(ctx, &model.ReadTODORequest{Due: tc.due, Location: tc.location})
+			switch {
+			case tc.wantErr && err == nil:
+				t.Fatal("expected err, but err is nil")
+			case tc.wantErr:
+				return
+			case err != nil:
+				t.Fatal("not expected err, but err is not nil: ", err)
+			}
+			got := map[int64]bool{}
+			for _, todo := range ret.TODOs {
+				got[todo.ID] = true
+			}
+			for _, id := range tc.wantIn {
+				if !got[id] {
+					t.Fatal("expected to contain: ", id, ", actual: ", got)
+				}
+			}
+			for _, id := range tc.wantOut {
+				if got[id] {
+					t.Fatal("expected not to contain: ", id, ", actual: ", got)
+				}
+			}
+		})
+	}
+
+	t.Run("start after due", func(t *testing.T) {
+		_, err := svc.PatchTODO(ctx, &model.PatchTODORequest{
+			ID:      1,
+			StartAt: model.OptionalTime{Set: true, Value: now},
+		})
+		if _, ok := err.(*model.ErrInvalidArgument); !ok {
+			t.Fatal("expected: ErrInvalidArgument, actual: ", err)
+		}
+	})
+
+	t.Run("clear due", func(t *testing.T) {
+		todo, err := svc.PatchTODO(ctx, &model.PatchTODORequest{
+			ID:      1,
+			StartAt: model.OptionalTime{Set: true, Value: now},
+			DueAt:   model.OptionalTime{Set: true, Null: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.DueAt != nil || todo.StartAt == nil || !todo.StartAt.Equal(now.Truncate(time.Second)) {
+			t.Fatal("unexpected dates: ", todo.StartAt, todo.DueAt)
+		}
+	})
 }
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// RestoreTODO takes the TODO out of the trash together with the subtasks
+// that were deleted along with it. If its parent is still in the trash, the
+// TODO is restored as a top-level TODO.
+//
+// The subtasks are those deleted with the same root. The TODOs trashed
+// before the root was recorded have none, and are matched by deleted_at
+// instead, which also matches the subtasks deleted on their own within the
+// same second.
+func (s *TODOService) RestoreTODO(ctx context.Context, id int64) (*model.TODO, error) {
+	const (
+		read = `SELECT todos.deleted_at, todos.deleted_root_id, parents.deleted_at IS NOT NULL
+			FROM todos LEFT JOIN todos AS parents ON parents.id = todos.parent_id
+			WHERE todos.id = ? AND todos.deleted_at IS NOT NULL`
+		restore = `WITH RECURSIVE trashed(id) AS (
+				SELECT id FROM todos WHERE id = ?
+				UNION ALL
+				SELECT todos.id FROM todos JOIN trashed ON todos.parent_id = trashed.id
+				WHERE todos.deleted_root_id IS ? AND (? IS NOT NULL OR todos.deleted_at = ?)
+			)
+			UPDATE todos SET deleted_at = NULL, deleted_root_id = NULL WHERE id IN (SELECT id FROM trashed) RETURNING id`
+		detach = `UPDATE todos SET parent_id = NULL WHERE id = ?`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		deletedAt     sql.NullTime
+		rootID        sql.NullInt64
+		parentTrashed bool
+	)
+	if err := tx.QueryRowContext(ctx, read, id).Scan(&deletedAt, &rootID, &parentTrashed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.ErrNotFound{What: err.Error()}
+		}
+		return nil, err
+	}
+
+	restored, err := scanIDs(tx.QueryContext(ctx, restore, id, rootID, rootID, formatTime(deletedAt.Time)))
+	if err != nil {
+		return nil, err
+	}
+	if parentTrashed {
+		if _, err := tx.ExecContext(ctx, detach, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := touchDependents(ctx, tx, restored...); err != nil {
+		return nil, err
+	}
+	if err := recordRevisions(ctx, tx, model.TODOActionRestore, restored...); err != nil {
+		return nil, err
+	}
+
+	todo, err := getTODO(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// PurgeTrash permanently deletes the TODOs moved to the trash before the
+// given time and reports how many there were.
+func (s *TODOService) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	const purge = `DELETE FROM todos WHERE deleted_at < ?`
+
+	ret, err := s.db.ExecContext(ctx, purge, formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return ret.RowsAffected()
+}
+
+// RunPurger purges the TODOs that have been in the trash longer than
+// retention every interval, until ctx is done.
+func (s *TODOService) RunPurger(ctx context.Context, retention, interval time.Duration) {
+	runPurger(ctx, s.PurgeTrash, s.now, retention, interval)
+}
+
+// runPurger calls purge with the time TODOs must have been trashed before
+// to be purged, now less retention, every interval until ctx is done.
+func runPurger(ctx context.Context, purge func(ctx context.Context, before time.Time) (int64, error), now func() time.Time, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := purge(ctx, now().Add(-retention))
+		if err != nil {
+			log.Print("purge trash: ", err)
+		} else if n > 0 {
+			log.Printf("purge trash: %d TODOs deleted", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package service_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTrash(t *testing.T) {
+	dbpath := filepath.Join(t.TempDir(), "todo.db")
+	todoDB, err := db.NewDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer todoDB.Close()
+
+	ctx := context.Background()
+	svc := service.NewTODOService(todoDB)
+
+	// 1 ─── 2 ─── 3
+	// 4
+	for _, parent := range []int64{0, 1, 2, 0} {
+		req := &model.CreateTODORequest{Subject: "subject"}
+		if parent != 0 {
+			parent := parent
+			req.ParentID = &parent
+		}
+		if _, err := svc.CreateTODOFrom(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 3 is deleted on its own a day before 1 takes 2 with it
+	if err := svc.DeleteTODO(ctx, []int64{3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := todoDB.Exec(`UPDATE todos SET deleted_at = DATETIME(deleted_at, '-1 day') WHERE id = 3`); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(t *testing.T, trashed bool) []int64 {
+		t.Helper()
+		ret, err := svc.ListTODO(ctx, &model.ReadTODORequest{Trashed: trashed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, todo := range ret.TODOs {
+			if trashed != (todo.DeletedAt != nil) {
+				t.Fatal("expected deleted_at to be set: ", trashed, ", actual: ", todo.DeletedAt)
+			}
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+
+	t.Run("deleted", func(t *testing.T) {
+		if ids, want := list(t, false), []int64{4}; !reflect.DeepEqual(ids, want) {
+			t.Fatal("expected: ", want, ", actual: ", ids)
+		}
+		if ids, want := list(t, true), []int64{3, 2, 1}; !reflect.DeepEqual(ids, want) {
+			t.Fatal("expected: ", want, ", actual: ", ids)
+		}
+		if _, err := svc.GetTODO(ctx, 1); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+		if err := svc.DeleteTODO(ctx, []int64{1}); reflect.TypeOf(err) != reflect.TypeOf(&model.ErrNotFound{}) {
+			t.Fatal("expected: *model.ErrNotFound, actual: ", err)
+		}
+	})
+
+	testcase := []struct {
+		name       string
+		id         int64
+		wantErr    interface{}
+		wantParent *int64
+		wantIDs    []int64
+	}{
+		{name: "not in trash", id: 4, wantErr: &model.ErrNotFound{}},
+		{name: "parent in trash", id: 2, wantIDs: []int64{4, 2}},
+		{name: "again", id: 2, wantErr: &model.ErrNotFound{}},
+		{name: "root", id: 1, wantIDs: []int64{4, 2, 1}},
+	}
+
+	for _, tc := range testcase {
+		t.Run("restore "+tc.name, func(t *testing.T) {
+			todo, err := svc.RestoreTODO(ctx, tc.id)
+			if tc.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatal("expected: ", reflect.TypeOf(tc.wantErr), ", actual: ", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if todo.DeletedAt != nil || !reflect.DeepEqual(todo.ParentID, tc.wantParent) {
+				t.Fatal("expected: restored top-level TODO, actual: ", todo)
+			}
+			if ids := list(t, false); !reflect.DeepEqual(ids, tc.wantIDs) {
+				t.Fatal("expected: ", tc.wantIDs, ", actual: ", ids)
+			}
+		})
+	}
+
+	t.Run("purge", func(t *testing.T) {
+		n, err := svc.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatal("expected: 1, actual: ", n)
+		}
+		if ids := list(t, true); len(ids) != 0 {
+			t.Fatal("expected: [], actual: ", ids)
+		}
+	})
+
+	// trashTree creates a TODO with a subtask and returns their ids.
+	trashTree := func(t *testing.T) (parent, child int64) {
+		t.Helper()
+		todo, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "parent"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := svc.CreateTODOFrom(ctx, &model.CreateTODORequest{Subject: "child", ParentID: &todo.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return todo.ID, sub.ID
+	}
+
+	t.Run("restore subtask deleted on its own in the same second", func(t *testing.T) {
+		parent, child := trashTree(t)
+		for _, id := range []int64{child, parent} {
+			if err := svc.DeleteTODO(ctx, []int64{id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := todoDB.Exec(`UPDATE todos SET deleted_at = '2021-06-01 00:00:00' WHERE id IN (?, ?)`, parent, child); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.RestoreTODO(ctx, parent); err != nil {
+			t.Fatal(err)
+		}
+		if ids, want := list(t, true), []int64{child}; !reflect.DeepEqual(ids, want) {
+			t.Fatal("expected: ", want, ", actual: ", ids)
+		}
+	})
+
+	t.Run("restore subtask deleted along with its parent", func(t *testing.T) {
+		parent, child := trashTree(t)
+		if err := svc.DeleteTODO(ctx, []int64{child, parent}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.RestoreTODO(ctx, parent); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.GetTODO(ctx, child); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("restore trashed before roots were recorded", func(t *testing.T) {
+		parent, child := trashTree(t)
+		if err := svc.DeleteTODO(ctx, []int64{parent}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := todoDB.Exec(`UPDATE todos SET deleted_root_id = NULL WHERE id IN (?, ?)`, parent, child); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.RestoreTODO(ctx, parent); err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []int64{parent, child} {
+			if _, err := svc.GetTODO(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}